@@ -1,50 +1,179 @@
 package abci
 
 import (
+	"bytes"
 	"crypto/sha256"
+	"sort"
+	"time"
 
+	errorsmod "cosmossdk.io/errors"
+	dbm "github.com/cometbft/cometbft-db"
 	"github.com/cometbft/cometbft/abci/types"
+	cryptoenc "github.com/cometbft/cometbft/crypto/encoding"
 )
 
 // App is the ABCI basic application with internal data
 // This simulates a app and cuts down the a real consensus validation
 type App struct {
 	types.BaseApplication
-	state              *State
-	previousState      *State
-	totalValidators    int64
-	votesForBlock      int64
-	consensusThreshold float64
+	// state is the last committed state, its data is shared with the store
+	state *State
+	// store persists the committed state, one version per height
+	store *VersionedStore
+	// cache buffers the writes of the current block, they are flushed to the store on Commit
+	cache *CacheStore
+	// validators is the validator set voting on the current block
+	validators *ValidatorSet
+	// nextValidators is the validator set of the next block, built on EndBlock and swapped in on Commit
+	nextValidators     *ValidatorSet
+	consensusThreshold Threshold
+	// blockHash is the hash of the current block, only the votes for it count
+	blockHash []byte
+	// votes are the block hashes voted by each validator address on the current block
+	votes map[string][]byte
+	// validatorUpdates are the queued updates, applied to the next validator set on EndBlock
+	validatorUpdates []types.ValidatorUpdate
+	// inBlock is true between BeginBlock and Commit, blockEnded once EndBlock was called
+	inBlock    bool
+	blockEnded bool
+	// blockRejected is true if the votes of the current block didn't reach consensus
+	blockRejected bool
+	// blockTxs are the hashes of the TXs delivered on the current block
+	blockTxs []string
+	// The mempool and the CheckTx validation
+	mempool      *Mempool
+	txValidators []TxValidator
+	txPriority   TxPriority
+	// The ABCI++ proposal handlers
+	prepareProposal PrepareProposalHandler
+	processProposal ProcessProposalHandler
+	// The misbehavior evidence and the punished validators
+	slashingParams SlashingParams
+	// evidencePool has the evidence not yet included on a committed block, indexed by address and height
+	evidencePool map[string]types.Misbehavior
+	// blockEvidence are the keys of the evidence included on the current block
+	blockEvidence []string
+	// jailed are the validators removed from the set for misbehaving, with their slashed power
+	jailed *ValidatorSet
+	// previousValidators and previousJailed are the backup of the validators taken at BeginBlock
+	// The EndBlock validator updates are the changes from previousValidators to nextValidators
+	previousValidators *ValidatorSet
+	previousJailed     *ValidatorSet
+	// The liveness tracking, signingInfos and missedBlocks are indexed by validator address
+	signingInfos         map[string]ValidatorSigningInfo
+	previousSigningInfos map[string]ValidatorSigningInfo
+	missedBlocks         map[string][]bool
+	previousMissedBlocks map[string][]bool
+	// blockTime is the time of the current block, given on the BeginBlock header
+	blockTime time.Time
+	// lastCommitVotes are the votes counted by ProcessVotes on the last committed block
+	// They replace the LastCommitInfo of BeginBlock on the simulated flow, blockCommitVotes are the ones of the current block
+	lastCommitVotes  []types.VoteInfo
+	blockCommitVotes []types.VoteInfo
+	// unjailed are the validators returned to the next validator set on EndBlock
+	unjailed []Validator
 }
 
 // Assert the interface
 var _ ABCIInterface = (*App)(nil)
 
-// NewApp returns a new simulated app
-func NewApp(totalValidators int64, consensusThreshold float64) *App {
-	return &App{
-		state:              &State{Height: 0, Data: make(map[string][]byte)},
-		previousState:      &State{Height: 0, Data: make(map[string][]byte)},
-		totalValidators:    totalValidators,
+// NewApp returns a new simulated app with the initial validators and the consensus threshold, backed by a in memory database
+// It returns a error if the threshold or the validator set are not valid
+func NewApp(validators []Validator, consensusThreshold Threshold) (*App, error) {
+	return NewAppWithDB(dbm.NewMemDB(), validators, consensusThreshold)
+}
+
+// NewAppWithDB returns a new simulated app that persists its state on the database
+// A database with committed blocks is reopened at the last committed height, with its persisted validator set,
+// so the initial validators are only used on a new database
+func NewAppWithDB(db dbm.DB, validators []Validator, consensusThreshold Threshold) (*App, error) {
+	if err := consensusThreshold.Validate(); err != nil {
+		return nil, err
+	}
+	store, err := NewVersionedStore(db)
+	if err != nil {
+		return nil, err
+	}
+
+	// A reopened database continues with the committed validators, jail, liveness and evidence
+	info := VersionInfo{Validators: validators}
+	state := &State{Height: 0, Data: store.data}
+	if store.Version() > 0 {
+		info, err = store.GetVersionInfo(store.Version())
+		if err != nil {
+			return nil, err
+		}
+		state.Height = store.Version()
+		state.AppHash = info.AppHash
+	}
+
+	app := &App{
+		state:              state,
+		store:              store,
+		cache:              NewCacheStore(store),
 		consensusThreshold: consensusThreshold,
+		votes:              make(map[string][]byte),
+		mempool:            NewMempool(MempoolFIFO),
+		txValidators:       DefaultTxValidators(),
+		slashingParams:     DefaultSlashingParams(),
+	}
+	if err := app.loadVersionInfo(info); err != nil {
+		return nil, err
 	}
+
+	proposalHandler := NewDefaultProposalHandler(0)
+	app.prepareProposal = proposalHandler.PrepareProposalHandler()
+	app.processProposal = proposalHandler.ProcessProposalHandler()
+
+	return app, nil
 }
 
 // BeginBlock simulates the start of a block
 // The main responsibility here is to start with a empty consensus state
-func (app *App) BeginBlock(types.RequestBeginBlock) types.ResponseBeginBlock {
+// The misbehaviors of the request are punished, slashing and jailing the validators before the votes
+// The liveness of the validators is tracked with the LastCommitInfo, as the Cosmos-SDK slashing module does
+// Without it, the votes counted by ProcessVotes on the last block are used, so the simulated flow tracks the liveness too
+func (app *App) BeginBlock(req types.RequestBeginBlock) types.ResponseBeginBlock {
 	// Prepare the app by updating the consensus state
-	app.votesForBlock = 0
+	app.blockHash = req.Hash
+	app.votes = make(map[string][]byte)
+	app.validatorUpdates = nil
+	app.inBlock = true
+	app.blockEnded = false
+	app.blockRejected = false
+	app.blockTxs = nil
+	app.blockEvidence = nil
+	app.blockTime = req.Header.Time
+	app.unjailed = nil
+	app.nextValidators = nil
+	app.blockCommitVotes = nil
 
-	// Copy the state as a backup
-	app.previousState = &State{
-		Height: app.state.Height,
-		Data:   make(map[string][]byte),
+	// The writes of the block are buffered, only the validators are copied as a backup
+	app.cache = NewCacheStore(app.store)
+	app.previousValidators = app.validators.Copy()
+	app.previousJailed = app.jailed.Copy()
+	app.previousSigningInfos = make(map[string]ValidatorSigningInfo, len(app.signingInfos))
+	for address, signingInfo := range app.signingInfos {
+		app.previousSigningInfos[address] = signingInfo
 	}
-	for hash, tx := range app.state.Data {
-		app.previousState.Data[hash] = tx
+	app.previousMissedBlocks = make(map[string][]bool, len(app.missedBlocks))
+	for address, missedBlocks := range app.missedBlocks {
+		app.previousMissedBlocks[address] = append([]bool(nil), missedBlocks...)
 	}
 
+	// Punish the misbehaviors, the evidence leaves the pool once the block is committed
+	app.handleEvidence(req.ByzantineValidators)
+	for _, misbehavior := range req.ByzantineValidators {
+		app.blockEvidence = append(app.blockEvidence, evidenceKey(misbehavior.Validator.Address, misbehavior.Height))
+	}
+
+	// Track the signatures of the last block and jail the validators that missed too many blocks
+	commitVotes := req.LastCommitInfo.Votes
+	if len(commitVotes) == 0 {
+		commitVotes = app.lastCommitVotes
+	}
+	app.handleLiveness(app.state.Height, commitVotes)
+
 	// We don't need to emit events though the simulation
 	return types.ResponseBeginBlock{}
 }
@@ -52,47 +181,289 @@ func (app *App) BeginBlock(types.RequestBeginBlock) types.ResponseBeginBlock {
 func (app *App) DeliverTx(req types.RequestDeliverTx) types.ResponseDeliverTx {
 	// Simplified processing of a TX
 	txHash := HashTx(req.Tx)
-	app.state.Data[txHash] = req.Tx
+	app.cache.Set(txHash, req.Tx)
+	app.blockTxs = append(app.blockTxs, txHash)
 
 	// We can return a empty response for simplicity
 	// But the event can be zero similarly to Cosmos-SDK
 	return types.ResponseDeliverTx{Code: 0}
 }
 
+// EndBlock simulates the end of a block
+// The TXs were already written by DeliverTx, so it builds the validator set of the next block:
+// the unjailed validators return and the queued updates are applied
+// It returns every change of the set since BeginBlock as validator updates, slashed, jailed and unjailed validators included
+// The votes of the current block are still counted on the current set, the next set is only used after Commit
 func (app *App) EndBlock(req types.RequestEndBlock) types.ResponseEndBlock {
-	// Check the consensus
-	if app.hasPassedConsensus() {
-		// Increase the height
-		app.state.Height += 1
-		// If it has passed, we commit the state
-		_ = app.Commit()
-	} else {
+	if !app.inBlock {
+		return types.ResponseEndBlock{}
+	}
+
+	app.blockEnded = true
+	current := app.validators.Copy()
+	app.applyUnjails(app.state.Height + 1)
+	app.applyValidatorUpdates()
+	app.nextValidators, app.validators = app.validators, current
+
+	return types.ResponseEndBlock{ValidatorUpdates: app.previousValidators.UpdatesTo(app.nextValidators)}
+}
+
+// applyValidatorUpdates applies the queued updates to the validator set
+// The updates of the validators jailed after they were queued are dropped, jailed validators only return with Unjail
+func (app *App) applyValidatorUpdates() {
+	updates := make([]types.ValidatorUpdate, 0, len(app.validatorUpdates))
+	for _, update := range app.validatorUpdates {
+		key, err := cryptoenc.PubKeyFromProto(update.PubKey)
+		if err != nil || app.IsJailed(key.Address()) {
+			continue
+		}
+		updates = append(updates, update)
+	}
+
+	// The updates were validated when queued and the jailed validators dropped, only emptying the set can fail
+	// As in the Cosmos-SDK, a invalid validator set halts the chain
+	if err := app.validators.ApplyUpdates(updates, app.jailed); err != nil {
+		panic(err)
+	}
+}
+
+// ProcessVotes decides if the block passed consensus, it must be called after EndBlock and before Commit
+// If the votes didn't reach the threshold, the block is rolled back and Commit keeps the previous state
+// A real CometBFT driver only sends decided blocks, so this step is skipped and the block is always committed
+func (app *App) ProcessVotes() bool {
+	if !app.hasPassedConsensus() {
 		// If fail we rollback
 		app.rollbackState()
+		app.blockRejected = true
+		return false
+	}
+	app.blockCommitVotes = app.commitVotes()
+	return true
+}
+
+// commitVotes returns the signatures of the current set on the block, sorted by address
+// Only the votes for the block hash are signed, as on the LastCommitInfo of CometBFT
+func (app *App) commitVotes() []types.VoteInfo {
+	validators := app.validators.Validators()
+	votes := make([]types.VoteInfo, len(validators))
+	for i, validator := range validators {
+		votedHash, voted := app.votes[string(validator.Address)]
+		votes[i] = types.VoteInfo{
+			Validator:       types.Validator{Address: validator.Address, Power: validator.Power},
+			SignedLastBlock: voted && bytes.Equal(votedHash, app.blockHash),
+		}
+	}
+	return votes
+}
+
+// Commit persists the block, increases the height and returns the app hash
+// The hash is the Merkle root of the state data, so apps with the same TXs return the same hash
+// Computing it is O(state), so the cost of a committed block grows with the state size and doesn't only scale with its writes
+// Only starting a block and rejecting it are O(writes), see ComputeAppHash
+// Rejected blocks and calls outside of a block return the last app hash without changes
+func (app *App) Commit() types.ResponseCommit {
+	if app.inBlock && !app.blockRejected {
+		app.cache.Write()
+		app.state.Height += 1
+		app.state.AppHash = ComputeAppHash(app.state.Data)
+
+		// The committed TXs leave the mempool, the TXs of rejected blocks are kept to be proposed again
+		for _, txHash := range app.blockTxs {
+			app.mempool.Remove(txHash)
+		}
+		for _, key := range app.blockEvidence {
+			delete(app.evidencePool, key)
+		}
+
+		// The validator set built on EndBlock is used from the next block
+		if app.nextValidators != nil {
+			app.validators = app.nextValidators
+			app.nextValidators = nil
+		}
+
+		// The votes counted by ProcessVotes are the last commit of the next block, a real driver sends it instead
+		app.lastCommitVotes = app.blockCommitVotes
+
+		// Persist the block, a failed write halts the chain as on CometBFT
+		if err := app.persistBlock(); err != nil {
+			panic(err)
+		}
 	}
+	app.inBlock = false
+
+	return types.ResponseCommit{Data: app.state.AppHash}
+}
 
-	// In this simulate we don't update the internal consensus state
-	return types.ResponseEndBlock{}
+// persistBlock writes the writes of the block, the new validator set and the jail, liveness and evidence state
+// as the version of the height
+func (app *App) persistBlock() error {
+	version, err := app.store.Commit(app.versionInfo())
+	if err != nil {
+		return err
+	}
+	if version != app.state.Height {
+		return errorsmod.Wrapf(ErrStore, "committed version %d doesn't match the height %d", version, app.state.Height)
+	}
+	return nil
+}
+
+// versionInfo returns the version info of the committed state, the lists are sorted by address
+func (app *App) versionInfo() VersionInfo {
+	info := VersionInfo{
+		AppHash:         app.state.AppHash,
+		Validators:      app.validators.Validators(),
+		Jailed:          app.jailed.Validators(),
+		Evidence:        app.PendingEvidence(),
+		LastCommitVotes: app.lastCommitVotes,
+	}
+
+	addresses := make([]string, 0, len(app.signingInfos))
+	for address := range app.signingInfos {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	for _, address := range addresses {
+		info.SigningInfos = append(info.SigningInfos, app.signingInfos[address])
+	}
+
+	addresses = addresses[:0]
+	for address := range app.missedBlocks {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	for _, address := range addresses {
+		info.MissedBlocks = append(info.MissedBlocks, ValidatorMissedBlocks{Address: []byte(address), MissedBlocks: app.missedBlocks[address]})
+	}
+	return info
+}
+
+// loadVersionInfo replaces the validators, jail, liveness and evidence state with the ones of a version
+// The validator set can't be empty, the jailed validators are not validated since they can be slashed to zero power
+func (app *App) loadVersionInfo(info VersionInfo) error {
+	if len(info.Validators) == 0 {
+		return errorsmod.Wrap(ErrInvalidValidator, "the validator set can't be empty")
+	}
+	validators, err := NewValidatorSet(info.Validators)
+	if err != nil {
+		return err
+	}
+
+	jailed := &ValidatorSet{validators: make(map[string]Validator, len(info.Jailed))}
+	for _, validator := range info.Jailed {
+		jailed.validators[string(validator.Address)] = validator
+	}
+	signingInfos := make(map[string]ValidatorSigningInfo, len(info.SigningInfos))
+	for _, signingInfo := range info.SigningInfos {
+		signingInfos[string(signingInfo.Address)] = signingInfo
+	}
+	missedBlocks := make(map[string][]bool, len(info.MissedBlocks))
+	for _, window := range info.MissedBlocks {
+		missedBlocks[string(window.Address)] = window.MissedBlocks
+	}
+	evidencePool := make(map[string]types.Misbehavior, len(info.Evidence))
+	for _, misbehavior := range info.Evidence {
+		evidencePool[evidenceKey(misbehavior.Validator.Address, misbehavior.Height)] = misbehavior
+	}
+
+	app.validators = validators
+	app.jailed = jailed
+	app.signingInfos = signingInfos
+	app.missedBlocks = missedBlocks
+	app.evidencePool = evidencePool
+	app.lastCommitVotes = info.LastCommitVotes
+	return nil
+}
+
+// Close closes the database of the app
+func (app *App) Close() error {
+	return app.store.Close()
 }
 
 func (app *App) rollbackState() {
-	// Restore the old state
-	// Normally this would be done by not committing the database changes
-	app.state = app.previousState
+	// Throw away the writes of the block and restore the validators
+	app.cache.Discard()
+	app.validators = app.previousValidators
+	app.jailed = app.previousJailed
+	app.signingInfos = app.previousSigningInfos
+	app.missedBlocks = app.previousMissedBlocks
+	app.nextValidators = nil
 }
 
-// Vote is a helper function to vote in a block
-func (app *App) Vote() {
-	app.votesForBlock += 1
+// Vote registers the vote of a validator for a block hash
+// Only the first vote of each validator counts, repeating the same vote has no effect
+// Voting for a different hash is a double sign, the evidence is added to the pool for the next block
+func (app *App) Vote(validatorAddr []byte, blockHash []byte) error {
+	validator, found := app.validators.GetByAddress(validatorAddr)
+	if !found {
+		return errorsmod.Wrapf(ErrUnknownValidator, "validator %X is not on the validator set", validatorAddr)
+	}
+
+	if votedHash, voted := app.votes[string(validatorAddr)]; voted {
+		if bytes.Equal(votedHash, blockHash) {
+			return nil
+		}
+		app.addDuplicateVoteEvidence(validator)
+		return errorsmod.Wrapf(ErrDuplicateVote, "validator %X already voted for block %X", validatorAddr, votedHash)
+	}
+
+	app.votes[string(validatorAddr)] = blockHash
+	return nil
 }
 
-// hasPassedConsensus check if the votes were enough to pass consensus
+// UpdateValidator queues a validator update, it is applied on EndBlock and the new set is used after Commit
+// A update with zero power removes the validator
+// Jailed validators can't be updated, they only return to the set with Unjail
+// It must be called between BeginBlock and EndBlock
+func (app *App) UpdateValidator(update types.ValidatorUpdate) error {
+	if err := app.checkBlockOpen(); err != nil {
+		return err
+	}
+	key, err := cryptoenc.PubKeyFromProto(update.PubKey)
+	if err != nil {
+		return errorsmod.Wrapf(ErrInvalidValidator, "invalid validator public key: %s", err)
+	}
+	if update.Power < 0 {
+		return errorsmod.Wrapf(ErrInvalidValidator, "negative power %d", update.Power)
+	}
+	if app.IsJailed(key.Address()) {
+		if app.signingInfo(key.Address()).Tombstoned {
+			return errorsmod.Wrapf(ErrValidatorTombstoned, "validator %X double signed", key.Address())
+		}
+		return errorsmod.Wrapf(ErrValidatorJailed, "validator %X is jailed", key.Address())
+	}
+
+	app.validatorUpdates = append(app.validatorUpdates, update)
+	return nil
+}
+
+// checkBlockOpen returns a error if the block is not between BeginBlock and EndBlock
+// The changes queued outside of it would never be applied
+func (app *App) checkBlockOpen() error {
+	if !app.inBlock || app.blockEnded {
+		return errorsmod.Wrap(ErrBlockNotOpen, "must be called between BeginBlock and EndBlock")
+	}
+	return nil
+}
+
+// GetValidators returns the current validators sorted by address
+func (app *App) GetValidators() []Validator {
+	return app.validators.Validators()
+}
+
+// hasPassedConsensus check if the voting power for the block was enough to pass consensus
 func (app App) hasPassedConsensus() bool {
-	votesPercent := (float64(app.votesForBlock) / float64(app.totalValidators))
-	return votesPercent >= app.consensusThreshold
+	var votedPower int64
+	for address, votedHash := range app.votes {
+		validator, found := app.validators.GetByAddress([]byte(address))
+		if found && bytes.Equal(votedHash, app.blockHash) {
+			votedPower += validator.Power
+		}
+	}
+
+	return app.consensusThreshold.IsPassed(votedPower, app.validators.TotalPower())
 }
 
-// GetState returns the current state, used on tests
+// GetState returns the last committed state, the writes of the current block are only visible after Commit
 func (app App) GetState() *State {
 	return app.state
 }
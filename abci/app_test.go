@@ -4,6 +4,9 @@ import (
 	"testing"
 
 	"github.com/cometbft/cometbft/abci/types"
+	"github.com/cometbft/cometbft/crypto/ed25519"
+	cryptoenc "github.com/cometbft/cometbft/crypto/encoding"
+	cmtproto "github.com/cometbft/cometbft/proto/tendermint/types"
 	"github.com/stretchr/testify/require"
 
 	"ibc-fee/abci"
@@ -11,7 +14,7 @@ import (
 
 // TestConsensusPass tests a perfect consensus on the simulated app
 func TestConsensusPass(t *testing.T) {
-	app := abci.NewApp(4, 0.66)
+	app := newApp(t, newValidators(4), abci.NewThreshold(66, 100))
 
 	// Start a new block
 	app.BeginBlock(types.RequestBeginBlock{})
@@ -25,6 +28,8 @@ func TestConsensusPass(t *testing.T) {
 
 	// Finalize the block
 	app.EndBlock(types.RequestEndBlock{})
+	app.ProcessVotes()
+	app.Commit()
 
 	// Assert the new height
 	currentState := app.GetState()
@@ -39,7 +44,7 @@ func TestConsensusPass(t *testing.T) {
 
 // TestFailedConsensus test a failed consensus by not reaching the vote threshold
 func TestFailedConsensus(t *testing.T) {
-	app := abci.NewApp(4, 0.66)
+	app := newApp(t, newValidators(4), abci.NewThreshold(66, 100))
 
 	// Start a new block
 	app.BeginBlock(types.RequestBeginBlock{})
@@ -53,6 +58,8 @@ func TestFailedConsensus(t *testing.T) {
 
 	// Finalize the block
 	app.EndBlock(types.RequestEndBlock{})
+	app.ProcessVotes()
+	app.Commit()
 
 	// Assert that height remains the same
 	currentState := app.GetState()
@@ -65,7 +72,7 @@ func TestFailedConsensus(t *testing.T) {
 // TestMixedConsensus test a passing consensus and them a failed consensus
 // The first set of messages should be commit but not the failed set
 func TestMixedConsensus(t *testing.T) {
-	app := abci.NewApp(10, 0.50) // Use different values just to cover more field
+	app := newApp(t, newValidators(10), abci.NewThreshold(1, 2)) // Use different values just to cover more field
 
 	// PASSING BLOCK 0
 
@@ -80,6 +87,8 @@ func TestMixedConsensus(t *testing.T) {
 
 	// Finalize the block
 	app.EndBlock(types.RequestEndBlock{})
+	app.ProcessVotes()
+	app.Commit()
 
 	// FAILED BLOCK 1
 
@@ -94,6 +103,8 @@ func TestMixedConsensus(t *testing.T) {
 
 	// Finalize the block
 	app.EndBlock(types.RequestEndBlock{})
+	app.ProcessVotes()
+	app.Commit()
 
 	// ASSERT
 
@@ -107,9 +118,251 @@ func TestMixedConsensus(t *testing.T) {
 	require.Equal(t, []byte("passing_tx"), currentState.Data[passingTxHash])
 }
 
+// TestCommitAppHash tests that apps processing the same TXs report the same app hash
+func TestCommitAppHash(t *testing.T) {
+	appA := newApp(t, newValidators(4), abci.NewThreshold(66, 100))
+	appB := newApp(t, newValidators(4), abci.NewThreshold(66, 100))
+
+	// Deliver the same TXs on a different order
+	runBlock(t, appA, withVotes(4), withTxs([]byte("tx1"), []byte("tx2"), []byte("tx3")))
+	runBlock(t, appB, withVotes(4), withTxs([]byte("tx3"), []byte("tx1"), []byte("tx2")))
+
+	hashA := appA.GetState().AppHash
+	require.NotEmpty(t, hashA)
+	require.Equal(t, hashA, appB.GetState().AppHash)
+
+	// Commit returns the same hash
+	require.Equal(t, hashA, appA.Commit().Data)
+
+	// A different TX changes the hash
+	runBlock(t, appB, withVotes(4), withTxs([]byte("tx4")))
+	require.NotEqual(t, hashA, appB.GetState().AppHash)
+}
+
+// TestFailedConsensusKeepsAppHash tests that a failed block doesn't change the app hash
+func TestFailedConsensusKeepsAppHash(t *testing.T) {
+	app := newApp(t, newValidators(4), abci.NewThreshold(66, 100))
+
+	runBlock(t, app, withVotes(4), withTxs([]byte("tx1")))
+	hash := app.GetState().AppHash
+
+	runBlock(t, app, withVotes(1), withTxs([]byte("tx2")))
+	require.Equal(t, hash, app.GetState().AppHash)
+}
+
+// TestCommitFinalizesState tests that the height and app hash only change on Commit
+func TestCommitFinalizesState(t *testing.T) {
+	app := newApp(t, newValidators(4), abci.NewThreshold(66, 100))
+
+	app.BeginBlock(types.RequestBeginBlock{})
+	app.DeliverTx(types.RequestDeliverTx{Tx: []byte("tx1")})
+	voteOnApp(4, app)
+	app.EndBlock(types.RequestEndBlock{})
+	require.True(t, app.ProcessVotes())
+
+	// Nothing is finalized before Commit
+	require.Equal(t, int64(0), app.GetState().Height)
+	require.Empty(t, app.GetState().AppHash)
+
+	res := app.Commit()
+	require.Equal(t, int64(1), app.GetState().Height)
+	require.Equal(t, app.GetState().AppHash, res.Data)
+
+	// Commit outside of a block doesn't change the state
+	require.Equal(t, res.Data, app.Commit().Data)
+	require.Equal(t, int64(1), app.GetState().Height)
+}
+
+// TestCommitWithoutProcessVotes tests the flow of a real CometBFT driver, where every block is committed
+func TestCommitWithoutProcessVotes(t *testing.T) {
+	app := newApp(t, newValidators(4), abci.NewThreshold(66, 100))
+
+	app.BeginBlock(types.RequestBeginBlock{})
+	app.DeliverTx(types.RequestDeliverTx{Tx: []byte("tx1")})
+	app.EndBlock(types.RequestEndBlock{})
+	app.Commit()
+
+	require.Equal(t, int64(1), app.GetState().Height)
+	require.Len(t, app.GetState().Data, 1)
+}
+
+// TestProcessVotesRejected tests that a rejected block is rolled back and Commit keeps the previous state
+func TestProcessVotesRejected(t *testing.T) {
+	app := newApp(t, newValidators(4), abci.NewThreshold(66, 100))
+	runBlock(t, app, withVotes(4), withTxs([]byte("tx1")))
+	hash := app.GetState().AppHash
+
+	app.BeginBlock(types.RequestBeginBlock{})
+	app.DeliverTx(types.RequestDeliverTx{Tx: []byte("tx2")})
+	voteOnApp(2, app)
+	app.EndBlock(types.RequestEndBlock{})
+	require.False(t, app.ProcessVotes())
+
+	require.Equal(t, hash, app.Commit().Data)
+	require.Equal(t, int64(1), app.GetState().Height)
+	require.Len(t, app.GetState().Data, 1)
+}
+
+// blockConfig is the config of a block run by runBlock
+type blockConfig struct {
+	req          types.RequestBeginBlock
+	txs          [][]byte
+	updates      []types.ValidatorUpdate
+	totalVotes   int
+	doubleSigner *abci.Validator
+	during       func()
+	// driver runs the block as a CometBFT driver, which never calls Vote nor ProcessVotes
+	driver bool
+}
+
+// blockOption sets a option of a block run by runBlock
+type blockOption func(*blockConfig)
+
+// withHeader sets the header of the block
+func withHeader(header cmtproto.Header) blockOption {
+	return func(c *blockConfig) {
+		c.req.Header = header
+	}
+}
+
+// atHeight sets the header of the block with the height and its block time
+func atHeight(height int64) blockOption {
+	return withHeader(cmtproto.Header{Height: height, Time: blockTime(height)})
+}
+
+// withEvidence sets the misbehaviors of the block
+func withEvidence(evidence []types.Misbehavior) blockOption {
+	return func(c *blockConfig) {
+		c.req.ByzantineValidators = evidence
+	}
+}
+
+// withCommitInfo runs the block as a CometBFT driver with the signatures of the last block, the validators don't vote
+func withCommitInfo(commitInfo types.CommitInfo) blockOption {
+	return func(c *blockConfig) {
+		c.req.LastCommitInfo = commitInfo
+		c.driver = true
+	}
+}
+
+// withTxs sets the TXs delivered on the block
+func withTxs(txs ...[]byte) blockOption {
+	return func(c *blockConfig) {
+		c.txs = txs
+	}
+}
+
+// withUpdates sets the validator updates queued on the block
+func withUpdates(updates ...types.ValidatorUpdate) blockOption {
+	return func(c *blockConfig) {
+		c.updates = updates
+	}
+}
+
+// withVotes sets the amount of validators that vote for the block, by default every validator votes
+func withVotes(totalVotes int) blockOption {
+	return func(c *blockConfig) {
+		c.totalVotes = totalVotes
+	}
+}
+
+// withDoubleSign makes the validator vote for a conflicting block after its vote
+func withDoubleSign(validator abci.Validator) blockOption {
+	return func(c *blockConfig) {
+		c.doubleSigner = &validator
+	}
+}
+
+// duringBlock runs the function after BeginBlock, so the tests can check the state inside of the block
+func duringBlock(fn func()) blockOption {
+	return func(c *blockConfig) {
+		c.during = fn
+	}
+}
+
+// runBlock is a helper function that runs a full block, it returns the EndBlock response
+// The block is committed only if the votes pass the threshold, as decided by ProcessVotes
+func runBlock(t *testing.T, app *abci.App, opts ...blockOption) types.ResponseEndBlock {
+	config := blockConfig{totalVotes: -1}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	app.BeginBlock(config.req)
+	if config.during != nil {
+		config.during()
+	}
+	for _, tx := range config.txs {
+		app.DeliverTx(types.RequestDeliverTx{Tx: tx})
+	}
+	for _, update := range config.updates {
+		require.NoError(t, app.UpdateValidator(update))
+	}
+	if !config.driver {
+		totalVotes := config.totalVotes
+		if totalVotes < 0 {
+			totalVotes = len(app.GetValidators())
+		}
+		voteOnApp(totalVotes, app)
+	}
+	if config.doubleSigner != nil {
+		require.ErrorIs(t, app.Vote(config.doubleSigner.Address, []byte("conflicting")), abci.ErrDuplicateVote)
+	}
+	res := app.EndBlock(types.RequestEndBlock{})
+	if !config.driver {
+		app.ProcessVotes()
+	}
+	app.Commit()
+	return res
+}
+
+// newApp is a helper function that returns a new app, failing the test on invalid params
+func newApp(t *testing.T, validators []abci.Validator, threshold abci.Threshold) *abci.App {
+	app, err := abci.NewApp(validators, threshold)
+	require.NoError(t, err)
+	return app
+}
+
 // voteOnApp is a helper function to test the voting process on the app
+// The first validators vote for the block, blocks on tests have no hash
 func voteOnApp(totalVotes int, app *abci.App) {
-	for range totalVotes {
-		app.Vote()
+	for _, validator := range app.GetValidators()[:totalVotes] {
+		if err := app.Vote(validator.Address, nil); err != nil {
+			panic(err)
+		}
 	}
 }
+
+// newValidators is a helper function that returns validators with the same power
+// Their addresses are derived from deterministic keys, see validatorKey
+func newValidators(total int) []abci.Validator {
+	validators := make([]abci.Validator, total)
+	for i := range validators {
+		validators[i] = newValidator(i, 1)
+	}
+	return validators
+}
+
+// newValidator is a helper function that returns the validator of the index with the power
+func newValidator(i int, power int64) abci.Validator {
+	pubKey, err := cryptoenc.PubKeyToProto(validatorKey(i).PubKey())
+	if err != nil {
+		panic(err)
+	}
+	validator, err := abci.NewValidator(pubKey, power)
+	if err != nil {
+		panic(err)
+	}
+	return validator
+}
+
+// withPower is a helper function that returns a copy of the validator with the power
+func withPower(validator abci.Validator, power int64) abci.Validator {
+	validator.Power = power
+	return validator
+}
+
+// validatorKey is a helper function that returns a deterministic key for the validator index
+func validatorKey(i int) ed25519.PrivKey {
+	return ed25519.GenPrivKeyFromSecret([]byte{byte(i)})
+}
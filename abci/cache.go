@@ -0,0 +1,80 @@
+package abci
+
+import (
+	"sort"
+)
+
+// KVStore is a key value store that a cache can be layered on
+type KVStore interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte)
+	Delete(key string)
+}
+
+// cacheValue is a write buffered on the cache, a deleted key hides the value of the parent
+type cacheValue struct {
+	value   []byte
+	deleted bool
+}
+
+// CacheStore is a copy on write layer over a parent store
+// Writes are buffered until they are flushed with Write or thrown away with Discard,
+// so its cost depends on the amount of writes and not on the size of the parent
+type CacheStore struct {
+	parent KVStore
+	writes map[string]cacheValue
+}
+
+// Assert the interface, caches can be layered over other caches
+var _ KVStore = (*CacheStore)(nil)
+
+// NewCacheStore returns a empty cache over the parent store
+func NewCacheStore(parent KVStore) *CacheStore {
+	return &CacheStore{parent: parent, writes: make(map[string]cacheValue)}
+}
+
+// Get returns the buffered value of a key, or the value of the parent if the key wasn't written
+func (c *CacheStore) Get(key string) ([]byte, bool) {
+	if write, found := c.writes[key]; found {
+		return write.value, !write.deleted
+	}
+	return c.parent.Get(key)
+}
+
+// Set buffers the value of a key
+func (c *CacheStore) Set(key string, value []byte) {
+	c.writes[key] = cacheValue{value: value}
+}
+
+// Delete buffers the deletion of a key
+func (c *CacheStore) Delete(key string) {
+	c.writes[key] = cacheValue{deleted: true}
+}
+
+// Size returns the amount of buffered writes
+func (c *CacheStore) Size() int {
+	return len(c.writes)
+}
+
+// Write flushes the buffered writes to the parent, sorted by key, and empties the cache
+func (c *CacheStore) Write() {
+	keys := make([]string, 0, len(c.writes))
+	for key := range c.writes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if write := c.writes[key]; write.deleted {
+			c.parent.Delete(key)
+		} else {
+			c.parent.Set(key, write.value)
+		}
+	}
+	c.Discard()
+}
+
+// Discard throws away the buffered writes
+func (c *CacheStore) Discard() {
+	c.writes = make(map[string]cacheValue)
+}
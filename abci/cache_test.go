@@ -0,0 +1,199 @@
+package abci_test
+
+import (
+	"fmt"
+	"testing"
+
+	dbm "github.com/cometbft/cometbft-db"
+	"github.com/cometbft/cometbft/abci/types"
+	"github.com/stretchr/testify/require"
+
+	"ibc-fee/abci"
+)
+
+// TestCacheStore tests that the writes are only visible on the parent after Write
+func TestCacheStore(t *testing.T) {
+	parent := newVersionedStore(t, 0)
+	parent.Set("a", []byte("1"))
+	parent.Set("b", []byte("2"))
+
+	cache := abci.NewCacheStore(parent)
+	cache.Set("a", []byte("3"))
+	cache.Delete("b")
+	cache.Set("c", []byte("4"))
+	require.Equal(t, 3, cache.Size())
+
+	// The cache sees its writes, the parent keeps its values
+	value, found := cache.Get("a")
+	require.True(t, found)
+	require.Equal(t, []byte("3"), value)
+	_, found = cache.Get("b")
+	require.False(t, found)
+	value, _ = parent.Get("a")
+	require.Equal(t, []byte("1"), value)
+	_, found = parent.Get("c")
+	require.False(t, found)
+
+	// Discard throws the writes away
+	cache.Discard()
+	require.Zero(t, cache.Size())
+	value, _ = cache.Get("a")
+	require.Equal(t, []byte("1"), value)
+
+	// Write flushes the writes
+	cache.Set("a", []byte("3"))
+	cache.Delete("b")
+	cache.Write()
+	require.Zero(t, cache.Size())
+	require.Equal(t, map[string][]byte{"a": []byte("3")}, parent.Data())
+}
+
+// TestNestedCacheStore tests that caches can be layered
+func TestNestedCacheStore(t *testing.T) {
+	parent := newVersionedStore(t, 0)
+	cache := abci.NewCacheStore(parent)
+	nested := abci.NewCacheStore(cache)
+
+	nested.Set("a", []byte("1"))
+	_, found := cache.Get("a")
+	require.False(t, found)
+
+	nested.Write()
+	_, found = cache.Get("a")
+	require.True(t, found)
+	_, found = parent.Get("a")
+	require.False(t, found)
+
+	cache.Write()
+	_, found = parent.Get("a")
+	require.True(t, found)
+}
+
+// TestBlockWritesBuffered tests that the TXs of a block are only on the state after Commit
+func TestBlockWritesBuffered(t *testing.T) {
+	app := newApp(t, newValidators(4), abci.NewThreshold(66, 100))
+	runBlock(t, app, withVotes(4), withTxs([]byte("tx1")))
+
+	app.BeginBlock(types.RequestBeginBlock{})
+	app.DeliverTx(types.RequestDeliverTx{Tx: []byte("tx2")})
+	require.NotContains(t, app.GetState().Data, abci.HashTx([]byte("tx2")))
+	voteOnApp(4, app)
+	app.EndBlock(types.RequestEndBlock{})
+	app.ProcessVotes()
+	app.Commit()
+
+	require.Contains(t, app.GetState().Data, abci.HashTx([]byte("tx2")))
+	require.Len(t, app.GetState().Data, 2)
+}
+
+// BenchmarkCacheStore measures a block of writes on a cache, the cost doesn't depend on the size of the parent
+func BenchmarkCacheStore(b *testing.B) {
+	for _, stateSize := range []int{1_000, 100_000} {
+		for _, writes := range []int{10, 1_000} {
+			b.Run(fmt.Sprintf("state=%d/writes=%d", stateSize, writes), func(b *testing.B) {
+				parent := newVersionedStore(b, stateSize)
+				values := benchmarkValues(writes)
+
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					cache := abci.NewCacheStore(parent)
+					for key, value := range values {
+						cache.Set(key, value)
+					}
+					cache.Discard()
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkRejectedBlock measures a full block that is rolled back
+// BeginBlock and the rollback only touch the writes of the block, so the cost doesn't depend on the state size
+// The app hash of a committed block is still computed over the whole state
+func BenchmarkRejectedBlock(b *testing.B) {
+	for _, stateSize := range []int{1_000, 100_000} {
+		for _, writes := range []int{10, 1_000} {
+			b.Run(fmt.Sprintf("state=%d/writes=%d", stateSize, writes), func(b *testing.B) {
+				app := newBenchmarkApp(b, stateSize)
+				values := benchmarkValues(writes)
+
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					app.BeginBlock(types.RequestBeginBlock{})
+					for _, tx := range values {
+						app.DeliverTx(types.RequestDeliverTx{Tx: tx})
+					}
+					app.EndBlock(types.RequestEndBlock{})
+					app.ProcessVotes()
+					app.Commit()
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkCommittedBlock measures a full committed block
+// Unlike the rejected block, its cost grows with the state size, since the app hash is computed over the whole state
+// So a committed block doesn't scale with its writes alone, a state of 100k keys is about 100 times slower than 1k keys
+func BenchmarkCommittedBlock(b *testing.B) {
+	for _, stateSize := range []int{1_000, 100_000} {
+		for _, writes := range []int{10, 1_000} {
+			b.Run(fmt.Sprintf("state=%d/writes=%d", stateSize, writes), func(b *testing.B) {
+				app := newBenchmarkApp(b, stateSize)
+				values := benchmarkValues(writes)
+
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					app.BeginBlock(types.RequestBeginBlock{})
+					for _, tx := range values {
+						app.DeliverTx(types.RequestDeliverTx{Tx: tx})
+					}
+					app.EndBlock(types.RequestEndBlock{})
+					app.Commit()
+				}
+			})
+		}
+	}
+}
+
+// newVersionedStore returns a in memory store with a committed version with the amount of keys
+func newVersionedStore(tb testing.TB, size int) *abci.VersionedStore {
+	store, err := abci.NewVersionedStore(dbm.NewMemDB())
+	require.NoError(tb, err)
+	if size == 0 {
+		return store
+	}
+
+	for key, value := range benchmarkValues(size) {
+		store.Set(key, value)
+	}
+	_, err = store.Commit(abci.VersionInfo{Validators: newValidators(1)})
+	require.NoError(tb, err)
+	return store
+}
+
+// newBenchmarkApp returns a app reopened on a database with the amount of keys
+func newBenchmarkApp(tb testing.TB, size int) *abci.App {
+	db := dbm.NewMemDB()
+	store, err := abci.NewVersionedStore(db)
+	require.NoError(tb, err)
+	for key, value := range benchmarkValues(size) {
+		store.Set(key, value)
+	}
+	_, err = store.Commit(abci.VersionInfo{Validators: newValidators(4)})
+	require.NoError(tb, err)
+
+	app, err := abci.NewAppWithDB(db, nil, abci.NewThreshold(66, 100))
+	require.NoError(tb, err)
+	return app
+}
+
+// benchmarkValues returns the amount of values indexed by their TX hash
+func benchmarkValues(size int) map[string][]byte {
+	values := make(map[string][]byte, size)
+	for i := 0; i < size; i++ {
+		tx := []byte(fmt.Sprintf("tx%d", i))
+		values[abci.HashTx(tx)] = tx
+	}
+	return values
+}
@@ -0,0 +1,123 @@
+package abci
+
+import (
+	errorsmod "cosmossdk.io/errors"
+	"github.com/cometbft/cometbft/abci/types"
+)
+
+// TxValidator validates a TX on CheckTx, the TX is only added to the mempool if every validator passes
+// The validators can return any error, the registered errors set the response code
+type TxValidator func(app *App, req types.RequestCheckTx) error
+
+// TxDecoder returns a error if the TX bytes can't be decoded
+type TxDecoder func(tx []byte) error
+
+// TxPriority returns the priority of a TX on the priority mempool
+type TxPriority func(tx []byte) int64
+
+// DefaultTxValidators returns the validators used by a new app, only duplicates are rejected
+func DefaultTxValidators() []TxValidator {
+	return []TxValidator{DuplicateTxValidator()}
+}
+
+// MaxTxSizeValidator rejects TXs bigger than the max bytes
+func MaxTxSizeValidator(maxBytes int) TxValidator {
+	return func(_ *App, req types.RequestCheckTx) error {
+		if len(req.Tx) > maxBytes {
+			return errorsmod.Wrapf(ErrTxTooLarge, "tx has %d bytes, max is %d", len(req.Tx), maxBytes)
+		}
+		return nil
+	}
+}
+
+// DuplicateTxValidator rejects TXs that are already committed or on the mempool
+// On a recheck the TX is already on the mempool, so only the committed state is checked
+func DuplicateTxValidator() TxValidator {
+	return func(app *App, req types.RequestCheckTx) error {
+		txHash := HashTx(req.Tx)
+		if _, found := app.state.Data[txHash]; found {
+			return errorsmod.Wrap(ErrDuplicateTx, "tx already committed")
+		}
+		if req.Type == types.CheckTxType_New && app.mempool.Has(txHash) {
+			return errorsmod.Wrap(ErrDuplicateTx, "tx already in mempool")
+		}
+		return nil
+	}
+}
+
+// DecodeTxValidator rejects TXs that can't be decoded by the decoder
+func DecodeTxValidator(decoder TxDecoder) TxValidator {
+	return func(_ *App, req types.RequestCheckTx) error {
+		if err := decoder(req.Tx); err != nil {
+			return errorsmod.Wrapf(ErrInvalidTx, "failed to decode tx: %s", err)
+		}
+		return nil
+	}
+}
+
+// SetTxValidators replaces the validators run on CheckTx
+func (app *App) SetTxValidators(validators ...TxValidator) {
+	app.txValidators = validators
+}
+
+// SetTxPriority sets the function that gives the priority of the TXs, by default all TXs have the same priority
+func (app *App) SetTxPriority(txPriority TxPriority) {
+	app.txPriority = txPriority
+}
+
+// SetMempool replaces the mempool of the app
+func (app *App) SetMempool(mempool *Mempool) {
+	app.mempool = mempool
+}
+
+// GetMempool returns the mempool of the app, used to build blocks on tests
+func (app *App) GetMempool() *Mempool {
+	return app.mempool
+}
+
+// CheckTx validates a TX and adds it to the mempool
+// Rechecked TXs that are not valid anymore are removed from the mempool
+func (app *App) CheckTx(req types.RequestCheckTx) types.ResponseCheckTx {
+	if err := app.runTxValidators(req); err != nil {
+		if req.Type == types.CheckTxType_Recheck {
+			app.mempool.Remove(HashTx(req.Tx))
+		}
+		return checkTxError(err)
+	}
+
+	var priority int64
+	if app.txPriority != nil {
+		priority = app.txPriority(req.Tx)
+	}
+
+	// Rechecked TXs are kept on the mempool with their original order
+	if req.Type == types.CheckTxType_New {
+		if err := app.mempool.Insert(req.Tx, priority); err != nil {
+			return checkTxError(err)
+		}
+	}
+
+	return types.ResponseCheckTx{Code: CodeTypeOK, Priority: priority}
+}
+
+// validateTx validates a TX that may already be on the mempool, as a recheck
+// It is used to validate the TXs of the proposals
+func (app *App) validateTx(tx []byte) error {
+	return app.runTxValidators(types.RequestCheckTx{Tx: tx, Type: types.CheckTxType_Recheck})
+}
+
+// runTxValidators runs all the TX validators, stopping at the first failure
+func (app *App) runTxValidators(req types.RequestCheckTx) error {
+	for _, validator := range app.txValidators {
+		if err := validator(app, req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkTxError returns a failed CheckTx response with the code of the error
+func checkTxError(err error) types.ResponseCheckTx {
+	codespace, code, log := errorsmod.ABCIInfo(err, false)
+	return types.ResponseCheckTx{Codespace: codespace, Code: code, Log: log}
+}
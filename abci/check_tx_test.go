@@ -0,0 +1,115 @@
+package abci_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/cometbft/cometbft/abci/types"
+	"github.com/stretchr/testify/require"
+
+	"ibc-fee/abci"
+)
+
+// TestCheckTxValidators tests the size, duplicate and decode validators
+func TestCheckTxValidators(t *testing.T) {
+	app := newApp(t, newValidators(4), abci.NewThreshold(66, 100))
+	app.SetTxValidators(
+		abci.MaxTxSizeValidator(10),
+		abci.DuplicateTxValidator(),
+		abci.DecodeTxValidator(func(tx []byte) error {
+			if !bytes.HasPrefix(tx, []byte("tx")) {
+				return fmt.Errorf("missing tx prefix")
+			}
+			return nil
+		}),
+	)
+
+	testCases := []struct {
+		name         string
+		tx           []byte
+		expectedCode uint32
+	}{
+		{name: "valid TX", tx: []byte("tx1"), expectedCode: abci.CodeTypeOK},
+		{name: "TX already in mempool", tx: []byte("tx1"), expectedCode: abci.CodeTypeDuplicateTx},
+		{name: "TX too large", tx: []byte("tx_too_large"), expectedCode: abci.CodeTypeTxTooLarge},
+		{name: "TX can't be decoded", tx: []byte("invalid"), expectedCode: abci.CodeTypeInvalidTx},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res := app.CheckTx(types.RequestCheckTx{Tx: tc.tx, Type: types.CheckTxType_New})
+			require.Equal(t, tc.expectedCode, res.Code, res.Log)
+			if tc.expectedCode != abci.CodeTypeOK {
+				require.Equal(t, abci.Codespace, res.Codespace)
+			}
+		})
+	}
+
+	// Only the valid TX is on the mempool
+	require.Equal(t, 1, app.GetMempool().Size())
+}
+
+// TestCheckTxCommittedDuplicate tests that committed TXs are rejected and removed from the mempool
+func TestCheckTxCommittedDuplicate(t *testing.T) {
+	app := newApp(t, newValidators(4), abci.NewThreshold(66, 100))
+
+	require.Equal(t, abci.CodeTypeOK, app.CheckTx(types.RequestCheckTx{Tx: []byte("tx1")}).Code)
+	runBlock(t, app, withVotes(4), withTxs(app.GetMempool().ReapMaxBytes(-1)...))
+	require.Equal(t, 0, app.GetMempool().Size())
+
+	res := app.CheckTx(types.RequestCheckTx{Tx: []byte("tx1")})
+	require.Equal(t, abci.CodeTypeDuplicateTx, res.Code)
+}
+
+// TestCheckTxRecheck tests that rechecks keep valid TXs and remove the invalid ones
+func TestCheckTxRecheck(t *testing.T) {
+	app := newApp(t, newValidators(4), abci.NewThreshold(66, 100))
+	require.Equal(t, abci.CodeTypeOK, app.CheckTx(types.RequestCheckTx{Tx: []byte("tx1")}).Code)
+	require.Equal(t, abci.CodeTypeOK, app.CheckTx(types.RequestCheckTx{Tx: []byte("tx_long")}).Code)
+
+	// The TX size limit is lowered, so the long TX is not valid anymore
+	app.SetTxValidators(abci.MaxTxSizeValidator(3), abci.DuplicateTxValidator())
+
+	res := app.CheckTx(types.RequestCheckTx{Tx: []byte("tx1"), Type: types.CheckTxType_Recheck})
+	require.Equal(t, abci.CodeTypeOK, res.Code, res.Log)
+	res = app.CheckTx(types.RequestCheckTx{Tx: []byte("tx_long"), Type: types.CheckTxType_Recheck})
+	require.Equal(t, abci.CodeTypeTxTooLarge, res.Code)
+
+	require.Equal(t, [][]byte{[]byte("tx1")}, app.GetMempool().ReapMaxBytes(-1))
+}
+
+// TestBlockFromPriorityMempool tests a block built from the priority mempool with a byte limit
+func TestBlockFromPriorityMempool(t *testing.T) {
+	app := newApp(t, newValidators(4), abci.NewThreshold(66, 100))
+	app.SetMempool(abci.NewMempool(abci.MempoolPriority))
+	app.SetTxPriority(func(tx []byte) int64 { return int64(tx[len(tx)-1] - '0') })
+
+	for _, tx := range []string{"tx1", "tx3", "tx2"} {
+		res := app.CheckTx(types.RequestCheckTx{Tx: []byte(tx)})
+		require.Equal(t, abci.CodeTypeOK, res.Code, res.Log)
+	}
+
+	// Only the two TXs with the highest priority fit on the block
+	runBlock(t, app, withVotes(4), withTxs(app.GetMempool().ReapMaxBytes(6)...))
+
+	state := app.GetState()
+	require.Len(t, state.Data, 2)
+	require.Contains(t, state.Data, abci.HashTx([]byte("tx3")))
+	require.Contains(t, state.Data, abci.HashTx([]byte("tx2")))
+	require.Equal(t, [][]byte{[]byte("tx1")}, app.GetMempool().ReapMaxBytes(-1))
+}
+
+// TestRejectedBlockKeepsMempool tests that the TXs of a rejected block stay on the mempool
+func TestRejectedBlockKeepsMempool(t *testing.T) {
+	app := newApp(t, newValidators(4), abci.NewThreshold(66, 100))
+	require.Equal(t, abci.CodeTypeOK, app.CheckTx(types.RequestCheckTx{Tx: []byte("tx1")}).Code)
+
+	runBlock(t, app, withVotes(1), withTxs(app.GetMempool().ReapMaxBytes(-1)...))
+	require.Empty(t, app.GetState().Data)
+	require.Equal(t, 1, app.GetMempool().Size())
+
+	runBlock(t, app, withVotes(4), withTxs(app.GetMempool().ReapMaxBytes(-1)...))
+	require.Len(t, app.GetState().Data, 1)
+	require.Equal(t, 0, app.GetMempool().Size())
+}
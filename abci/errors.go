@@ -0,0 +1,49 @@
+package abci
+
+import (
+	errorsmod "cosmossdk.io/errors"
+)
+
+// Codespace is the codespace of the errors returned by the app
+const Codespace = "abci"
+
+// ABCI response codes returned by the app
+// Codes must never be changed or reused, new codes are added at the end
+const (
+	CodeTypeOK uint32 = iota
+	CodeTypeUnknownPath
+	CodeTypeInvalidQuery
+	CodeTypeNotFound
+	CodeTypeTxTooLarge
+	CodeTypeDuplicateTx
+	CodeTypeInvalidTx
+	CodeTypeInvalidValidator
+	CodeTypeUnknownValidator
+	CodeTypeDuplicateVote
+	CodeTypeInvalidThreshold
+	CodeTypeInvalidSlashingParams
+	CodeTypeValidatorNotJailed
+	CodeTypeValidatorJailed
+	CodeTypeValidatorTombstoned
+	CodeTypeStore
+	CodeTypeInvalidRollback
+	CodeTypeBlockNotOpen
+)
+
+// Errors returned by the app, their codes match the response codes
+var (
+	ErrTxTooLarge            = errorsmod.Register(Codespace, CodeTypeTxTooLarge, "tx too large")
+	ErrDuplicateTx           = errorsmod.Register(Codespace, CodeTypeDuplicateTx, "duplicated tx")
+	ErrInvalidTx             = errorsmod.Register(Codespace, CodeTypeInvalidTx, "invalid tx")
+	ErrInvalidValidator      = errorsmod.Register(Codespace, CodeTypeInvalidValidator, "invalid validator")
+	ErrUnknownValidator      = errorsmod.Register(Codespace, CodeTypeUnknownValidator, "unknown validator")
+	ErrDuplicateVote         = errorsmod.Register(Codespace, CodeTypeDuplicateVote, "duplicated vote")
+	ErrInvalidThreshold      = errorsmod.Register(Codespace, CodeTypeInvalidThreshold, "invalid consensus threshold")
+	ErrInvalidSlashingParams = errorsmod.Register(Codespace, CodeTypeInvalidSlashingParams, "invalid slashing params")
+	ErrValidatorNotJailed    = errorsmod.Register(Codespace, CodeTypeValidatorNotJailed, "validator not jailed")
+	ErrValidatorJailed       = errorsmod.Register(Codespace, CodeTypeValidatorJailed, "validator still jailed")
+	ErrValidatorTombstoned   = errorsmod.Register(Codespace, CodeTypeValidatorTombstoned, "validator tombstoned")
+	ErrStore                 = errorsmod.Register(Codespace, CodeTypeStore, "store error")
+	ErrInvalidRollback       = errorsmod.Register(Codespace, CodeTypeInvalidRollback, "invalid rollback")
+	ErrBlockNotOpen          = errorsmod.Register(Codespace, CodeTypeBlockNotOpen, "block not open")
+)
@@ -5,8 +5,14 @@ import (
 )
 
 // ABCIInterface is a cut down interface from: cometbft/abci/types/application.go
-// This only has a basic structure with: BeginBlock, DeliverTx and EndBlock
+// This only has a basic structure with: BeginBlock, DeliverTx, EndBlock, Commit, CheckTx, the ABCI++ proposals
+// and the Info and Query endpoints
 type ABCIInterface interface {
+	Info(types.RequestInfo) types.ResponseInfo
+	Query(types.RequestQuery) types.ResponseQuery
+	CheckTx(types.RequestCheckTx) types.ResponseCheckTx
+	PrepareProposal(types.RequestPrepareProposal) types.ResponsePrepareProposal
+	ProcessProposal(types.RequestProcessProposal) types.ResponseProcessProposal
 	BeginBlock(types.RequestBeginBlock) types.ResponseBeginBlock
 	DeliverTx(types.RequestDeliverTx) types.ResponseDeliverTx
 	EndBlock(types.RequestEndBlock) types.ResponseEndBlock
@@ -0,0 +1,162 @@
+package abci
+
+import (
+	"bytes"
+	"sort"
+	"time"
+
+	errorsmod "cosmossdk.io/errors"
+	"github.com/cometbft/cometbft/abci/types"
+)
+
+// ValidatorSigningInfo is the liveness info of a validator, as on the Cosmos-SDK slashing module
+type ValidatorSigningInfo struct {
+	// Address is the address of the validator
+	Address []byte `json:"address"`
+	// StartHeight is the height the validator started to be tracked, it can't be jailed before a full window
+	StartHeight int64 `json:"start_height"`
+	// IndexOffset is the amount of tracked blocks, its position on the window is the offset modulo the window
+	IndexOffset int64 `json:"index_offset"`
+	// MissedBlocksCounter is the amount of missed blocks on the window
+	MissedBlocksCounter int64 `json:"missed_blocks_counter"`
+	// JailedUntil is the time the validator can unjail
+	JailedUntil time.Time `json:"jailed_until"`
+	// Tombstoned is true if the validator double signed, it can never unjail
+	Tombstoned bool `json:"tombstoned"`
+}
+
+// ValidatorMissedBlocks is the missed blocks window of a validator, as persisted on the versions
+type ValidatorMissedBlocks struct {
+	// Address is the address of the validator
+	Address []byte `json:"address"`
+	// MissedBlocks are the missed flags of the window, indexed by the offset modulo the window
+	MissedBlocks []bool `json:"missed_blocks"`
+}
+
+// GetSigningInfo returns the liveness info of a validator
+func (app *App) GetSigningInfo(validatorAddr []byte) (ValidatorSigningInfo, bool) {
+	signingInfo, found := app.signingInfos[string(validatorAddr)]
+	return signingInfo, found
+}
+
+// Unjail queues the return of a jailed validator to the validator set, it is applied on EndBlock
+// The downtime period must have passed on the block time and tombstoned validators can't unjail
+// Validators slashed to zero power can't unjail either, CometBFT would take their update as a removal
+// As with UpdateValidator, it must be called between BeginBlock and EndBlock
+func (app *App) Unjail(validatorAddr []byte) error {
+	if err := app.checkBlockOpen(); err != nil {
+		return err
+	}
+	validator, found := app.jailed.GetByAddress(validatorAddr)
+	if !found {
+		return errorsmod.Wrapf(ErrValidatorNotJailed, "validator %X is not jailed", validatorAddr)
+	}
+
+	signingInfo := app.signingInfo(validatorAddr)
+	if signingInfo.Tombstoned {
+		return errorsmod.Wrapf(ErrValidatorTombstoned, "validator %X double signed", validatorAddr)
+	}
+	if app.blockTime.Before(signingInfo.JailedUntil) {
+		return errorsmod.Wrapf(ErrValidatorJailed, "validator %X is jailed until %s", validatorAddr, signingInfo.JailedUntil)
+	}
+	if validator.Power <= 0 {
+		return errorsmod.Wrapf(ErrInvalidValidator, "validator %X was slashed to zero power", validatorAddr)
+	}
+
+	app.unjailed = append(app.unjailed, validator)
+	return nil
+}
+
+// handleLiveness tracks the signatures of the block of the height on the sliding window of each validator
+// A validator that missed more blocks than allowed is slashed and jailed for the downtime period
+// The validators already out of the set, such as the ones jailed by the evidence, are skipped
+// The votes are sorted by address, so every app jails the same validators
+func (app *App) handleLiveness(height int64, votes []types.VoteInfo) {
+	window := app.slashingParams.SignedBlocksWindow
+	maxMissed := window - app.slashingParams.MinSignedPerWindow.MulInt64(window).TruncateInt64()
+
+	votes = append([]types.VoteInfo(nil), votes...)
+	sort.Slice(votes, func(i, j int) bool {
+		return bytes.Compare(votes[i].Validator.Address, votes[j].Validator.Address) < 0
+	})
+	for _, vote := range votes {
+		validator, found := app.validators.GetByAddress(vote.Validator.Address)
+		if !found {
+			continue
+		}
+		address := string(validator.Address)
+		missed := !vote.SignedLastBlock
+
+		signingInfo, found := app.signingInfos[address]
+		if !found {
+			signingInfo = ValidatorSigningInfo{Address: validator.Address, StartHeight: height}
+		}
+
+		missedBlocks := app.missedBlocks[address]
+		if int64(len(missedBlocks)) != window {
+			missedBlocks = make([]bool, window)
+			app.missedBlocks[address] = missedBlocks
+		}
+
+		// Replace the oldest block of the window
+		index := signingInfo.IndexOffset % window
+		signingInfo.IndexOffset++
+		if missedBlocks[index] && !missed {
+			signingInfo.MissedBlocksCounter--
+		} else if !missedBlocks[index] && missed {
+			signingInfo.MissedBlocksCounter++
+		}
+		missedBlocks[index] = missed
+		app.signingInfos[address] = signingInfo
+
+		// The last validator is never jailed, since a empty set could never pass consensus
+		minHeight := signingInfo.StartHeight + window
+		if height <= minHeight || signingInfo.MissedBlocksCounter <= maxMissed || app.validators.Size() == 1 {
+			continue
+		}
+
+		app.slashAndJail(validator.Address, app.slashingParams.SlashFractionDowntime, app.blockTime.Add(app.slashingParams.DowntimeJailDuration))
+		app.resetSigningWindow(validator.Address, height)
+	}
+}
+
+// applyUnjails returns the unjailed validators to the set, their window starts again at the current height
+func (app *App) applyUnjails(height int64) {
+	for _, validator := range app.unjailed {
+		if !app.IsJailed(validator.Address) {
+			continue
+		}
+		delete(app.jailed.validators, string(validator.Address))
+		app.validators.validators[string(validator.Address)] = validator
+		app.resetSigningWindow(validator.Address, height)
+	}
+}
+
+// resetSigningWindow clears the missed blocks of a validator and starts its window at the height
+func (app *App) resetSigningWindow(validatorAddr []byte, height int64) {
+	signingInfo := app.signingInfo(validatorAddr)
+	signingInfo.StartHeight = height
+	signingInfo.IndexOffset = 0
+	signingInfo.MissedBlocksCounter = 0
+	app.signingInfos[string(validatorAddr)] = signingInfo
+	delete(app.missedBlocks, string(validatorAddr))
+}
+
+// resetMissedBlocks clears the missed blocks of every validator, used when the window size changes
+func (app *App) resetMissedBlocks() {
+	for address, signingInfo := range app.signingInfos {
+		signingInfo.IndexOffset = 0
+		signingInfo.MissedBlocksCounter = 0
+		app.signingInfos[address] = signingInfo
+	}
+	app.missedBlocks = make(map[string][]bool)
+}
+
+// signingInfo returns the liveness info of a validator, a new one starts at the committed height
+func (app *App) signingInfo(validatorAddr []byte) ValidatorSigningInfo {
+	signingInfo, found := app.signingInfos[string(validatorAddr)]
+	if !found {
+		signingInfo = ValidatorSigningInfo{Address: validatorAddr, StartHeight: app.state.Height}
+	}
+	return signingInfo
+}
@@ -0,0 +1,258 @@
+package abci_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	sdkmath "cosmossdk.io/math"
+	"github.com/cometbft/cometbft/abci/types"
+	cmtproto "github.com/cometbft/cometbft/proto/tendermint/types"
+	"github.com/stretchr/testify/require"
+
+	"ibc-fee/abci"
+)
+
+var genesisTime = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// TestDowntimeJail tests that a validator that doesn't vote is jailed after a full window
+// and that it can unjail after the downtime period
+func TestDowntimeJail(t *testing.T) {
+	app := newLivenessApp(t)
+	offender := app.GetValidators()[3]
+
+	// The offender never votes, it can't be jailed before a full window
+	// The signatures of a block are tracked on the BeginBlock of the next one
+	for height := int64(1); height <= 12; height++ {
+		runBlock(t, app, atHeight(height), withVotes(3))
+		require.False(t, app.IsJailed(offender.Address))
+	}
+	signingInfo, found := app.GetSigningInfo(offender.Address)
+	require.True(t, found)
+	require.Equal(t, int64(1), signingInfo.StartHeight)
+	require.Equal(t, int64(10), signingInfo.MissedBlocksCounter)
+
+	// Past the window the offender is slashed and jailed, it is removed from the CometBFT set
+	res := runBlock(t, app, atHeight(13), withVotes(3))
+	require.Equal(t, []types.ValidatorUpdate{{PubKey: offender.PubKey, Power: 0}}, res.ValidatorUpdates)
+	require.True(t, app.IsJailed(offender.Address))
+	require.Equal(t, []abci.Validator{withPower(offender, 99)}, app.GetJailedValidators())
+	require.Len(t, app.GetValidators(), 3)
+	signingInfo, _ = app.GetSigningInfo(offender.Address)
+	require.Equal(t, blockTime(13).Add(time.Minute), signingInfo.JailedUntil)
+	require.Zero(t, signingInfo.MissedBlocksCounter)
+
+	// The remaining validators keep consensus
+	runBlock(t, app, atHeight(14), withVotes(3))
+	require.Equal(t, int64(14), app.GetState().Height)
+
+	// The offender can't unjail before the downtime period, nor return with a update
+	runBlock(t, app, atHeight(15), withVotes(3), duringBlock(func() {
+		require.ErrorIs(t, app.Unjail(offender.Address), abci.ErrValidatorJailed)
+		require.ErrorIs(t, app.Unjail(app.GetValidators()[0].Address), abci.ErrValidatorNotJailed)
+		update := types.ValidatorUpdate{PubKey: offender.PubKey, Power: 100}
+		require.ErrorIs(t, app.UpdateValidator(update), abci.ErrValidatorJailed)
+	}))
+
+	// After the downtime period the offender returns on EndBlock with its slashed power
+	unjailTime := signingInfo.JailedUntil
+	res = runBlock(t, app, withHeader(cmtproto.Header{Height: 16, Time: unjailTime}), withVotes(3), duringBlock(func() {
+		require.NoError(t, app.Unjail(offender.Address))
+		require.True(t, app.IsJailed(offender.Address))
+	}))
+	require.Equal(t, []types.ValidatorUpdate{{PubKey: offender.PubKey, Power: 99}}, res.ValidatorUpdates)
+	require.Equal(t, int64(16), app.GetState().Height)
+
+	require.False(t, app.IsJailed(offender.Address))
+	signingInfo, _ = app.GetSigningInfo(offender.Address)
+	require.Equal(t, app.GetState().Height, signingInfo.StartHeight)
+	require.Contains(t, app.GetValidators(), withPower(offender, 99))
+}
+
+// TestDowntimeJailThenDoubleSign tests that a validator jailed for downtime is still slashed and tombstoned
+// by the evidence of a double sign made before it went offline
+func TestDowntimeJailThenDoubleSign(t *testing.T) {
+	app := newLivenessApp(t)
+	offender := app.GetValidators()[3]
+
+	// The offender double signs and then goes offline until it is jailed for downtime
+	runBlock(t, app, atHeight(1), withDoubleSign(offender))
+	evidence := app.PendingEvidence()
+	require.Len(t, evidence, 1)
+	for height := int64(2); height <= 14; height++ {
+		runBlock(t, app, atHeight(height), withVotes(3))
+	}
+	require.Equal(t, []abci.Validator{withPower(offender, 99)}, app.GetJailedValidators())
+	signingInfo, _ := app.GetSigningInfo(offender.Address)
+	require.False(t, signingInfo.Tombstoned)
+
+	// The evidence slashes and tombstones the jailed offender
+	res := runBlock(t, app, atHeight(15), withVotes(3), withEvidence(evidence))
+	require.Empty(t, res.ValidatorUpdates)
+	require.Equal(t, []abci.Validator{withPower(offender, 95)}, app.GetJailedValidators())
+	signingInfo, _ = app.GetSigningInfo(offender.Address)
+	require.True(t, signingInfo.Tombstoned)
+	require.Equal(t, abci.DoubleSignJailEndTime, signingInfo.JailedUntil)
+	require.Empty(t, app.PendingEvidence())
+
+	// The offender can't unjail after the downtime period, and the same evidence isn't slashed twice
+	runBlock(t, app, withHeader(cmtproto.Header{Height: 16, Time: blockTime(1000)}), withVotes(3), withEvidence(evidence), duringBlock(func() {
+		require.ErrorIs(t, app.Unjail(offender.Address), abci.ErrValidatorTombstoned)
+	}))
+	require.Equal(t, []abci.Validator{withPower(offender, 95)}, app.GetJailedValidators())
+}
+
+// TestUnjailZeroPower tests that a validator slashed to zero power for downtime can't return to the set
+func TestUnjailZeroPower(t *testing.T) {
+	app := newLivenessApp(t)
+	params := abci.DefaultSlashingParams()
+	params.SignedBlocksWindow = 10
+	params.DowntimeJailDuration = time.Minute
+	params.SlashFractionDowntime = sdkmath.LegacyOneDec()
+	require.NoError(t, app.SetSlashingParams(params))
+	offender := app.GetValidators()[3]
+
+	for height := int64(1); height <= 13; height++ {
+		runBlock(t, app, atHeight(height), withVotes(3))
+	}
+	require.Equal(t, []abci.Validator{withPower(offender, 0)}, app.GetJailedValidators())
+
+	signingInfo, _ := app.GetSigningInfo(offender.Address)
+	res := runBlock(t, app, withHeader(cmtproto.Header{Height: 14, Time: signingInfo.JailedUntil}), withVotes(3), duringBlock(func() {
+		require.ErrorIs(t, app.Unjail(offender.Address), abci.ErrInvalidValidator)
+	}))
+	require.Empty(t, res.ValidatorUpdates)
+	require.True(t, app.IsJailed(offender.Address))
+	require.NotContains(t, app.GetValidators(), withPower(offender, 0))
+	require.Len(t, app.GetValidators(), 3)
+}
+
+// TestLivenessSlidingWindow tests that the missed blocks only count inside the window
+func TestLivenessSlidingWindow(t *testing.T) {
+	app := newLivenessApp(t)
+	offender := app.GetValidators()[3]
+
+	// Missing every other block stays at the max missed blocks of the window
+	// The last block is tracked on the next BeginBlock
+	for height := int64(1); height <= 40; height++ {
+		votes := 3
+		if height%2 == 0 {
+			votes = 4
+		}
+		runBlock(t, app, atHeight(height), withVotes(votes))
+	}
+	require.False(t, app.IsJailed(offender.Address))
+	signingInfo, _ := app.GetSigningInfo(offender.Address)
+	require.Equal(t, int64(5), signingInfo.MissedBlocksCounter)
+	require.Equal(t, int64(39), signingInfo.IndexOffset)
+
+	// Voting on the whole window clears the missed blocks
+	for height := int64(41); height <= 50; height++ {
+		runBlock(t, app, atHeight(height), withVotes(4))
+	}
+	signingInfo, _ = app.GetSigningInfo(offender.Address)
+	require.Zero(t, signingInfo.MissedBlocksCounter)
+}
+
+// TestDoubleSignTombstoned tests that a double signer can never unjail
+func TestDoubleSignTombstoned(t *testing.T) {
+	app := newLivenessApp(t)
+	offender := app.GetValidators()[0]
+
+	app.BeginBlock(types.RequestBeginBlock{})
+	voteOnApp(4, app)
+	require.Error(t, app.Vote(offender.Address, []byte("conflicting")))
+	app.EndBlock(types.RequestEndBlock{})
+	app.ProcessVotes()
+	app.Commit()
+
+	app.BeginBlock(types.RequestBeginBlock{
+		Header:              cmtproto.Header{Time: blockTime(1000)},
+		ByzantineValidators: app.PendingEvidence(),
+	})
+	signingInfo, _ := app.GetSigningInfo(offender.Address)
+	require.True(t, signingInfo.Tombstoned)
+	require.Equal(t, abci.DoubleSignJailEndTime, signingInfo.JailedUntil)
+	err := app.Unjail(offender.Address)
+	require.ErrorIs(t, err, abci.ErrValidatorTombstoned)
+}
+
+// TestLivenessFromLastCommitInfo tests that the liveness is tracked with the LastCommitInfo of BeginBlock
+// as sent by a real CometBFT driver, which never calls Vote nor ProcessVotes
+func TestLivenessFromLastCommitInfo(t *testing.T) {
+	app := newLivenessApp(t)
+	validators := app.GetValidators()
+	offender := validators[3]
+
+	// Every validator signs on more blocks than the window, nobody is jailed
+	for height := int64(1); height <= 25; height++ {
+		runBlock(t, app, atHeight(height), withCommitInfo(lastCommitInfo(height, validators, nil)))
+	}
+	for _, validator := range validators {
+		require.False(t, app.IsJailed(validator.Address))
+		signingInfo, found := app.GetSigningInfo(validator.Address)
+		require.True(t, found)
+		require.Zero(t, signingInfo.MissedBlocksCounter)
+	}
+	require.Equal(t, int64(25), app.GetState().Height)
+
+	// A validator missing on the LastCommitInfo is jailed after the window
+	for height := int64(26); height <= 36; height++ {
+		runBlock(t, app, atHeight(height), withCommitInfo(lastCommitInfo(height, validators, offender.Address)))
+	}
+	require.True(t, app.IsJailed(offender.Address))
+	require.Len(t, app.GetValidators(), 3)
+}
+
+// TestUnjailOutsideBlock tests that the unjails and the validator updates can't be queued outside of a open block
+func TestUnjailOutsideBlock(t *testing.T) {
+	app := newLivenessApp(t)
+	validator := app.GetValidators()[0]
+	update := types.ValidatorUpdate{PubKey: validator.PubKey, Power: 10}
+
+	require.ErrorIs(t, app.Unjail(validator.Address), abci.ErrBlockNotOpen)
+	require.ErrorIs(t, app.UpdateValidator(update), abci.ErrBlockNotOpen)
+
+	// After EndBlock the changes would never be applied
+	app.BeginBlock(types.RequestBeginBlock{})
+	voteOnApp(4, app)
+	app.EndBlock(types.RequestEndBlock{})
+	require.ErrorIs(t, app.Unjail(validator.Address), abci.ErrBlockNotOpen)
+	require.ErrorIs(t, app.UpdateValidator(update), abci.ErrBlockNotOpen)
+	require.True(t, app.ProcessVotes())
+	app.Commit()
+	require.ErrorIs(t, app.UpdateValidator(update), abci.ErrBlockNotOpen)
+}
+
+// lastCommitInfo returns the signatures of the block before the height, as sent by CometBFT
+// Every validator signed the last block except the missing one, the first block has no last commit
+func lastCommitInfo(height int64, validators []abci.Validator, missing []byte) types.CommitInfo {
+	if height == 1 {
+		return types.CommitInfo{}
+	}
+	votes := make([]types.VoteInfo, len(validators))
+	for i, validator := range validators {
+		votes[i] = types.VoteInfo{
+			Validator:       types.Validator{Address: validator.Address, Power: validator.Power},
+			SignedLastBlock: !bytes.Equal(validator.Address, missing),
+		}
+	}
+	return types.CommitInfo{Votes: votes}
+}
+
+// newLivenessApp returns a app with 4 validators and a window of 10 blocks that must have half of the votes
+func newLivenessApp(t *testing.T) *abci.App {
+	app := newApp(t, newValidatorsWithPower(4, 100), abci.NewThreshold(66, 100))
+
+	params := abci.DefaultSlashingParams()
+	params.SignedBlocksWindow = 10
+	params.MinSignedPerWindow = sdkmath.LegacyNewDecWithPrec(5, 1)
+	params.DowntimeJailDuration = time.Minute
+	require.NoError(t, app.SetSlashingParams(params))
+	return app
+}
+
+// blockTime returns the time of a block, blocks are 5 seconds apart
+func blockTime(height int64) time.Time {
+	return genesisTime.Add(time.Duration(height) * 5 * time.Second)
+}
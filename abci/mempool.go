@@ -0,0 +1,99 @@
+package abci
+
+import (
+	"sort"
+
+	errorsmod "cosmossdk.io/errors"
+)
+
+// MempoolMode defines the order in which the TXs are reaped from the mempool
+type MempoolMode int
+
+const (
+	// MempoolFIFO reaps the TXs in the order they were added
+	MempoolFIFO MempoolMode = iota
+	// MempoolPriority reaps the TXs with the highest priority first, ties are reaped in the order they were added
+	MempoolPriority
+)
+
+// Mempool is a simple in memory mempool of the TXs accepted by CheckTx
+type Mempool struct {
+	mode MempoolMode
+	txs  map[string]mempoolTx
+	// nextSeq is the insertion order of the next TX
+	nextSeq uint64
+}
+
+// mempoolTx is a TX on the mempool with its priority and insertion order
+type mempoolTx struct {
+	tx       []byte
+	priority int64
+	seq      uint64
+}
+
+// NewMempool returns a new empty mempool
+func NewMempool(mode MempoolMode) *Mempool {
+	return &Mempool{
+		mode: mode,
+		txs:  make(map[string]mempoolTx),
+	}
+}
+
+// Insert adds a TX to the mempool, the priority is only used by the priority mode
+func (mp *Mempool) Insert(tx []byte, priority int64) error {
+	txHash := HashTx(tx)
+	if mp.Has(txHash) {
+		return errorsmod.Wrap(ErrDuplicateTx, "tx already in mempool")
+	}
+
+	mp.txs[txHash] = mempoolTx{tx: tx, priority: priority, seq: mp.nextSeq}
+	mp.nextSeq += 1
+	return nil
+}
+
+// Has returns true if the TX hash is on the mempool
+func (mp *Mempool) Has(txHash string) bool {
+	_, found := mp.txs[txHash]
+	return found
+}
+
+// Remove removes a TX hash from the mempool
+func (mp *Mempool) Remove(txHash string) {
+	delete(mp.txs, txHash)
+}
+
+// Size returns the amount of TXs on the mempool
+func (mp *Mempool) Size() int {
+	return len(mp.txs)
+}
+
+// ReapMaxBytes returns the TXs on the mempool order up to a total of max bytes, without removing them
+// As in CometBFT, the reap stops at the first TX that doesn't fit and a negative max returns every TX
+func (mp *Mempool) ReapMaxBytes(maxBytes int64) [][]byte {
+	var totalBytes int64
+	txs := [][]byte{}
+	for _, memTx := range mp.sortedTxs() {
+		if maxBytes >= 0 && totalBytes+int64(len(memTx.tx)) > maxBytes {
+			break
+		}
+		totalBytes += int64(len(memTx.tx))
+		txs = append(txs, memTx.tx)
+	}
+	return txs
+}
+
+// sortedTxs returns the TXs on the reap order of the mempool mode
+func (mp *Mempool) sortedTxs() []mempoolTx {
+	txs := make([]mempoolTx, 0, len(mp.txs))
+	for _, memTx := range mp.txs {
+		txs = append(txs, memTx)
+	}
+
+	sort.Slice(txs, func(i, j int) bool {
+		if mp.mode == MempoolPriority && txs[i].priority != txs[j].priority {
+			return txs[i].priority > txs[j].priority
+		}
+		return txs[i].seq < txs[j].seq
+	})
+	return txs
+}
@@ -0,0 +1,53 @@
+package abci_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"ibc-fee/abci"
+)
+
+// TestMempoolFIFO tests that the FIFO mempool reaps the TXs in insertion order
+func TestMempoolFIFO(t *testing.T) {
+	mp := abci.NewMempool(abci.MempoolFIFO)
+	require.NoError(t, mp.Insert([]byte("tx1"), 1))
+	require.NoError(t, mp.Insert([]byte("tx2"), 3))
+	require.NoError(t, mp.Insert([]byte("tx3"), 2))
+
+	require.Equal(t, [][]byte{[]byte("tx1"), []byte("tx2"), []byte("tx3")}, mp.ReapMaxBytes(-1))
+	require.Equal(t, 3, mp.Size())
+
+	// Duplicates are rejected
+	require.ErrorIs(t, mp.Insert([]byte("tx1"), 1), abci.ErrDuplicateTx)
+}
+
+// TestMempoolPriority tests that the priority mempool reaps the highest priority first and ties in insertion order
+func TestMempoolPriority(t *testing.T) {
+	mp := abci.NewMempool(abci.MempoolPriority)
+	require.NoError(t, mp.Insert([]byte("tx1"), 1))
+	require.NoError(t, mp.Insert([]byte("tx2"), 3))
+	require.NoError(t, mp.Insert([]byte("tx3"), 2))
+	require.NoError(t, mp.Insert([]byte("tx4"), 3))
+
+	expected := [][]byte{[]byte("tx2"), []byte("tx4"), []byte("tx3"), []byte("tx1")}
+	require.Equal(t, expected, mp.ReapMaxBytes(-1))
+}
+
+// TestMempoolReapMaxBytes tests that the reap stops at the first TX that doesn't fit
+func TestMempoolReapMaxBytes(t *testing.T) {
+	mp := abci.NewMempool(abci.MempoolFIFO)
+	require.NoError(t, mp.Insert([]byte("aaa"), 0))
+	require.NoError(t, mp.Insert([]byte("bbbbb"), 0))
+	require.NoError(t, mp.Insert([]byte("c"), 0))
+
+	require.Empty(t, mp.ReapMaxBytes(0))
+	require.Equal(t, [][]byte{[]byte("aaa")}, mp.ReapMaxBytes(7))
+	require.Equal(t, [][]byte{[]byte("aaa"), []byte("bbbbb")}, mp.ReapMaxBytes(8))
+	require.Len(t, mp.ReapMaxBytes(9), 3)
+
+	// Reaping doesn't remove the TXs
+	require.Equal(t, 3, mp.Size())
+	mp.Remove(abci.HashTx([]byte("aaa")))
+	require.Equal(t, [][]byte{[]byte("bbbbb")}, mp.ReapMaxBytes(5))
+}
@@ -0,0 +1,152 @@
+package abci
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"github.com/cometbft/cometbft/crypto/merkle"
+	ics23 "github.com/cosmos/ics23/go"
+)
+
+// ProofOpSimpleMerkleCommitment is the proof op type of the state proofs, the same used by the Cosmos-SDK
+const ProofOpSimpleMerkleCommitment = "ics23:simple"
+
+// ComputeAppHash computes the Merkle root of the state data
+// The keys are sorted, so the root only depends on the content and not on the insertion order
+// The tree is the CometBFT simple Merkle tree, the same as the ICS23 TendermintSpec and the Cosmos-SDK multistore root
+// Its cost is O(state): every leaf and inner node is hashed again, even if the block wrote a single key
+// The tree can't be updated incrementally, a new key shifts the position of the later leaves and so every inner node above them
+// Only a different tree, such as a IAVL tree, would hash the dirty keys alone, but it changes the app hash and the proof format
+func ComputeAppHash(data map[string][]byte) []byte {
+	keys := sortedKeys(data)
+
+	leaves := make([][]byte, len(keys))
+	for i, key := range keys {
+		leaves[i] = kvLeaf([]byte(key), data[key])
+	}
+
+	return merkle.HashFromByteSlices(leaves)
+}
+
+// CreateMembershipProof returns the ICS23 proof that a key exists on the data
+// The proof can be verified against the app hash with the ICS23 TendermintSpec
+func CreateMembershipProof(data map[string][]byte, key []byte) (*ics23.CommitmentProof, error) {
+	exist, err := createExistenceProof(data, sortedKeys(data), key)
+	if err != nil {
+		return nil, err
+	}
+	return &ics23.CommitmentProof{Proof: &ics23.CommitmentProof_Exist{Exist: exist}}, nil
+}
+
+// CreateNonMembershipProof returns the ICS23 proof that a key doesn't exist on the data
+// The proof contains the existence proofs of the closest keys on the left and right
+func CreateNonMembershipProof(data map[string][]byte, key []byte) (*ics23.CommitmentProof, error) {
+	if _, found := data[string(key)]; found {
+		return nil, fmt.Errorf("key %X exists on the state", key)
+	}
+
+	keys := sortedKeys(data)
+	nonExist := &ics23.NonExistenceProof{Key: key}
+
+	// The key would be inserted at this index, so the neighbors are around it
+	idx := sort.SearchStrings(keys, string(key))
+	if idx > 0 {
+		left, err := createExistenceProof(data, keys, []byte(keys[idx-1]))
+		if err != nil {
+			return nil, err
+		}
+		nonExist.Left = left
+	}
+	if idx < len(keys) {
+		right, err := createExistenceProof(data, keys, []byte(keys[idx]))
+		if err != nil {
+			return nil, err
+		}
+		nonExist.Right = right
+	}
+
+	return &ics23.CommitmentProof{Proof: &ics23.CommitmentProof_Nonexist{Nonexist: nonExist}}, nil
+}
+
+// createExistenceProof converts the CometBFT Merkle proof of a key into a ICS23 existence proof
+func createExistenceProof(data map[string][]byte, keys []string, key []byte) (*ics23.ExistenceProof, error) {
+	value, found := data[string(key)]
+	if !found {
+		return nil, fmt.Errorf("key %X doesn't exist on the state", key)
+	}
+
+	leaves := make([][]byte, len(keys))
+	for i, k := range keys {
+		leaves[i] = kvLeaf([]byte(k), data[k])
+	}
+	_, proofs := merkle.ProofsFromByteSlices(leaves)
+	idx := sort.SearchStrings(keys, string(key))
+	proof := proofs[idx]
+
+	// The aunts go from the leaf to the root, as the ICS23 path
+	path := buildPath(proof.Index, proof.Total)
+	innerOps := make([]*ics23.InnerOp, len(proof.Aunts))
+	for i, aunt := range proof.Aunts {
+		innerOp := &ics23.InnerOp{Hash: ics23.HashOp_SHA256}
+		if path[i] {
+			// The node is on the left, so the sibling goes after it
+			innerOp.Prefix = []byte{1}
+			innerOp.Suffix = aunt
+		} else {
+			innerOp.Prefix = append([]byte{1}, aunt...)
+		}
+		innerOps[i] = innerOp
+	}
+
+	return &ics23.ExistenceProof{
+		Key:   key,
+		Value: value,
+		Leaf:  ics23.TendermintSpec.LeafSpec,
+		Path:  innerOps,
+	}, nil
+}
+
+// buildPath returns if the node is the left child on each level, from the leaf to the root
+func buildPath(idx, total int64) []bool {
+	if total < 2 {
+		return nil
+	}
+
+	numLeft := splitPoint(total)
+	if idx < numLeft {
+		return append(buildPath(idx, numLeft), true)
+	}
+	return append(buildPath(idx-numLeft, total-numLeft), false)
+}
+
+// splitPoint returns the largest power of 2 less than length, as the CometBFT Merkle tree
+func splitPoint(length int64) int64 {
+	point := int64(1)
+	for point*2 < length {
+		point *= 2
+	}
+	return point
+}
+
+// kvLeaf encodes a key value pair as the leaf of the tree
+// Following the TendermintSpec leaf, the value is hashed and both fields are prefixed by their varint length
+func kvLeaf(key, value []byte) []byte {
+	valueHash := sha256.Sum256(value)
+
+	leaf := binary.AppendUvarint(nil, uint64(len(key)))
+	leaf = append(leaf, key...)
+	leaf = binary.AppendUvarint(leaf, uint64(len(valueHash)))
+	return append(leaf, valueHash[:]...)
+}
+
+// sortedKeys returns the keys of the data on ascending order
+func sortedKeys(data map[string][]byte) []string {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
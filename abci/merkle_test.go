@@ -0,0 +1,68 @@
+package abci_test
+
+import (
+	"testing"
+
+	ics23 "github.com/cosmos/ics23/go"
+	"github.com/stretchr/testify/require"
+
+	"ibc-fee/abci"
+)
+
+// TestComputeAppHashICS23Leaf tests that a single entry root matches the ICS23 TendermintSpec leaf
+func TestComputeAppHashICS23Leaf(t *testing.T) {
+	key, value := []byte("key"), []byte("value")
+
+	expected, err := ics23.TendermintSpec.LeafSpec.Apply(key, value)
+	require.NoError(t, err)
+	require.Equal(t, expected, abci.ComputeAppHash(map[string][]byte{string(key): value}))
+}
+
+// TestComputeAppHashDeterministic tests that the root doesn't depend on the insertion order
+func TestComputeAppHashDeterministic(t *testing.T) {
+	dataA := make(map[string][]byte)
+	dataB := make(map[string][]byte)
+	for i := byte(0); i < 100; i++ {
+		dataA[string([]byte{i})] = []byte{i, i}
+		dataB[string([]byte{99 - i})] = []byte{99 - i, 99 - i}
+	}
+
+	require.Equal(t, abci.ComputeAppHash(dataA), abci.ComputeAppHash(dataB))
+
+	// Changing a value changes the root
+	dataB[string([]byte{50})] = []byte{0}
+	require.NotEqual(t, abci.ComputeAppHash(dataA), abci.ComputeAppHash(dataB))
+}
+
+// TestMembershipProofs tests the proofs of every key on trees of different sizes
+func TestMembershipProofs(t *testing.T) {
+	for size := 1; size <= 33; size++ {
+		data := make(map[string][]byte)
+		for i := 0; i < size; i++ {
+			// Use even keys, so the odd keys can be used as missing keys
+			data[string([]byte{byte(i * 2)})] = []byte{byte(i)}
+		}
+		root := abci.ComputeAppHash(data)
+
+		for key, value := range data {
+			proof, err := abci.CreateMembershipProof(data, []byte(key))
+			require.NoError(t, err)
+			require.True(t, ics23.VerifyMembership(ics23.TendermintSpec, root, proof, []byte(key), value), "size %d", size)
+
+			// Existing keys have no non membership proof
+			_, err = abci.CreateNonMembershipProof(data, []byte(key))
+			require.Error(t, err)
+		}
+
+		// Missing keys before, between and after the existing keys
+		for i := -1; i < size; i++ {
+			missingKey := []byte{byte(i*2 + 1)}
+			if i < 0 {
+				missingKey = []byte{}
+			}
+			proof, err := abci.CreateNonMembershipProof(data, missingKey)
+			require.NoError(t, err)
+			require.True(t, ics23.VerifyNonMembership(ics23.TendermintSpec, root, proof, missingKey), "size %d", size)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package abci
+
+import (
+	"sort"
+
+	"github.com/cometbft/cometbft/abci/types"
+)
+
+// PrepareProposalHandler selects and orders the TXs of a block proposed by this node
+type PrepareProposalHandler func(app *App, req types.RequestPrepareProposal) types.ResponsePrepareProposal
+
+// ProcessProposalHandler accepts or rejects a block proposed by another node
+type ProcessProposalHandler func(app *App, req types.RequestProcessProposal) types.ResponseProcessProposal
+
+// DefaultProposalHandler is the default proposal logic of the app
+// Proposed TXs must pass the TX validators, as on a recheck, and fit on the block bytes
+type DefaultProposalHandler struct {
+	// maxBlockTxBytes is the max amount of TX bytes accepted on a processed proposal, zero disables the check
+	// The prepared proposals always use the max bytes given by CometBFT
+	maxBlockTxBytes int64
+}
+
+// NewDefaultProposalHandler returns the default proposal handler with the max TX bytes of a block
+func NewDefaultProposalHandler(maxBlockTxBytes int64) *DefaultProposalHandler {
+	return &DefaultProposalHandler{maxBlockTxBytes: maxBlockTxBytes}
+}
+
+// PrepareProposalHandler returns the handler that builds the proposal
+// Invalid and duplicated TXs are dropped, TXs are ordered by priority and added while they fit on the max bytes
+// A TX that doesn't fit is skipped, so smaller TXs after it can still be added
+func (h *DefaultProposalHandler) PrepareProposalHandler() PrepareProposalHandler {
+	return func(app *App, req types.RequestPrepareProposal) types.ResponsePrepareProposal {
+		// Sort by priority, keeping the CometBFT order for ties
+		txs := make([][]byte, len(req.Txs))
+		copy(txs, req.Txs)
+		if app.txPriority != nil {
+			sort.SliceStable(txs, func(i, j int) bool {
+				return app.txPriority(txs[i]) > app.txPriority(txs[j])
+			})
+		}
+
+		var totalBytes int64
+		seenTxs := make(map[string]bool)
+		selectedTxs := [][]byte{}
+		for _, tx := range txs {
+			txHash := HashTx(tx)
+			if seenTxs[txHash] || app.validateTx(tx) != nil {
+				continue
+			}
+			if totalBytes+int64(len(tx)) > req.MaxTxBytes {
+				continue
+			}
+
+			seenTxs[txHash] = true
+			totalBytes += int64(len(tx))
+			selectedTxs = append(selectedTxs, tx)
+		}
+
+		return types.ResponsePrepareProposal{Txs: selectedTxs}
+	}
+}
+
+// ProcessProposalHandler returns the handler that validates the proposal
+// The block is rejected if any TX is invalid or duplicated, or if the block is bigger than the max bytes
+func (h *DefaultProposalHandler) ProcessProposalHandler() ProcessProposalHandler {
+	return func(app *App, req types.RequestProcessProposal) types.ResponseProcessProposal {
+		var totalBytes int64
+		seenTxs := make(map[string]bool)
+		for _, tx := range req.Txs {
+			txHash := HashTx(tx)
+			if seenTxs[txHash] || app.validateTx(tx) != nil {
+				return rejectProposal()
+			}
+			seenTxs[txHash] = true
+			totalBytes += int64(len(tx))
+		}
+
+		if h.maxBlockTxBytes > 0 && totalBytes > h.maxBlockTxBytes {
+			return rejectProposal()
+		}
+
+		return types.ResponseProcessProposal{Status: types.ResponseProcessProposal_ACCEPT}
+	}
+}
+
+// NoOpPrepareProposal returns a handler that proposes the TXs given by CometBFT as they are
+func NoOpPrepareProposal() PrepareProposalHandler {
+	return func(_ *App, req types.RequestPrepareProposal) types.ResponsePrepareProposal {
+		return types.ResponsePrepareProposal{Txs: req.Txs}
+	}
+}
+
+// NoOpProcessProposal returns a handler that accepts every proposal
+func NoOpProcessProposal() ProcessProposalHandler {
+	return func(_ *App, _ types.RequestProcessProposal) types.ResponseProcessProposal {
+		return types.ResponseProcessProposal{Status: types.ResponseProcessProposal_ACCEPT}
+	}
+}
+
+// SetPrepareProposal sets the handler used by PrepareProposal
+func (app *App) SetPrepareProposal(handler PrepareProposalHandler) {
+	app.prepareProposal = handler
+}
+
+// SetProcessProposal sets the handler used by ProcessProposal
+func (app *App) SetProcessProposal(handler ProcessProposalHandler) {
+	app.processProposal = handler
+}
+
+// PrepareProposal returns the TXs of the block proposed by this node
+func (app *App) PrepareProposal(req types.RequestPrepareProposal) types.ResponsePrepareProposal {
+	return app.prepareProposal(app, req)
+}
+
+// ProcessProposal accepts or rejects a block proposed by another node
+func (app *App) ProcessProposal(req types.RequestProcessProposal) types.ResponseProcessProposal {
+	return app.processProposal(app, req)
+}
+
+// rejectProposal returns a rejected proposal response
+func rejectProposal() types.ResponseProcessProposal {
+	return types.ResponseProcessProposal{Status: types.ResponseProcessProposal_REJECT}
+}
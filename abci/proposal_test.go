@@ -0,0 +1,136 @@
+package abci_test
+
+import (
+	"testing"
+
+	"github.com/cometbft/cometbft/abci/types"
+	"github.com/stretchr/testify/require"
+
+	"ibc-fee/abci"
+)
+
+// TestPrepareProposal tests that the default handler drops invalid, duplicated and oversized TXs
+func TestPrepareProposal(t *testing.T) {
+	app := newApp(t, newValidators(4), abci.NewThreshold(66, 100))
+	app.SetTxValidators(abci.MaxTxSizeValidator(5), abci.DuplicateTxValidator())
+	runBlock(t, app, withVotes(4), withTxs([]byte("tx0")))
+
+	res := app.PrepareProposal(types.RequestPrepareProposal{
+		MaxTxBytes: 9,
+		Txs: [][]byte{
+			[]byte("tx0"),          // Already committed
+			[]byte("tx1"),          // Valid
+			[]byte("tx_too_large"), // Bigger than the max TX size
+			[]byte("tx1"),          // Duplicated on the proposal
+			[]byte("tx22"),         // Valid
+			[]byte("tx3"),          // Doesn't fit on the block
+			[]byte("t4"),           // Fits after the skipped TX
+		},
+	})
+
+	require.Equal(t, [][]byte{[]byte("tx1"), []byte("tx22"), []byte("t4")}, res.Txs)
+}
+
+// TestPrepareProposalPriority tests that the default handler orders the TXs by priority
+func TestPrepareProposalPriority(t *testing.T) {
+	app := newApp(t, newValidators(4), abci.NewThreshold(66, 100))
+	app.SetTxPriority(func(tx []byte) int64 { return int64(tx[len(tx)-1] - '0') })
+
+	res := app.PrepareProposal(types.RequestPrepareProposal{
+		MaxTxBytes: 9,
+		Txs:        [][]byte{[]byte("tx1"), []byte("tx3"), []byte("tx2"), []byte("ty3")},
+	})
+
+	require.Equal(t, [][]byte{[]byte("tx3"), []byte("ty3"), []byte("tx2")}, res.Txs)
+}
+
+// TestProcessProposal tests that the default handler rejects invalid blocks
+func TestProcessProposal(t *testing.T) {
+	app := newApp(t, newValidators(4), abci.NewThreshold(66, 100))
+	app.SetTxValidators(abci.MaxTxSizeValidator(5), abci.DuplicateTxValidator())
+	handler := abci.NewDefaultProposalHandler(8)
+	app.SetProcessProposal(handler.ProcessProposalHandler())
+	runBlock(t, app, withVotes(4), withTxs([]byte("tx0")))
+
+	testCases := []struct {
+		name           string
+		txs            [][]byte
+		expectedStatus types.ResponseProcessProposal_ProposalStatus
+	}{
+		{
+			name:           "valid block",
+			txs:            [][]byte{[]byte("tx1"), []byte("tx2")},
+			expectedStatus: types.ResponseProcessProposal_ACCEPT,
+		},
+		{
+			name:           "empty block",
+			txs:            nil,
+			expectedStatus: types.ResponseProcessProposal_ACCEPT,
+		},
+		{
+			name:           "committed TX",
+			txs:            [][]byte{[]byte("tx0")},
+			expectedStatus: types.ResponseProcessProposal_REJECT,
+		},
+		{
+			name:           "duplicated TX",
+			txs:            [][]byte{[]byte("tx1"), []byte("tx1")},
+			expectedStatus: types.ResponseProcessProposal_REJECT,
+		},
+		{
+			name:           "oversized TX",
+			txs:            [][]byte{[]byte("tx_too_large")},
+			expectedStatus: types.ResponseProcessProposal_REJECT,
+		},
+		{
+			name:           "block bigger than the max bytes",
+			txs:            [][]byte{[]byte("tx1"), []byte("tx2"), []byte("tx3")},
+			expectedStatus: types.ResponseProcessProposal_REJECT,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res := app.ProcessProposal(types.RequestProcessProposal{Txs: tc.txs})
+			require.Equal(t, tc.expectedStatus, res.Status)
+		})
+	}
+}
+
+// TestProposalFromMempool tests a block prepared from the mempool TXs and processed by another app
+func TestProposalFromMempool(t *testing.T) {
+	proposer := newApp(t, newValidators(4), abci.NewThreshold(66, 100))
+	validator := newApp(t, newValidators(4), abci.NewThreshold(66, 100))
+
+	for _, tx := range []string{"tx1", "tx2", "tx3"} {
+		require.Equal(t, abci.CodeTypeOK, proposer.CheckTx(types.RequestCheckTx{Tx: []byte(tx)}).Code)
+	}
+
+	prepared := proposer.PrepareProposal(types.RequestPrepareProposal{
+		MaxTxBytes: 6,
+		Txs:        proposer.GetMempool().ReapMaxBytes(-1),
+	})
+	require.Len(t, prepared.Txs, 2)
+
+	processed := validator.ProcessProposal(types.RequestProcessProposal{Txs: prepared.Txs})
+	require.Equal(t, types.ResponseProcessProposal_ACCEPT, processed.Status)
+
+	// Both apps run the block and reach the same app hash
+	runBlock(t, proposer, withVotes(4), withTxs(prepared.Txs...))
+	runBlock(t, validator, withVotes(4), withTxs(prepared.Txs...))
+	require.Equal(t, proposer.GetState().AppHash, validator.GetState().AppHash)
+	require.Equal(t, 1, proposer.GetMempool().Size())
+}
+
+// TestNoOpProposalHandlers tests the handlers that pass the proposals through
+func TestNoOpProposalHandlers(t *testing.T) {
+	app := newApp(t, newValidators(4), abci.NewThreshold(66, 100))
+	app.SetPrepareProposal(abci.NoOpPrepareProposal())
+	app.SetProcessProposal(abci.NoOpProcessProposal())
+
+	txs := [][]byte{[]byte("tx1"), []byte("tx1")}
+	require.Equal(t, txs, app.PrepareProposal(types.RequestPrepareProposal{Txs: txs}).Txs)
+
+	res := app.ProcessProposal(types.RequestProcessProposal{Txs: txs})
+	require.Equal(t, types.ResponseProcessProposal_ACCEPT, res.Status)
+}
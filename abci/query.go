@@ -0,0 +1,125 @@
+package abci
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cometbft/cometbft/abci/types"
+	"github.com/cometbft/cometbft/proto/tendermint/crypto"
+	ics23 "github.com/cosmos/ics23/go"
+)
+
+// Query paths supported by the app
+const (
+	// QueryPathTx returns a TX by its hex encoded hash, as /tx/<hex hash>
+	QueryPathTx = "/tx/"
+	// QueryPathHeight returns the last committed height
+	QueryPathHeight = "/height"
+	// QueryPathStoreKey returns the value of the raw key given as the query data
+	QueryPathStoreKey = "/store/key"
+)
+
+// AppVersion is the version of the simulated app returned by Info
+const AppVersion uint64 = 1
+
+// Info returns the last committed height and app hash
+// CometBFT uses it on startup to know from which height the blocks must be replayed
+func (app *App) Info(types.RequestInfo) types.ResponseInfo {
+	return types.ResponseInfo{
+		Data:             "abci-simulation",
+		AppVersion:       AppVersion,
+		LastBlockHeight:  app.state.Height,
+		LastBlockAppHash: app.state.AppHash,
+	}
+}
+
+// Query reads the last committed state
+// With Prove set, the value comes with a ICS23 proof that can be verified against the app hash
+func (app *App) Query(req types.RequestQuery) types.ResponseQuery {
+	committed := app.state
+
+	// Only the last committed height is available
+	if req.Height != 0 && req.Height != committed.Height {
+		return queryError(CodeTypeInvalidQuery, fmt.Sprintf("height %d is not available, last height is %d", req.Height, committed.Height))
+	}
+
+	switch {
+	case req.Path == QueryPathHeight:
+		return types.ResponseQuery{
+			Code:   CodeTypeOK,
+			Value:  []byte(strconv.FormatInt(committed.Height, 10)),
+			Height: committed.Height,
+		}
+	case strings.HasPrefix(req.Path, QueryPathTx):
+		txHash, err := hex.DecodeString(strings.TrimPrefix(req.Path, QueryPathTx))
+		if err != nil {
+			return queryError(CodeTypeInvalidQuery, fmt.Sprintf("invalid tx hash: %s", err))
+		}
+		return queryKey(committed, txHash, req.Prove, true)
+	case req.Path == QueryPathStoreKey:
+		if len(req.Data) == 0 {
+			return queryError(CodeTypeInvalidQuery, "empty store key")
+		}
+		return queryKey(committed, req.Data, req.Prove, false)
+	default:
+		return queryError(CodeTypeUnknownPath, fmt.Sprintf("unknown query path %s", req.Path))
+	}
+}
+
+// queryKey returns the value of a key on the state with a optional proof
+// Missing TXs are reported as not found, missing store keys can be proven as non existent
+func queryKey(state *State, key []byte, prove bool, mustExist bool) types.ResponseQuery {
+	value, found := state.Data[string(key)]
+	if !found && mustExist {
+		return queryError(CodeTypeNotFound, fmt.Sprintf("tx %X not found", key))
+	}
+
+	res := types.ResponseQuery{
+		Code:   CodeTypeOK,
+		Key:    key,
+		Value:  value,
+		Height: state.Height,
+	}
+	if !prove {
+		return res
+	}
+
+	proof, err := createProof(state.Data, key, found)
+	if err != nil {
+		return queryError(CodeTypeInvalidQuery, err.Error())
+	}
+	res.ProofOps = proof
+	return res
+}
+
+// createProof returns the membership or non membership proof of a key as proof ops
+func createProof(data map[string][]byte, key []byte, exists bool) (*crypto.ProofOps, error) {
+	var commitmentProof *ics23.CommitmentProof
+	var err error
+	if exists {
+		commitmentProof, err = CreateMembershipProof(data, key)
+	} else {
+		commitmentProof, err = CreateNonMembershipProof(data, key)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	proofBz, err := commitmentProof.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	return &crypto.ProofOps{Ops: []crypto.ProofOp{{
+		Type: ProofOpSimpleMerkleCommitment,
+		Key:  key,
+		Data: proofBz,
+	}}}, nil
+}
+
+// queryError returns a failed query response
+func queryError(code uint32, log string) types.ResponseQuery {
+	return types.ResponseQuery{Code: code, Log: log}
+}
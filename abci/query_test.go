@@ -0,0 +1,124 @@
+package abci_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/cometbft/cometbft/abci/types"
+	ics23 "github.com/cosmos/ics23/go"
+	"github.com/stretchr/testify/require"
+
+	"ibc-fee/abci"
+)
+
+// TestInfo tests that Info returns the last committed height and app hash
+func TestInfo(t *testing.T) {
+	app := newApp(t, newValidators(4), abci.NewThreshold(66, 100))
+
+	res := app.Info(types.RequestInfo{})
+	require.Equal(t, int64(0), res.LastBlockHeight)
+	require.Empty(t, res.LastBlockAppHash)
+
+	runBlock(t, app, withVotes(4), withTxs([]byte("tx1")))
+	runBlock(t, app, withVotes(4), withTxs([]byte("tx2")))
+
+	res = app.Info(types.RequestInfo{})
+	require.Equal(t, int64(2), res.LastBlockHeight)
+	require.Equal(t, app.GetState().AppHash, res.LastBlockAppHash)
+	require.Equal(t, abci.AppVersion, res.AppVersion)
+}
+
+// TestQueryTx tests the TX lookup by hash with a proof against the app hash
+func TestQueryTx(t *testing.T) {
+	app := newApp(t, newValidators(4), abci.NewThreshold(66, 100))
+	runBlock(t, app, withVotes(4), withTxs([]byte("tx1"), []byte("tx2")))
+	appHash := app.Info(types.RequestInfo{}).LastBlockAppHash
+
+	txHash := []byte(abci.HashTx([]byte("tx1")))
+	res := app.Query(types.RequestQuery{Path: abci.QueryPathTx + hex.EncodeToString(txHash), Prove: true})
+	require.Equal(t, abci.CodeTypeOK, res.Code, res.Log)
+	require.Equal(t, []byte("tx1"), res.Value)
+	require.Equal(t, int64(1), res.Height)
+
+	// Verify the proof against the app hash
+	proof := decodeProof(t, res)
+	require.True(t, ics23.VerifyMembership(ics23.TendermintSpec, appHash, proof, txHash, res.Value))
+
+	// Unknown TXs
+	missingHash := []byte(abci.HashTx([]byte("missing")))
+	res = app.Query(types.RequestQuery{Path: abci.QueryPathTx + hex.EncodeToString(missingHash)})
+	require.Equal(t, abci.CodeTypeNotFound, res.Code)
+
+	// Invalid hashes
+	res = app.Query(types.RequestQuery{Path: abci.QueryPathTx + "not-hex"})
+	require.Equal(t, abci.CodeTypeInvalidQuery, res.Code)
+}
+
+// TestQueryStoreKey tests the raw key lookup with membership and non membership proofs
+func TestQueryStoreKey(t *testing.T) {
+	app := newApp(t, newValidators(4), abci.NewThreshold(66, 100))
+	runBlock(t, app, withVotes(4), withTxs([]byte("tx1"), []byte("tx2"), []byte("tx3")))
+	appHash := app.Info(types.RequestInfo{}).LastBlockAppHash
+
+	key := []byte(abci.HashTx([]byte("tx2")))
+	res := app.Query(types.RequestQuery{Path: abci.QueryPathStoreKey, Data: key, Prove: true})
+	require.Equal(t, abci.CodeTypeOK, res.Code, res.Log)
+	require.Equal(t, []byte("tx2"), res.Value)
+	require.True(t, ics23.VerifyMembership(ics23.TendermintSpec, appHash, decodeProof(t, res), key, res.Value))
+
+	// Missing keys are proven as non existent
+	missingKey := []byte("missing")
+	res = app.Query(types.RequestQuery{Path: abci.QueryPathStoreKey, Data: missingKey, Prove: true})
+	require.Equal(t, abci.CodeTypeOK, res.Code, res.Log)
+	require.Nil(t, res.Value)
+	require.True(t, ics23.VerifyNonMembership(ics23.TendermintSpec, appHash, decodeProof(t, res), missingKey))
+
+	// Without prove no proof is returned
+	res = app.Query(types.RequestQuery{Path: abci.QueryPathStoreKey, Data: key})
+	require.Nil(t, res.ProofOps)
+
+	// Empty keys are invalid
+	res = app.Query(types.RequestQuery{Path: abci.QueryPathStoreKey})
+	require.Equal(t, abci.CodeTypeInvalidQuery, res.Code)
+}
+
+// TestQueryCommittedState tests that queries only see the committed state
+func TestQueryCommittedState(t *testing.T) {
+	app := newApp(t, newValidators(4), abci.NewThreshold(66, 100))
+	runBlock(t, app, withVotes(4), withTxs([]byte("tx1")))
+
+	// Start a block with a new TX, but don't commit it
+	app.BeginBlock(types.RequestBeginBlock{})
+	app.DeliverTx(types.RequestDeliverTx{Tx: []byte("tx2")})
+
+	txHash := hex.EncodeToString([]byte(abci.HashTx([]byte("tx2"))))
+	res := app.Query(types.RequestQuery{Path: abci.QueryPathTx + txHash})
+	require.Equal(t, abci.CodeTypeNotFound, res.Code)
+
+	res = app.Query(types.RequestQuery{Path: abci.QueryPathHeight})
+	require.Equal(t, abci.CodeTypeOK, res.Code)
+	require.Equal(t, []byte("1"), res.Value)
+}
+
+// TestQueryErrors tests unknown paths and unavailable heights
+func TestQueryErrors(t *testing.T) {
+	app := newApp(t, newValidators(4), abci.NewThreshold(66, 100))
+	runBlock(t, app, withVotes(4), withTxs([]byte("tx1")))
+
+	res := app.Query(types.RequestQuery{Path: "/unknown"})
+	require.Equal(t, abci.CodeTypeUnknownPath, res.Code)
+
+	res = app.Query(types.RequestQuery{Path: abci.QueryPathHeight, Height: 5})
+	require.Equal(t, abci.CodeTypeInvalidQuery, res.Code)
+}
+
+// decodeProof decodes the ICS23 proof of a query response
+func decodeProof(t *testing.T, res types.ResponseQuery) *ics23.CommitmentProof {
+	require.NotNil(t, res.ProofOps)
+	require.Len(t, res.ProofOps.Ops, 1)
+	require.Equal(t, abci.ProofOpSimpleMerkleCommitment, res.ProofOps.Ops[0].Type)
+
+	var proof ics23.CommitmentProof
+	require.NoError(t, proof.Unmarshal(res.ProofOps.Ops[0].Data))
+	return &proof
+}
@@ -0,0 +1,45 @@
+package abci
+
+import (
+	errorsmod "cosmossdk.io/errors"
+)
+
+// SetRetainedHeights sets the amount of heights kept on the store, the older heights are pruned on Commit
+// Zero keeps every height, pruned heights can't be rolled back to
+func (app *App) SetRetainedHeights(retained uint64) {
+	app.store.SetRetainedVersions(retained)
+}
+
+// EarliestHeight returns the earliest height that can be rolled back to
+func (app *App) EarliestHeight() int64 {
+	return app.store.EarliestVersion()
+}
+
+// RollbackTo restores the state, the app hash, the validator set and the jail, liveness and evidence state of a retained height
+// The heights after it are deleted from the store, so the app continues from the height as if they never happened
+// It can't be called during a block
+func (app *App) RollbackTo(height int64) error {
+	if app.inBlock {
+		return errorsmod.Wrap(ErrInvalidRollback, "can't rollback during a block")
+	}
+	if height < app.store.EarliestVersion() || height > app.state.Height {
+		return errorsmod.Wrapf(ErrInvalidRollback, "height %d is not retained, heights are %d to %d",
+			height, app.store.EarliestVersion(), app.state.Height)
+	}
+
+	info, err := app.store.GetVersionInfo(height)
+	if err != nil {
+		return err
+	}
+	// Validate the version before the store is changed, loading it again can't fail
+	if _, err := NewValidatorSet(info.Validators); err != nil {
+		return err
+	}
+	if err := app.store.RollbackTo(height); err != nil {
+		return err
+	}
+
+	app.state = &State{Height: height, Data: app.store.data, AppHash: info.AppHash}
+	app.cache = NewCacheStore(app.store)
+	return app.loadVersionInfo(info)
+}
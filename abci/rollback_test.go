@@ -0,0 +1,199 @@
+package abci_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/cometbft/cometbft/abci/types"
+	"github.com/stretchr/testify/require"
+
+	"ibc-fee/abci"
+)
+
+// TestRollbackTo tests that a rollback restores the state, app hash and validators of a height
+// and that the app produces the same blocks again
+func TestRollbackTo(t *testing.T) {
+	for _, b := range backends(t) {
+		t.Run(b.name, func(t *testing.T) {
+			app, err := abci.NewAppWithDB(b.openDB(t), newValidators(4), abci.NewThreshold(66, 100))
+			require.NoError(t, err)
+
+			states, validators := runRollbackBlocks(t, app, 5)
+
+			require.NoError(t, app.RollbackTo(2))
+			require.Equal(t, int64(2), app.GetState().Height)
+			require.Equal(t, states[2].AppHash, app.GetState().AppHash)
+			require.Equal(t, states[2].Data, app.GetState().Data)
+			require.Equal(t, validators[2], app.GetValidators())
+
+			info := app.Info(types.RequestInfo{})
+			require.Equal(t, int64(2), info.LastBlockHeight)
+			require.Equal(t, states[2].AppHash, info.LastBlockAppHash)
+
+			// The rolled back TXs can be delivered again with the same hashes
+			for height := int64(3); height <= 5; height++ {
+				runBlock(t, app, rollbackBlock(t, height)...)
+				require.Equal(t, states[height].AppHash, app.GetState().AppHash)
+				require.Equal(t, validators[height], app.GetValidators())
+			}
+
+			// Rollback to the current height doesn't change the state
+			require.NoError(t, app.RollbackTo(5))
+			require.Equal(t, states[5].AppHash, app.GetState().AppHash)
+
+			// The rollback is persisted
+			require.NoError(t, app.RollbackTo(1))
+			require.NoError(t, app.Close())
+			reopened, err := abci.NewAppWithDB(b.openDB(t), nil, abci.NewThreshold(66, 100))
+			require.NoError(t, err)
+			require.Equal(t, int64(1), reopened.GetState().Height)
+			require.Equal(t, states[1].AppHash, reopened.GetState().AppHash)
+			require.Equal(t, states[1].Data, reopened.GetState().Data)
+			require.Equal(t, validators[1], reopened.GetValidators())
+			require.NoError(t, reopened.Close())
+		})
+	}
+}
+
+// TestRollbackAcrossJail tests that a rollback restores the jail, liveness and evidence state of the height
+func TestRollbackAcrossJail(t *testing.T) {
+	app := newApp(t, newValidatorsWithPower(4, 100), abci.NewThreshold(66, 100))
+	offender := app.GetValidators()[0]
+
+	// The offender double signs on the second block and is jailed on the third
+	runBlock(t, app, withTxs([]byte("tx1")))
+	runBlock(t, app, withDoubleSign(offender))
+	evidence := app.PendingEvidence()
+	require.Len(t, evidence, 1)
+	signingInfo, _ := app.GetSigningInfo(offender.Address)
+
+	runBlock(t, app, withEvidence(evidence))
+	require.True(t, app.IsJailed(offender.Address))
+	jailedHash := app.GetState().AppHash
+	jailedInfo, _ := app.GetSigningInfo(offender.Address)
+	require.True(t, jailedInfo.Tombstoned)
+	runBlock(t, app, withVotes(3), withTxs([]byte("tx4")))
+
+	// Rolling back to the jail height keeps the offender jailed and tombstoned
+	require.NoError(t, app.RollbackTo(3))
+	require.True(t, app.IsJailed(offender.Address))
+	require.Len(t, app.GetValidators(), 3)
+	restoredInfo, _ := app.GetSigningInfo(offender.Address)
+	require.Equal(t, jailedInfo, restoredInfo)
+	require.Empty(t, app.PendingEvidence())
+
+	// Rolling back before the jail returns the offender to the set with the evidence pending
+	require.NoError(t, app.RollbackTo(2))
+	require.False(t, app.IsJailed(offender.Address))
+	require.Empty(t, app.GetJailedValidators())
+	require.Contains(t, app.GetValidators(), offender)
+	restoredInfo, _ = app.GetSigningInfo(offender.Address)
+	require.Equal(t, signingInfo, restoredInfo)
+	require.Equal(t, evidence, app.PendingEvidence())
+
+	// Replaying the evidence jails the offender again with the same hash
+	runBlock(t, app, withEvidence(app.PendingEvidence()))
+	require.True(t, app.IsJailed(offender.Address))
+	require.Equal(t, jailedHash, app.GetState().AppHash)
+}
+
+// TestRollbackInvalid tests the heights that can't be rolled back to
+func TestRollbackInvalid(t *testing.T) {
+	app := newApp(t, newValidators(4), abci.NewThreshold(66, 100))
+	runRollbackBlocks(t, app, 3)
+
+	require.ErrorIs(t, app.RollbackTo(0), abci.ErrInvalidRollback)
+	require.ErrorIs(t, app.RollbackTo(4), abci.ErrInvalidRollback)
+
+	app.BeginBlock(types.RequestBeginBlock{})
+	require.ErrorIs(t, app.RollbackTo(2), abci.ErrInvalidRollback)
+	app.EndBlock(types.RequestEndBlock{})
+	app.Commit()
+}
+
+// TestRetainedHeights tests that only the retained heights can be rolled back to
+// and that pruning keeps the values still used by them
+func TestRetainedHeights(t *testing.T) {
+	for _, b := range backends(t) {
+		t.Run(b.name, func(t *testing.T) {
+			app, err := abci.NewAppWithDB(b.openDB(t), newValidators(4), abci.NewThreshold(66, 100))
+			require.NoError(t, err)
+			app.SetRetainedHeights(2)
+
+			states, validators := runRollbackBlocks(t, app, 6)
+			require.Equal(t, int64(5), app.EarliestHeight())
+			require.ErrorIs(t, app.RollbackTo(4), abci.ErrInvalidRollback)
+
+			// The TX of the first block was written before the pruned heights, it is still on the retained ones
+			require.NoError(t, app.RollbackTo(5))
+			require.Equal(t, states[5].AppHash, app.GetState().AppHash)
+			require.Equal(t, states[5].Data, app.GetState().Data)
+			require.Equal(t, validators[5], app.GetValidators())
+			require.Contains(t, app.GetState().Data, abci.HashTx([]byte("tx1")))
+
+			require.NoError(t, app.Close())
+			reopened, err := abci.NewAppWithDB(b.openDB(t), nil, abci.NewThreshold(66, 100))
+			require.NoError(t, err)
+			require.Equal(t, int64(5), reopened.EarliestHeight())
+			require.Equal(t, states[5].Data, reopened.GetState().Data)
+			require.NoError(t, reopened.Close())
+		})
+	}
+}
+
+// TestVersionedStorePrune tests that the pruned versions can't be read
+func TestVersionedStorePrune(t *testing.T) {
+	store := newVersionedStore(t, 0)
+	store.SetRetainedVersions(2)
+
+	for version := 1; version <= 4; version++ {
+		store.Set("a", []byte(fmt.Sprint(version)))
+		if version == 1 {
+			store.Set("b", []byte("1"))
+		}
+		_, err := store.Commit(abci.VersionInfo{})
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, int64(3), store.EarliestVersion())
+	_, _, err := store.GetVersioned("a", 2)
+	require.ErrorIs(t, err, abci.ErrStore)
+	_, err = store.GetVersionInfo(2)
+	require.ErrorIs(t, err, abci.ErrStore)
+
+	value, found, err := store.GetVersioned("a", 3)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, []byte("3"), value)
+	value, found, err = store.GetVersioned("b", 3)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, []byte("1"), value)
+}
+
+// runRollbackBlocks runs the blocks up to the height, returning the state and validators of each height
+func runRollbackBlocks(t *testing.T, app *abci.App, lastHeight int64) (map[int64]abci.State, map[int64][]abci.Validator) {
+	states := make(map[int64]abci.State)
+	validators := make(map[int64][]abci.Validator)
+	for height := int64(1); height <= lastHeight; height++ {
+		runBlock(t, app, rollbackBlock(t, height)...)
+
+		state := app.GetState()
+		data := make(map[string][]byte, len(state.Data))
+		for key, value := range state.Data {
+			data[key] = value
+		}
+		states[height] = abci.State{Height: state.Height, Data: data, AppHash: state.AppHash}
+		validators[height] = app.GetValidators()
+	}
+	return states, validators
+}
+
+// rollbackBlock returns the options of the block of the height, with a TX for the height, the third block adds a validator
+func rollbackBlock(t *testing.T, height int64) []blockOption {
+	opts := []blockOption{withVotes(4), withTxs([]byte(fmt.Sprintf("tx%d", height)))}
+	if height == 3 {
+		opts = append(opts, withUpdates(validatorUpdate(t, 4, 1)))
+	}
+	return opts
+}
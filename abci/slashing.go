@@ -0,0 +1,196 @@
+package abci
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"time"
+
+	errorsmod "cosmossdk.io/errors"
+	sdkmath "cosmossdk.io/math"
+	"github.com/cometbft/cometbft/abci/types"
+)
+
+// DoubleSignJailEndTime is the jail end time of the double signers, they are jailed forever
+var DoubleSignJailEndTime = time.Unix(253402300799, 0).UTC()
+
+// SlashingParams are the params of the validator punishments
+type SlashingParams struct {
+	// SlashFractionDoubleSign is the fraction of the power slashed from a validator that votes for two blocks
+	SlashFractionDoubleSign sdkmath.LegacyDec
+	// SignedBlocksWindow is the amount of blocks of the sliding window used to track the liveness
+	SignedBlocksWindow int64
+	// MinSignedPerWindow is the min fraction of the window blocks that a validator must vote
+	MinSignedPerWindow sdkmath.LegacyDec
+	// DowntimeJailDuration is the time a validator jailed for downtime waits before it can unjail
+	DowntimeJailDuration time.Duration
+	// SlashFractionDowntime is the fraction of the power slashed from a validator jailed for downtime
+	SlashFractionDowntime sdkmath.LegacyDec
+}
+
+// DefaultSlashingParams returns the default slashing params, with the Cosmos-SDK default values
+func DefaultSlashingParams() SlashingParams {
+	return SlashingParams{
+		SlashFractionDoubleSign: sdkmath.LegacyNewDecWithPrec(5, 2),
+		SignedBlocksWindow:      100,
+		MinSignedPerWindow:      sdkmath.LegacyNewDecWithPrec(5, 1),
+		DowntimeJailDuration:    10 * time.Minute,
+		SlashFractionDowntime:   sdkmath.LegacyNewDecWithPrec(1, 2),
+	}
+}
+
+// Validate checks that the fractions are between zero and one and that the window and duration are positive
+func (p SlashingParams) Validate() error {
+	if !isValidFraction(p.SlashFractionDoubleSign) {
+		return errorsmod.Wrapf(ErrInvalidSlashingParams, "double sign slash fraction must be in [0, 1], got %s", p.SlashFractionDoubleSign)
+	}
+	if p.SignedBlocksWindow <= 0 {
+		return errorsmod.Wrapf(ErrInvalidSlashingParams, "signed blocks window must be positive, got %d", p.SignedBlocksWindow)
+	}
+	if !isValidFraction(p.MinSignedPerWindow) {
+		return errorsmod.Wrapf(ErrInvalidSlashingParams, "min signed per window must be in [0, 1], got %s", p.MinSignedPerWindow)
+	}
+	if p.DowntimeJailDuration <= 0 {
+		return errorsmod.Wrapf(ErrInvalidSlashingParams, "downtime jail duration must be positive, got %s", p.DowntimeJailDuration)
+	}
+	if !isValidFraction(p.SlashFractionDowntime) {
+		return errorsmod.Wrapf(ErrInvalidSlashingParams, "downtime slash fraction must be in [0, 1], got %s", p.SlashFractionDowntime)
+	}
+	return nil
+}
+
+// SetSlashingParams replaces the slashing params
+// Changing the window resets the missed blocks of every validator
+func (app *App) SetSlashingParams(params SlashingParams) error {
+	if err := params.Validate(); err != nil {
+		return err
+	}
+	if params.SignedBlocksWindow != app.slashingParams.SignedBlocksWindow {
+		app.resetMissedBlocks()
+	}
+	app.slashingParams = params
+	return nil
+}
+
+// PendingEvidence returns the evidence of misbehaviors not yet included on a committed block, sorted by height and address
+// The evidence is fed to the next BeginBlock as its ByzantineValidators, as CometBFT does
+func (app *App) PendingEvidence() []types.Misbehavior {
+	evidence := make([]types.Misbehavior, 0, len(app.evidencePool))
+	for _, misbehavior := range app.evidencePool {
+		evidence = append(evidence, misbehavior)
+	}
+	sort.Slice(evidence, func(i, j int) bool {
+		if evidence[i].Height != evidence[j].Height {
+			return evidence[i].Height < evidence[j].Height
+		}
+		return bytes.Compare(evidence[i].Validator.Address, evidence[j].Validator.Address) < 0
+	})
+	return evidence
+}
+
+// GetJailedValidators returns the jailed validators with their slashed power, sorted by address
+func (app *App) GetJailedValidators() []Validator {
+	return app.jailed.Validators()
+}
+
+// IsJailed returns true if the validator was jailed
+func (app *App) IsJailed(validatorAddr []byte) bool {
+	_, found := app.jailed.GetByAddress(validatorAddr)
+	return found
+}
+
+// addDuplicateVoteEvidence adds the evidence of a validator that voted for two blocks on the current height
+func (app *App) addDuplicateVoteEvidence(validator Validator) {
+	height := app.state.Height + 1
+	key := evidenceKey(validator.Address, height)
+	if _, found := app.evidencePool[key]; found {
+		return
+	}
+
+	app.evidencePool[key] = types.Misbehavior{
+		Type:             types.MisbehaviorType_DUPLICATE_VOTE,
+		Validator:        types.Validator{Address: validator.Address, Power: validator.Power},
+		Height:           height,
+		TotalVotingPower: app.validators.TotalPower(),
+	}
+}
+
+// handleEvidence slashes and jails the validators of the duplicate vote evidence
+// Double signers are tombstoned, so they can never unjail
+// Validators already jailed for downtime are slashed and tombstoned too, so going offline doesn't escape the punishment
+// Evidence of unknown or already tombstoned validators is ignored
+func (app *App) handleEvidence(evidence []types.Misbehavior) {
+	for _, misbehavior := range evidence {
+		if misbehavior.Type != types.MisbehaviorType_DUPLICATE_VOTE {
+			continue
+		}
+		address := misbehavior.Validator.Address
+		if app.signingInfo(address).Tombstoned {
+			continue
+		}
+
+		if app.IsJailed(address) {
+			app.slashJailed(address, app.slashingParams.SlashFractionDoubleSign, DoubleSignJailEndTime)
+		} else if !app.slashAndJail(address, app.slashingParams.SlashFractionDoubleSign, DoubleSignJailEndTime) {
+			continue
+		}
+
+		signingInfo := app.signingInfo(address)
+		signingInfo.Tombstoned = true
+		app.signingInfos[string(address)] = signingInfo
+	}
+}
+
+// slashJailed removes the slashed fraction of the power of a jailed validator and extends its jail until the given time
+func (app *App) slashJailed(validatorAddr []byte, slashFraction sdkmath.LegacyDec, jailedUntil time.Time) {
+	validator, found := app.jailed.GetByAddress(validatorAddr)
+	if !found {
+		return
+	}
+	validator.Power -= sdkmath.LegacyNewDec(validator.Power).Mul(slashFraction).TruncateInt64()
+	app.jailed.validators[string(validator.Address)] = validator
+
+	signingInfo := app.signingInfo(validatorAddr)
+	signingInfo.JailedUntil = jailedUntil
+	app.signingInfos[string(validatorAddr)] = signingInfo
+}
+
+// slashAndJail removes the slashed fraction of the power of a validator and moves it out of the validator set
+// The validator stays jailed until the given time, it returns true if the validator was jailed
+// The last validator is slashed but not jailed, since a empty set could never pass consensus
+func (app *App) slashAndJail(validatorAddr []byte, slashFraction sdkmath.LegacyDec, jailedUntil time.Time) bool {
+	validator, found := app.validators.GetByAddress(validatorAddr)
+	if !found {
+		return false
+	}
+
+	slashedPower := sdkmath.LegacyNewDec(validator.Power).Mul(slashFraction).TruncateInt64()
+	validator.Power -= slashedPower
+
+	if app.validators.Size() == 1 {
+		// Keep at least one power so the set stays valid
+		if validator.Power <= 0 {
+			validator.Power = 1
+		}
+		app.validators.validators[string(validator.Address)] = validator
+		return false
+	}
+
+	delete(app.validators.validators, string(validator.Address))
+	app.jailed.validators[string(validator.Address)] = validator
+
+	signingInfo := app.signingInfo(validatorAddr)
+	signingInfo.JailedUntil = jailedUntil
+	app.signingInfos[string(validatorAddr)] = signingInfo
+	return true
+}
+
+// isValidFraction returns true if the fraction is between zero and one
+func isValidFraction(fraction sdkmath.LegacyDec) bool {
+	return !fraction.IsNil() && !fraction.IsNegative() && fraction.LTE(sdkmath.LegacyOneDec())
+}
+
+// evidenceKey returns the key of a evidence on the pool
+func evidenceKey(validatorAddr []byte, height int64) string {
+	return fmt.Sprintf("%X/%d", validatorAddr, height)
+}
@@ -0,0 +1,162 @@
+package abci_test
+
+import (
+	"testing"
+
+	sdkmath "cosmossdk.io/math"
+	"github.com/cometbft/cometbft/abci/types"
+	"github.com/stretchr/testify/require"
+
+	"ibc-fee/abci"
+)
+
+// TestDoubleSignEvidence tests that a double vote is slashed and jailed on the next block
+// and that the remaining validators keep reaching consensus
+func TestDoubleSignEvidence(t *testing.T) {
+	app := newApp(t, newValidatorsWithPower(4, 100), abci.NewThreshold(66, 100))
+	offender := app.GetValidators()[0]
+
+	// The offender votes for the block and for a conflicting hash
+	app.BeginBlock(types.RequestBeginBlock{})
+	voteOnApp(4, app)
+	err := app.Vote(offender.Address, []byte("conflicting"))
+	require.ErrorIs(t, err, abci.ErrDuplicateVote)
+
+	// Voting again doesn't duplicate the evidence
+	err = app.Vote(offender.Address, []byte("other conflicting"))
+	require.ErrorIs(t, err, abci.ErrDuplicateVote)
+	app.EndBlock(types.RequestEndBlock{})
+	require.True(t, app.ProcessVotes())
+	app.Commit()
+
+	evidence := app.PendingEvidence()
+	require.Len(t, evidence, 1)
+	require.Equal(t, types.MisbehaviorType_DUPLICATE_VOTE, evidence[0].Type)
+	require.Equal(t, offender.Address, evidence[0].Validator.Address)
+	require.Equal(t, int64(100), evidence[0].Validator.Power)
+	require.Equal(t, int64(1), evidence[0].Height)
+	require.Equal(t, int64(400), evidence[0].TotalVotingPower)
+
+	// The evidence is included on the next block, the offender is slashed and jailed
+	app.BeginBlock(types.RequestBeginBlock{ByzantineValidators: evidence})
+	require.True(t, app.IsJailed(offender.Address))
+	require.Equal(t, []abci.Validator{withPower(offender, 95)}, app.GetJailedValidators())
+	require.Len(t, app.GetValidators(), 3)
+
+	// The offender can't vote anymore and can't return with a update
+	err = app.Vote(offender.Address, nil)
+	require.ErrorIs(t, err, abci.ErrUnknownValidator)
+	err = app.UpdateValidator(types.ValidatorUpdate{PubKey: offender.PubKey, Power: 100})
+	require.ErrorIs(t, err, abci.ErrValidatorTombstoned)
+
+	// 2 of the 3 remaining validators pass the threshold, the offender is removed from the CometBFT set
+	voteOnApp(2, app)
+	res := app.EndBlock(types.RequestEndBlock{})
+	require.Equal(t, []types.ValidatorUpdate{{PubKey: offender.PubKey, Power: 0}}, res.ValidatorUpdates)
+	require.True(t, app.ProcessVotes())
+	app.Commit()
+
+	require.Equal(t, int64(2), app.GetState().Height)
+	require.Empty(t, app.PendingEvidence())
+
+	// The remaining set keeps working on the next blocks
+	runBlock(t, app, withVotes(2), withTxs([]byte("tx1")))
+	require.Equal(t, int64(3), app.GetState().Height)
+}
+
+// TestDoubleSignRejectedBlock tests that a rejected block undoes the punishment and keeps the evidence
+func TestDoubleSignRejectedBlock(t *testing.T) {
+	app := newApp(t, newValidatorsWithPower(4, 100), abci.NewThreshold(66, 100))
+	offender := app.GetValidators()[0]
+
+	app.BeginBlock(types.RequestBeginBlock{})
+	voteOnApp(4, app)
+	require.Error(t, app.Vote(offender.Address, []byte("conflicting")))
+	app.EndBlock(types.RequestEndBlock{})
+	app.ProcessVotes()
+	app.Commit()
+
+	// The block with the evidence is rejected
+	app.BeginBlock(types.RequestBeginBlock{ByzantineValidators: app.PendingEvidence()})
+	require.True(t, app.IsJailed(offender.Address))
+	voteOnApp(1, app)
+	app.EndBlock(types.RequestEndBlock{})
+	require.False(t, app.ProcessVotes())
+	app.Commit()
+
+	require.False(t, app.IsJailed(offender.Address))
+	require.Len(t, app.GetValidators(), 4)
+	require.Len(t, app.PendingEvidence(), 1)
+}
+
+// TestDoubleSignLastValidator tests that the last validator is slashed but stays on the set
+func TestDoubleSignLastValidator(t *testing.T) {
+	app := newApp(t, newValidatorsWithPower(1, 100), abci.NewThreshold(66, 100))
+	offender := app.GetValidators()[0]
+
+	app.BeginBlock(types.RequestBeginBlock{})
+	voteOnApp(1, app)
+	require.Error(t, app.Vote(offender.Address, []byte("conflicting")))
+	app.EndBlock(types.RequestEndBlock{})
+	app.ProcessVotes()
+	app.Commit()
+
+	app.BeginBlock(types.RequestBeginBlock{ByzantineValidators: app.PendingEvidence()})
+	require.False(t, app.IsJailed(offender.Address))
+	require.Equal(t, []abci.Validator{withPower(offender, 95)}, app.GetValidators())
+	voteOnApp(1, app)
+	app.EndBlock(types.RequestEndBlock{})
+	require.True(t, app.ProcessVotes())
+	app.Commit()
+}
+
+// TestSlashingParams tests the validation of the slashing params
+func TestSlashingParams(t *testing.T) {
+	require.NoError(t, abci.DefaultSlashingParams().Validate())
+
+	testCases := []struct {
+		name     string
+		malleate func(params *abci.SlashingParams)
+	}{
+		{name: "double sign fraction above one", malleate: func(p *abci.SlashingParams) { p.SlashFractionDoubleSign = sdkmath.LegacyNewDec(2) }},
+		{name: "negative double sign fraction", malleate: func(p *abci.SlashingParams) { p.SlashFractionDoubleSign = sdkmath.LegacyNewDec(-1) }},
+		{name: "nil double sign fraction", malleate: func(p *abci.SlashingParams) { p.SlashFractionDoubleSign = sdkmath.LegacyDec{} }},
+		{name: "zero window", malleate: func(p *abci.SlashingParams) { p.SignedBlocksWindow = 0 }},
+		{name: "min signed above one", malleate: func(p *abci.SlashingParams) { p.MinSignedPerWindow = sdkmath.LegacyNewDec(2) }},
+		{name: "zero jail duration", malleate: func(p *abci.SlashingParams) { p.DowntimeJailDuration = 0 }},
+		{name: "negative downtime fraction", malleate: func(p *abci.SlashingParams) { p.SlashFractionDowntime = sdkmath.LegacyNewDec(-1) }},
+	}
+
+	app := newApp(t, newValidatorsWithPower(4, 100), abci.NewThreshold(66, 100))
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			params := abci.DefaultSlashingParams()
+			tc.malleate(&params)
+			require.ErrorIs(t, app.SetSlashingParams(params), abci.ErrInvalidSlashingParams)
+		})
+	}
+
+	// Slash half of the power
+	params := abci.DefaultSlashingParams()
+	params.SlashFractionDoubleSign = sdkmath.LegacyNewDecWithPrec(5, 1)
+	require.NoError(t, app.SetSlashingParams(params))
+	offender := app.GetValidators()[0]
+	app.BeginBlock(types.RequestBeginBlock{})
+	voteOnApp(4, app)
+	require.Error(t, app.Vote(offender.Address, []byte("conflicting")))
+	app.EndBlock(types.RequestEndBlock{})
+	app.ProcessVotes()
+	app.Commit()
+
+	app.BeginBlock(types.RequestBeginBlock{ByzantineValidators: app.PendingEvidence()})
+	require.Equal(t, []abci.Validator{withPower(offender, 50)}, app.GetJailedValidators())
+}
+
+// newValidatorsWithPower returns validators with the same power
+func newValidatorsWithPower(total int, power int64) []abci.Validator {
+	validators := newValidators(total)
+	for i := range validators {
+		validators[i].Power = power
+	}
+	return validators
+}
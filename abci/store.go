@@ -0,0 +1,432 @@
+package abci
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"sort"
+
+	errorsmod "cosmossdk.io/errors"
+	dbm "github.com/cometbft/cometbft-db"
+	"github.com/cometbft/cometbft/abci/types"
+)
+
+// Prefixes of the keys of the versioned store on the database
+var (
+	// latestVersionKey has the last committed version
+	latestVersionKey = []byte("l")
+	// dataPrefix has the values of each key by version: d/<uvarint len key><key><big endian version>
+	dataPrefix = []byte("d/")
+	// versionPrefix has the info of each version: v/<big endian version>
+	versionPrefix = []byte("v/")
+	// earliestVersionKey has the earliest retained version
+	earliestVersionKey = []byte("e")
+	// changesPrefix indexes the keys written on each version: c/<big endian version><key>
+	changesPrefix = []byte("c/")
+)
+
+// Markers of the values on the database, so a deleted key can be told apart from a empty value
+const (
+	valueDeleted byte = iota
+	valueSet
+)
+
+// VersionInfo is the info committed with each version of the store
+// Besides the validator set, it has the jail, liveness and evidence state, so a reopened app punishes the same validators
+// The lists are sorted by address, so every node writes the same info
+type VersionInfo struct {
+	// AppHash is the app hash of the version
+	AppHash []byte `json:"app_hash"`
+	// Validators is the validator set that votes on the next block
+	Validators []Validator `json:"validators"`
+	// Jailed are the jailed validators with their slashed power
+	Jailed []Validator `json:"jailed,omitempty"`
+	// SigningInfos are the liveness info of the validators, with their jail end time and tombstone
+	SigningInfos []ValidatorSigningInfo `json:"signing_infos,omitempty"`
+	// MissedBlocks are the missed blocks windows of the validators
+	MissedBlocks []ValidatorMissedBlocks `json:"missed_blocks,omitempty"`
+	// Evidence is the evidence pool, not yet included on a committed block, sorted by height and address
+	Evidence []types.Misbehavior `json:"evidence,omitempty"`
+	// LastCommitVotes are the votes counted by ProcessVotes on the version block, tracked on the next block
+	LastCommitVotes []types.VoteInfo `json:"last_commit_votes,omitempty"`
+}
+
+// VersionedStore is a key value store backed by a cometbft-db database that keeps one version per height
+// Writes change the working values in memory and are persisted as the next version on Commit
+// Each version only writes the changed keys, the working values are kept in memory
+// so the app hash can be computed without reading the database
+type VersionedStore struct {
+	db      dbm.DB
+	version int64
+	data    map[string][]byte
+	// pending are the keys written since the last commit, a nil value is a deleted key
+	pending map[string][]byte
+	// earliest is the earliest retained version, older versions were pruned
+	earliest int64
+	// retained is the amount of versions kept after a commit, zero keeps every version
+	retained uint64
+}
+
+// Assert the interface, the block cache is layered over the store
+var _ KVStore = (*VersionedStore)(nil)
+
+// NewVersionedStore opens the store on the database, loading the last committed version
+// A new database starts at version zero
+func NewVersionedStore(db dbm.DB) (*VersionedStore, error) {
+	store := &VersionedStore{db: db, data: make(map[string][]byte), pending: make(map[string][]byte)}
+
+	bz, err := db.Get(latestVersionKey)
+	if err != nil {
+		return nil, errorsmod.Wrapf(ErrStore, "failed to read the latest version: %s", err)
+	}
+	if bz != nil {
+		store.version = int64(binary.BigEndian.Uint64(bz))
+	}
+
+	bz, err = db.Get(earliestVersionKey)
+	if err != nil {
+		return nil, errorsmod.Wrapf(ErrStore, "failed to read the earliest version: %s", err)
+	}
+	store.earliest = 1
+	if bz != nil {
+		store.earliest = int64(binary.BigEndian.Uint64(bz))
+	}
+
+	if err := store.loadData(store.version); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// Version returns the last committed version
+func (s *VersionedStore) Version() int64 {
+	return s.version
+}
+
+// EarliestVersion returns the earliest retained version
+func (s *VersionedStore) EarliestVersion() int64 {
+	return s.earliest
+}
+
+// SetRetainedVersions sets the amount of versions kept, older versions are pruned on the next Commit
+// Zero keeps every version
+func (s *VersionedStore) SetRetainedVersions(retained uint64) {
+	s.retained = retained
+}
+
+// Get returns the working value of a key
+func (s *VersionedStore) Get(key string) ([]byte, bool) {
+	value, found := s.data[key]
+	return value, found
+}
+
+// Set writes the working value of a key, a nil value is stored as empty
+func (s *VersionedStore) Set(key string, value []byte) {
+	if value == nil {
+		value = []byte{}
+	}
+	s.data[key] = value
+	s.pending[key] = value
+}
+
+// Delete deletes the working value of a key
+func (s *VersionedStore) Delete(key string) {
+	delete(s.data, key)
+	s.pending[key] = nil
+}
+
+// Data returns a copy of the working values
+func (s *VersionedStore) Data() map[string][]byte {
+	data := make(map[string][]byte, len(s.data))
+	for key, value := range s.data {
+		data[key] = value
+	}
+	return data
+}
+
+// GetVersioned returns the value of a key on a committed version
+func (s *VersionedStore) GetVersioned(key string, version int64) ([]byte, bool, error) {
+	if version < s.earliest || version > s.version {
+		return nil, false, errorsmod.Wrapf(ErrStore, "version %d is not retained, versions are %d to %d", version, s.earliest, s.version)
+	}
+
+	// The last write of the key up to the version is the first entry of a reverse iteration
+	prefix := dataKeyPrefix(key)
+	iterator, err := s.db.ReverseIterator(dataKey(prefix, 0), dataKey(prefix, version+1))
+	if err != nil {
+		return nil, false, errorsmod.Wrapf(ErrStore, "failed to read key: %s", err)
+	}
+	defer iterator.Close()
+
+	if !iterator.Valid() {
+		return nil, false, nil
+	}
+	value := iterator.Value()
+	if value[0] == valueDeleted {
+		return nil, false, nil
+	}
+	return value[1:], true, nil
+}
+
+// GetVersionInfo returns the info committed with a version
+func (s *VersionedStore) GetVersionInfo(version int64) (VersionInfo, error) {
+	var info VersionInfo
+	bz, err := s.db.Get(versionKey(version))
+	if err != nil {
+		return info, errorsmod.Wrapf(ErrStore, "failed to read version %d: %s", version, err)
+	}
+	if bz == nil {
+		return info, errorsmod.Wrapf(ErrStore, "version %d is not committed", version)
+	}
+	if err := json.Unmarshal(bz, &info); err != nil {
+		return info, errorsmod.Wrapf(ErrStore, "failed to decode version %d: %s", version, err)
+	}
+	return info, nil
+}
+
+// Commit persists the writes since the last commit as the next version
+// The writes and the version info are written on a single batch, so a crash never leaves a partial version
+func (s *VersionedStore) Commit(info VersionInfo) (int64, error) {
+	version := s.version + 1
+
+	batch := s.db.NewBatch()
+	defer batch.Close()
+
+	// Sort the keys so every node writes the same batch
+	keys := make([]string, 0, len(s.pending))
+	for key := range s.pending {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := []byte{valueDeleted}
+		if s.pending[key] != nil {
+			value = append([]byte{valueSet}, s.pending[key]...)
+		}
+		if err := batch.Set(dataKey(dataKeyPrefix(key), version), value); err != nil {
+			return 0, errorsmod.Wrapf(ErrStore, "failed to write key: %s", err)
+		}
+		if err := batch.Set(changeKey(version, key), []byte{}); err != nil {
+			return 0, errorsmod.Wrapf(ErrStore, "failed to index key: %s", err)
+		}
+	}
+
+	bz, err := json.Marshal(info)
+	if err != nil {
+		return 0, errorsmod.Wrapf(ErrStore, "failed to encode version %d: %s", version, err)
+	}
+	if err := batch.Set(versionKey(version), bz); err != nil {
+		return 0, errorsmod.Wrapf(ErrStore, "failed to write version %d: %s", version, err)
+	}
+	if err := batch.Set(latestVersionKey, encodeVersion(version)); err != nil {
+		return 0, errorsmod.Wrapf(ErrStore, "failed to write the latest version: %s", err)
+	}
+	if err := batch.WriteSync(); err != nil {
+		return 0, errorsmod.Wrapf(ErrStore, "failed to commit version %d: %s", version, err)
+	}
+
+	s.pending = make(map[string][]byte)
+	s.version = version
+
+	if err := s.prune(); err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+// RollbackTo deletes the versions after the given version, which becomes the latest
+// The version must be retained, the working values are reloaded and the uncommitted writes are lost
+func (s *VersionedStore) RollbackTo(version int64) error {
+	if version < s.earliest || version > s.version {
+		return errorsmod.Wrapf(ErrInvalidRollback, "version %d is not retained, versions are %d to %d", version, s.earliest, s.version)
+	}
+
+	batch := s.db.NewBatch()
+	defer batch.Close()
+
+	for deleted := version + 1; deleted <= s.version; deleted++ {
+		keys, err := s.changedKeys(deleted)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err := batch.Delete(dataKey(dataKeyPrefix(key), deleted)); err != nil {
+				return errorsmod.Wrapf(ErrStore, "failed to delete key: %s", err)
+			}
+			if err := batch.Delete(changeKey(deleted, key)); err != nil {
+				return errorsmod.Wrapf(ErrStore, "failed to delete key index: %s", err)
+			}
+		}
+		if err := batch.Delete(versionKey(deleted)); err != nil {
+			return errorsmod.Wrapf(ErrStore, "failed to delete version %d: %s", deleted, err)
+		}
+	}
+	if err := batch.Set(latestVersionKey, encodeVersion(version)); err != nil {
+		return errorsmod.Wrapf(ErrStore, "failed to write the latest version: %s", err)
+	}
+	if err := batch.WriteSync(); err != nil {
+		return errorsmod.Wrapf(ErrStore, "failed to rollback to version %d: %s", version, err)
+	}
+
+	s.version = version
+	s.pending = make(map[string][]byte)
+	return s.loadData(version)
+}
+
+// Close closes the database
+func (s *VersionedStore) Close() error {
+	return s.db.Close()
+}
+
+// prune deletes the versions older than the retained ones
+// When a version becomes the earliest, the older values of the keys it wrote are not needed anymore,
+// the values of the keys it didn't write are kept since they are still its values
+func (s *VersionedStore) prune() error {
+	if s.retained == 0 || s.version-int64(s.retained)+1 <= s.earliest {
+		return nil
+	}
+	earliest := s.version - int64(s.retained) + 1
+
+	batch := s.db.NewBatch()
+	defer batch.Close()
+
+	for version := s.earliest + 1; version <= earliest; version++ {
+		keys, err := s.changedKeys(version)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err := s.deleteOlderValues(batch, key, version); err != nil {
+				return err
+			}
+		}
+
+		// The pruned version and its index
+		pruned := version - 1
+		prunedKeys, err := s.changedKeys(pruned)
+		if err != nil {
+			return err
+		}
+		for _, key := range prunedKeys {
+			if err := batch.Delete(changeKey(pruned, key)); err != nil {
+				return errorsmod.Wrapf(ErrStore, "failed to delete key index: %s", err)
+			}
+		}
+		if err := batch.Delete(versionKey(pruned)); err != nil {
+			return errorsmod.Wrapf(ErrStore, "failed to delete version %d: %s", pruned, err)
+		}
+	}
+	if err := batch.Set(earliestVersionKey, encodeVersion(earliest)); err != nil {
+		return errorsmod.Wrapf(ErrStore, "failed to write the earliest version: %s", err)
+	}
+	if err := batch.WriteSync(); err != nil {
+		return errorsmod.Wrapf(ErrStore, "failed to prune to version %d: %s", earliest, err)
+	}
+
+	s.earliest = earliest
+	return nil
+}
+
+// deleteOlderValues deletes the values of a key written before the version
+func (s *VersionedStore) deleteOlderValues(batch dbm.Batch, key string, version int64) error {
+	prefix := dataKeyPrefix(key)
+	iterator, err := s.db.Iterator(dataKey(prefix, 0), dataKey(prefix, version))
+	if err != nil {
+		return errorsmod.Wrapf(ErrStore, "failed to read key: %s", err)
+	}
+	defer iterator.Close()
+
+	for ; iterator.Valid(); iterator.Next() {
+		if err := batch.Delete(append([]byte{}, iterator.Key()...)); err != nil {
+			return errorsmod.Wrapf(ErrStore, "failed to delete key: %s", err)
+		}
+	}
+	return iterator.Error()
+}
+
+// changedKeys returns the keys written on a version
+func (s *VersionedStore) changedKeys(version int64) ([]string, error) {
+	prefix := changeKey(version, "")
+	iterator, err := dbm.IteratePrefix(s.db, prefix)
+	if err != nil {
+		return nil, errorsmod.Wrapf(ErrStore, "failed to read the keys of version %d: %s", version, err)
+	}
+	defer iterator.Close()
+
+	var keys []string
+	for ; iterator.Valid(); iterator.Next() {
+		keys = append(keys, string(iterator.Key()[len(prefix):]))
+	}
+	if err := iterator.Error(); err != nil {
+		return nil, errorsmod.Wrapf(ErrStore, "failed to read the keys of version %d: %s", version, err)
+	}
+	return keys, nil
+}
+
+// loadData loads the values of a version, the entries are sorted by key and version so the last one wins
+func (s *VersionedStore) loadData(version int64) error {
+	iterator, err := dbm.IteratePrefix(s.db, dataPrefix)
+	if err != nil {
+		return errorsmod.Wrapf(ErrStore, "failed to load the data: %s", err)
+	}
+	defer iterator.Close()
+
+	data := make(map[string][]byte)
+	for ; iterator.Valid(); iterator.Next() {
+		key, keyVersion := decodeDataKey(iterator.Key())
+		if keyVersion > version {
+			continue
+		}
+
+		value := iterator.Value()
+		if value[0] == valueDeleted {
+			delete(data, key)
+			continue
+		}
+		data[key] = append([]byte{}, value[1:]...)
+	}
+	if err := iterator.Error(); err != nil {
+		return errorsmod.Wrapf(ErrStore, "failed to load the data: %s", err)
+	}
+
+	s.data = data
+	return nil
+}
+
+// dataKeyPrefix returns the prefix of every version of a key, the key length is prefixed so no key is a prefix of other
+func dataKeyPrefix(key string) []byte {
+	prefix := append([]byte{}, dataPrefix...)
+	prefix = binary.AppendUvarint(prefix, uint64(len(key)))
+	return append(prefix, key...)
+}
+
+// dataKey returns the key of a version of a key
+func dataKey(prefix []byte, version int64) []byte {
+	return append(append([]byte{}, prefix...), encodeVersion(version)...)
+}
+
+// decodeDataKey returns the key and the version of a data key
+func decodeDataKey(bz []byte) (string, int64) {
+	bz = bz[len(dataPrefix):]
+	length, n := binary.Uvarint(bz)
+	key := string(bz[n : n+int(length)])
+	return key, int64(binary.BigEndian.Uint64(bz[n+int(length):]))
+}
+
+// versionKey returns the key of the info of a version
+func versionKey(version int64) []byte {
+	return append(append([]byte{}, versionPrefix...), encodeVersion(version)...)
+}
+
+// changeKey returns the key of the index of a key written on a version
+func changeKey(version int64, key string) []byte {
+	bz := append(append([]byte{}, changesPrefix...), encodeVersion(version)...)
+	return append(bz, key...)
+}
+
+// encodeVersion encodes a version on big endian, so the versions are sorted on the database
+func encodeVersion(version int64) []byte {
+	bz := make([]byte, 8)
+	binary.BigEndian.PutUint64(bz, uint64(version))
+	return bz
+}
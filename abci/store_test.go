@@ -0,0 +1,197 @@
+package abci_test
+
+import (
+	"bytes"
+	"testing"
+
+	dbm "github.com/cometbft/cometbft-db"
+	"github.com/cometbft/cometbft/abci/types"
+	"github.com/stretchr/testify/require"
+
+	"ibc-fee/abci"
+)
+
+// backend opens a database, opening it again returns the same data
+type backend struct {
+	name   string
+	openDB func(t *testing.T) dbm.DB
+}
+
+// backends returns the in memory and the on disk backends, each test gets a new database
+func backends(t *testing.T) []backend {
+	memDB := dbm.NewMemDB()
+	dir := t.TempDir()
+
+	return []backend{
+		{
+			name:   "memdb",
+			openDB: func(_ *testing.T) dbm.DB { return memDB },
+		},
+		{
+			name: "goleveldb",
+			openDB: func(t *testing.T) dbm.DB {
+				db, err := dbm.NewGoLevelDB("abci", dir)
+				require.NoError(t, err)
+				return db
+			},
+		},
+	}
+}
+
+// TestVersionedStore tests that each version keeps its values
+func TestVersionedStore(t *testing.T) {
+	for _, b := range backends(t) {
+		t.Run(b.name, func(t *testing.T) {
+			store, err := abci.NewVersionedStore(b.openDB(t))
+			require.NoError(t, err)
+			require.Equal(t, int64(0), store.Version())
+
+			// Version 1 sets two keys, version 2 updates one and deletes the other
+			store.Set("a", []byte("1"))
+			store.Set("b", []byte("2"))
+			version, err := store.Commit(abci.VersionInfo{AppHash: []byte("hash1")})
+			require.NoError(t, err)
+			require.Equal(t, int64(1), version)
+			store.Set("a", []byte("3"))
+			store.Delete("b")
+			_, err = store.Commit(abci.VersionInfo{AppHash: []byte("hash2")})
+			require.NoError(t, err)
+			store.Set("c", nil)
+			_, err = store.Commit(abci.VersionInfo{AppHash: []byte("hash3")})
+			require.NoError(t, err)
+
+			value, found := store.Get("a")
+			require.True(t, found)
+			require.Equal(t, []byte("3"), value)
+			_, found = store.Get("b")
+			require.False(t, found)
+
+			value, found, err = store.GetVersioned("b", 1)
+			require.NoError(t, err)
+			require.True(t, found)
+			require.Equal(t, []byte("2"), value)
+			_, found, err = store.GetVersioned("b", 2)
+			require.NoError(t, err)
+			require.False(t, found)
+			value, found, err = store.GetVersioned("a", 2)
+			require.NoError(t, err)
+			require.True(t, found)
+			require.Equal(t, []byte("3"), value)
+
+			// A empty value is not a deleted key
+			value, found, err = store.GetVersioned("c", 3)
+			require.NoError(t, err)
+			require.True(t, found)
+			require.Empty(t, value)
+
+			_, _, err = store.GetVersioned("a", 4)
+			require.ErrorIs(t, err, abci.ErrStore)
+			info, err := store.GetVersionInfo(2)
+			require.NoError(t, err)
+			require.Equal(t, []byte("hash2"), info.AppHash)
+			_, err = store.GetVersionInfo(4)
+			require.ErrorIs(t, err, abci.ErrStore)
+
+			// Reopening loads the latest version
+			require.NoError(t, store.Close())
+			store, err = abci.NewVersionedStore(b.openDB(t))
+			require.NoError(t, err)
+			require.Equal(t, int64(3), store.Version())
+			require.Equal(t, map[string][]byte{"a": []byte("3"), "c": {}}, store.Data())
+			require.NoError(t, store.Close())
+		})
+	}
+}
+
+// TestAppReopen tests that a reopened app continues at the last committed height
+// with the same validators, jail, liveness and evidence
+func TestAppReopen(t *testing.T) {
+	for _, b := range backends(t) {
+		t.Run(b.name, func(t *testing.T) {
+			app, err := abci.NewAppWithDB(b.openDB(t), newValidators(4), abci.NewThreshold(66, 100))
+			require.NoError(t, err)
+
+			jailed, offender := runReopenBlocks(t, app)
+
+			state := app.GetState()
+			validators := app.GetValidators()
+			require.Equal(t, int64(3), state.Height)
+			require.Len(t, validators, 4)
+			require.True(t, app.IsJailed(jailed.Address))
+			jailedValidators := app.GetJailedValidators()
+			evidence := app.PendingEvidence()
+			require.Len(t, evidence, 1)
+			// The added validator is only tracked from the next block
+			signingInfos := make(map[string]abci.ValidatorSigningInfo)
+			for _, validator := range append(validators, jailedValidators...) {
+				if signingInfo, found := app.GetSigningInfo(validator.Address); found {
+					signingInfos[string(validator.Address)] = signingInfo
+				}
+			}
+			require.Len(t, signingInfos, 4)
+			require.True(t, signingInfos[string(jailed.Address)].Tombstoned)
+			require.NoError(t, app.Close())
+
+			// The validators, jail, liveness and evidence are taken from the database
+			reopened, err := abci.NewAppWithDB(b.openDB(t), nil, abci.NewThreshold(66, 100))
+			require.NoError(t, err)
+			require.Equal(t, state.Height, reopened.GetState().Height)
+			require.Equal(t, state.AppHash, reopened.GetState().AppHash)
+			require.Equal(t, state.Data, reopened.GetState().Data)
+			require.Equal(t, validators, reopened.GetValidators())
+			require.Equal(t, jailedValidators, reopened.GetJailedValidators())
+			require.Equal(t, evidence, reopened.PendingEvidence())
+			for address, signingInfo := range signingInfos {
+				reopenedInfo, found := reopened.GetSigningInfo([]byte(address))
+				require.True(t, found)
+				require.Equal(t, signingInfo, reopenedInfo)
+			}
+
+			info := reopened.Info(types.RequestInfo{})
+			require.Equal(t, state.Height, info.LastBlockHeight)
+			require.Equal(t, state.AppHash, info.LastBlockAppHash)
+
+			// The reopened app keeps producing the same hashes and punishments as a app that never stopped
+			// and the tombstoned validator still can't unjail
+			app, err = abci.NewApp(newValidators(4), abci.NewThreshold(66, 100))
+			require.NoError(t, err)
+			runReopenBlocks(t, app)
+			for _, a := range []*abci.App{app, reopened} {
+				runBlock(t, a, withVotes(3), withEvidence(a.PendingEvidence()), withTxs([]byte("tx4")), duringBlock(func() {
+					require.ErrorIs(t, a.Unjail(jailed.Address), abci.ErrValidatorTombstoned)
+				}))
+			}
+			require.Equal(t, int64(4), reopened.GetState().Height)
+			require.Equal(t, app.GetState().AppHash, reopened.GetState().AppHash)
+			require.True(t, reopened.IsJailed(offender.Address))
+			require.Equal(t, app.GetValidators(), reopened.GetValidators())
+			require.Equal(t, app.GetJailedValidators(), reopened.GetJailedValidators())
+			for _, validator := range app.GetValidators() {
+				signingInfo, _ := app.GetSigningInfo(validator.Address)
+				reopenedInfo, _ := reopened.GetSigningInfo(validator.Address)
+				require.Equal(t, signingInfo, reopenedInfo)
+			}
+			require.NoError(t, reopened.Close())
+		})
+	}
+}
+
+// runReopenBlocks runs a passing block, a rejected block, a block that adds a validator where the first validator double signs
+// and a block where the evidence jails it and the next validator double signs
+// It returns the jailed validator and the validator with pending evidence
+func runReopenBlocks(t *testing.T, app *abci.App) (abci.Validator, abci.Validator) {
+	runBlock(t, app, withVotes(4), withTxs([]byte("tx1"), []byte("tx2")))
+	runBlock(t, app, withVotes(2), withTxs([]byte("rejected")))
+
+	jailed := app.GetValidators()[0]
+	runBlock(t, app, withVotes(4), withTxs([]byte("tx3")), withUpdates(validatorUpdate(t, 4, 1)), withDoubleSign(jailed))
+
+	// The jailed validator leaves the set on BeginBlock, the offender is the first of the remaining ones
+	offender := app.GetValidators()[0]
+	if bytes.Equal(offender.Address, jailed.Address) {
+		offender = app.GetValidators()[1]
+	}
+	runBlock(t, app, withVotes(4), withEvidence(app.PendingEvidence()), withDoubleSign(offender))
+	require.Equal(t, int64(3), app.GetState().Height)
+	return jailed, offender
+}
@@ -0,0 +1,60 @@
+package abci
+
+import (
+	sdkmath "cosmossdk.io/math"
+
+	errorsmod "cosmossdk.io/errors"
+)
+
+// Threshold is the exact fraction of the voting power needed to pass consensus
+// The comparison is done with integers, so there are no rounding errors at the boundary
+type Threshold struct {
+	Numerator   int64
+	Denominator int64
+	// Strict requires more than the fraction, as the ">2/3" of CometBFT, otherwise reaching it is enough
+	Strict bool
+}
+
+// NewThreshold returns a threshold that passes when the voted power reaches the fraction
+func NewThreshold(numerator, denominator int64) Threshold {
+	return Threshold{Numerator: numerator, Denominator: denominator}
+}
+
+// NewStrictThreshold returns a threshold that passes when the voted power is more than the fraction
+func NewStrictThreshold(numerator, denominator int64) Threshold {
+	return Threshold{Numerator: numerator, Denominator: denominator, Strict: true}
+}
+
+// DefaultThreshold is the CometBFT threshold, more than 2/3 of the voting power
+func DefaultThreshold() Threshold {
+	return NewStrictThreshold(2, 3)
+}
+
+// Validate checks that the fraction is between zero and one and that it can be passed
+func (t Threshold) Validate() error {
+	if t.Denominator <= 0 {
+		return errorsmod.Wrapf(ErrInvalidThreshold, "denominator must be positive, got %d", t.Denominator)
+	}
+	if t.Numerator <= 0 || t.Numerator > t.Denominator {
+		return errorsmod.Wrapf(ErrInvalidThreshold, "threshold %d/%d must be in (0, 1]", t.Numerator, t.Denominator)
+	}
+	if t.Strict && t.Numerator == t.Denominator {
+		return errorsmod.Wrap(ErrInvalidThreshold, "a strict threshold of 1 can never be passed")
+	}
+	return nil
+}
+
+// IsPassed returns true if the voted power passes the threshold of the total power
+// It compares votedPower * denominator with totalPower * numerator, so the division is never done
+func (t Threshold) IsPassed(votedPower, totalPower int64) bool {
+	if totalPower <= 0 {
+		return false
+	}
+
+	voted := sdkmath.NewInt(votedPower).MulRaw(t.Denominator)
+	required := sdkmath.NewInt(totalPower).MulRaw(t.Numerator)
+	if t.Strict {
+		return voted.GT(required)
+	}
+	return voted.GTE(required)
+}
@@ -0,0 +1,95 @@
+package abci_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/cometbft/cometbft/abci/types"
+	"github.com/stretchr/testify/require"
+
+	"ibc-fee/abci"
+)
+
+// TestThresholdIsPassed tests the exact comparison at the threshold boundary
+func TestThresholdIsPassed(t *testing.T) {
+	testCases := []struct {
+		name       string
+		threshold  abci.Threshold
+		votedPower int64
+		totalPower int64
+		expectPass bool
+	}{
+		{name: "exactly 2/3", threshold: abci.NewThreshold(2, 3), votedPower: 2, totalPower: 3, expectPass: true},
+		{name: "exactly 2/3 strict", threshold: abci.NewStrictThreshold(2, 3), votedPower: 2, totalPower: 3, expectPass: false},
+		{name: "more than 2/3 strict", threshold: abci.DefaultThreshold(), votedPower: 7, totalPower: 10, expectPass: true},
+		{name: "just below 2/3", threshold: abci.NewThreshold(2, 3), votedPower: 199, totalPower: 300, expectPass: false},
+		{name: "large powers at the boundary", threshold: abci.NewThreshold(2, 3), votedPower: math.MaxInt64 / 3 * 2, totalPower: math.MaxInt64 / 3 * 3, expectPass: true},
+		{name: "large powers below the boundary", threshold: abci.NewThreshold(2, 3), votedPower: math.MaxInt64/3*2 - 1, totalPower: math.MaxInt64 / 3 * 3, expectPass: false},
+		{name: "every validator", threshold: abci.NewThreshold(1, 1), votedPower: 5, totalPower: 5, expectPass: true},
+		{name: "no power", threshold: abci.NewThreshold(1, 2), votedPower: 0, totalPower: 0, expectPass: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expectPass, tc.threshold.IsPassed(tc.votedPower, tc.totalPower))
+		})
+	}
+}
+
+// TestThresholdValidate tests the validation of the threshold fraction
+func TestThresholdValidate(t *testing.T) {
+	testCases := []struct {
+		name      string
+		threshold abci.Threshold
+		expectErr bool
+	}{
+		{name: "default threshold", threshold: abci.DefaultThreshold()},
+		{name: "half", threshold: abci.NewThreshold(1, 2)},
+		{name: "every validator", threshold: abci.NewThreshold(1, 1)},
+		{name: "zero denominator", threshold: abci.NewThreshold(1, 0), expectErr: true},
+		{name: "negative denominator", threshold: abci.NewThreshold(1, -2), expectErr: true},
+		{name: "zero numerator", threshold: abci.NewThreshold(0, 2), expectErr: true},
+		{name: "more than one", threshold: abci.NewThreshold(3, 2), expectErr: true},
+		{name: "strict threshold of one", threshold: abci.NewStrictThreshold(1, 1), expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.threshold.Validate()
+			if tc.expectErr {
+				require.ErrorIs(t, err, abci.ErrInvalidThreshold)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+// TestNewAppInvalidParams tests that the app is not created with invalid params
+func TestNewAppInvalidParams(t *testing.T) {
+	_, err := abci.NewApp(newValidators(4), abci.NewThreshold(3, 2))
+	require.ErrorIs(t, err, abci.ErrInvalidThreshold)
+
+	_, err = abci.NewApp(nil, abci.DefaultThreshold())
+	require.ErrorIs(t, err, abci.ErrInvalidValidator)
+
+	_, err = abci.NewApp([]abci.Validator{withPower(newValidator(0, 1), 0)}, abci.DefaultThreshold())
+	require.ErrorIs(t, err, abci.ErrInvalidValidator)
+}
+
+// TestStrictThresholdConsensus tests the strict mode on a block voted by exactly 2/3 of the power
+func TestStrictThresholdConsensus(t *testing.T) {
+	for _, tc := range []struct {
+		threshold    abci.Threshold
+		expectPassed bool
+	}{
+		{threshold: abci.NewThreshold(2, 3), expectPassed: true},
+		{threshold: abci.NewStrictThreshold(2, 3), expectPassed: false},
+	} {
+		app := newApp(t, newValidators(3), tc.threshold)
+		app.BeginBlock(types.RequestBeginBlock{})
+		voteOnApp(2, app)
+		app.EndBlock(types.RequestEndBlock{})
+		require.Equal(t, tc.expectPassed, app.ProcessVotes())
+	}
+}
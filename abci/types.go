@@ -1,7 +1,8 @@
 package abci
 
-// State is a mock state with only a height and some data
+// State is a mock state with only a height, some data and the hash of the last commit
 type State struct {
-	Height int64
-	Data   map[string][]byte
+	Height  int64
+	Data    map[string][]byte
+	AppHash []byte
 }
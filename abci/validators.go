@@ -0,0 +1,194 @@
+package abci
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+
+	errorsmod "cosmossdk.io/errors"
+	"github.com/cometbft/cometbft/abci/types"
+	cryptoenc "github.com/cometbft/cometbft/crypto/encoding"
+	"github.com/cometbft/cometbft/proto/tendermint/crypto"
+)
+
+// Validator is a validator of the simulated app with its voting power
+type Validator struct {
+	// Address is the CometBFT address of the validator, derived from its consensus public key
+	Address []byte
+	// PubKey is the consensus public key of the validator, it is sent on the validator updates
+	PubKey crypto.PublicKey
+	// Power is the voting power of the validator
+	Power int64
+}
+
+// validatorJSON is the JSON encoding of a validator
+// The public key is proto encoded, since its oneof can't be decoded from JSON
+type validatorJSON struct {
+	Address []byte `json:"address"`
+	PubKey  []byte `json:"pub_key"`
+	Power   int64  `json:"power"`
+}
+
+// NewValidator returns a validator with the address derived from its consensus public key
+func NewValidator(pubKey crypto.PublicKey, power int64) (Validator, error) {
+	key, err := cryptoenc.PubKeyFromProto(pubKey)
+	if err != nil {
+		return Validator{}, errorsmod.Wrapf(ErrInvalidValidator, "invalid validator public key: %s", err)
+	}
+	return Validator{Address: key.Address(), PubKey: pubKey, Power: power}, nil
+}
+
+// MarshalJSON encodes the validator with its proto encoded public key
+func (v Validator) MarshalJSON() ([]byte, error) {
+	pubKey, err := v.PubKey.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(validatorJSON{Address: v.Address, PubKey: pubKey, Power: v.Power})
+}
+
+// UnmarshalJSON decodes a validator encoded by MarshalJSON
+func (v *Validator) UnmarshalJSON(bz []byte) error {
+	var decoded validatorJSON
+	if err := json.Unmarshal(bz, &decoded); err != nil {
+		return err
+	}
+	var pubKey crypto.PublicKey
+	if err := pubKey.Unmarshal(decoded.PubKey); err != nil {
+		return err
+	}
+	*v = Validator{Address: decoded.Address, PubKey: pubKey, Power: decoded.Power}
+	return nil
+}
+
+// ValidatorSet is the set of validators that vote on the blocks, indexed by address
+type ValidatorSet struct {
+	validators map[string]Validator
+}
+
+// NewValidatorSet returns a validator set, the addresses must be unique and the powers positive
+// Each address must be derived from the validator public key
+func NewValidatorSet(validators []Validator) (*ValidatorSet, error) {
+	vs := &ValidatorSet{validators: make(map[string]Validator)}
+	for _, validator := range validators {
+		key, err := cryptoenc.PubKeyFromProto(validator.PubKey)
+		if err != nil {
+			return nil, errorsmod.Wrapf(ErrInvalidValidator, "invalid validator public key: %s", err)
+		}
+		if !bytes.Equal(key.Address(), validator.Address) {
+			return nil, errorsmod.Wrapf(ErrInvalidValidator, "validator address %X doesn't match its public key", validator.Address)
+		}
+		if validator.Power <= 0 {
+			return nil, errorsmod.Wrapf(ErrInvalidValidator, "validator %X has non positive power %d", validator.Address, validator.Power)
+		}
+		if _, found := vs.validators[string(validator.Address)]; found {
+			return nil, errorsmod.Wrapf(ErrInvalidValidator, "duplicated validator %X", validator.Address)
+		}
+		vs.validators[string(validator.Address)] = validator
+	}
+	return vs, nil
+}
+
+// GetByAddress returns a validator by its address
+func (vs *ValidatorSet) GetByAddress(address []byte) (Validator, bool) {
+	validator, found := vs.validators[string(address)]
+	return validator, found
+}
+
+// Validators returns the validators sorted by address
+func (vs *ValidatorSet) Validators() []Validator {
+	validators := make([]Validator, 0, len(vs.validators))
+	for _, validator := range vs.validators {
+		validators = append(validators, validator)
+	}
+	sort.Slice(validators, func(i, j int) bool {
+		return bytes.Compare(validators[i].Address, validators[j].Address) < 0
+	})
+	return validators
+}
+
+// Size returns the amount of validators
+func (vs *ValidatorSet) Size() int {
+	return len(vs.validators)
+}
+
+// TotalPower returns the sum of the voting power of all the validators
+func (vs *ValidatorSet) TotalPower() int64 {
+	var totalPower int64
+	for _, validator := range vs.validators {
+		totalPower += validator.Power
+	}
+	return totalPower
+}
+
+// Copy returns a copy of the validator set
+func (vs *ValidatorSet) Copy() *ValidatorSet {
+	validators := make(map[string]Validator, len(vs.validators))
+	for address, validator := range vs.validators {
+		validators[address] = validator
+	}
+	return &ValidatorSet{validators: validators}
+}
+
+// ApplyUpdates applies the CometBFT validator updates, as returned by EndBlock
+// A update with zero power removes the validator, otherwise the validator is added or its power replaced
+// The updates are validated before any is applied, so a invalid update leaves the set unchanged
+// Updates that would remove every validator are rejected, since no block could pass consensus
+// Updates of the jailed validators, tombstoned included, are rejected, they only return to the set by unjailing
+func (vs *ValidatorSet) ApplyUpdates(updates []types.ValidatorUpdate, jailed *ValidatorSet) error {
+	validators := make([]Validator, len(updates))
+	for i, update := range updates {
+		validator, err := NewValidator(update.PubKey, update.Power)
+		if err != nil {
+			return err
+		}
+		if update.Power < 0 {
+			return errorsmod.Wrapf(ErrInvalidValidator, "validator %X has negative power %d", validator.Address, update.Power)
+		}
+		if _, found := jailed.GetByAddress(validator.Address); found {
+			return errorsmod.Wrapf(ErrValidatorJailed, "validator %X is jailed", validator.Address)
+		}
+		validators[i] = validator
+	}
+
+	updated := vs.Copy()
+	for _, validator := range validators {
+		if validator.Power == 0 {
+			delete(updated.validators, string(validator.Address))
+			continue
+		}
+		updated.validators[string(validator.Address)] = validator
+	}
+	if updated.Size() == 0 {
+		return errorsmod.Wrap(ErrInvalidValidator, "validator updates remove every validator")
+	}
+
+	vs.validators = updated.validators
+	return nil
+}
+
+// UpdatesTo returns the CometBFT validator updates that turn the set into the next set, sorted by address
+// The removed validators are sent with zero power, the unchanged validators are not sent
+func (vs *ValidatorSet) UpdatesTo(next *ValidatorSet) []types.ValidatorUpdate {
+	changed := []Validator{}
+	for address, validator := range vs.validators {
+		if _, found := next.validators[address]; !found {
+			validator.Power = 0
+			changed = append(changed, validator)
+		}
+	}
+	for address, validator := range next.validators {
+		if current, found := vs.validators[address]; !found || current.Power != validator.Power {
+			changed = append(changed, validator)
+		}
+	}
+	sort.Slice(changed, func(i, j int) bool {
+		return bytes.Compare(changed[i].Address, changed[j].Address) < 0
+	})
+
+	updates := make([]types.ValidatorUpdate, len(changed))
+	for i, validator := range changed {
+		updates[i] = types.ValidatorUpdate{PubKey: validator.PubKey, Power: validator.Power}
+	}
+	return updates
+}
@@ -0,0 +1,192 @@
+package abci_test
+
+import (
+	"testing"
+
+	"github.com/cometbft/cometbft/abci/types"
+	cryptoenc "github.com/cometbft/cometbft/crypto/encoding"
+	"github.com/stretchr/testify/require"
+
+	"ibc-fee/abci"
+)
+
+// TestNewValidatorSet tests the validation of the validator set
+func TestNewValidatorSet(t *testing.T) {
+	validators := newValidators(2)
+
+	testCases := []struct {
+		name       string
+		validators []abci.Validator
+		expectErr  bool
+	}{
+		{name: "valid set", validators: validators},
+		{name: "empty public key", validators: []abci.Validator{{Address: validators[0].Address, Power: 1}}, expectErr: true},
+		{name: "address not matching the public key", validators: []abci.Validator{{Address: validators[1].Address, PubKey: validators[0].PubKey, Power: 1}}, expectErr: true},
+		{name: "zero power", validators: []abci.Validator{withPower(validators[0], 0)}, expectErr: true},
+		{name: "duplicated address", validators: []abci.Validator{validators[0], validators[0]}, expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			vs, err := abci.NewValidatorSet(tc.validators)
+			if tc.expectErr {
+				require.ErrorIs(t, err, abci.ErrInvalidValidator)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, int64(2), vs.TotalPower())
+		})
+	}
+}
+
+// TestWeightedConsensus tests that the threshold is computed with the voting power
+func TestWeightedConsensus(t *testing.T) {
+	validators := newValidators(3)
+	validators[0].Power = 70
+	validators[1].Power = 20
+	validators[2].Power = 10
+	blockHash := []byte("block")
+
+	testCases := []struct {
+		name         string
+		voters       []int
+		expectPassed bool
+	}{
+		{name: "validator with most power alone", voters: []int{0}, expectPassed: true},
+		{name: "validators with less power", voters: []int{1, 2}, expectPassed: false},
+		{name: "every validator", voters: []int{0, 1, 2}, expectPassed: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			app := newApp(t, validators, abci.NewThreshold(66, 100))
+			app.BeginBlock(types.RequestBeginBlock{Hash: blockHash})
+			for _, voter := range tc.voters {
+				require.NoError(t, app.Vote(validators[voter].Address, blockHash))
+			}
+			app.EndBlock(types.RequestEndBlock{})
+			require.Equal(t, tc.expectPassed, app.ProcessVotes())
+		})
+	}
+}
+
+// TestVoteDedup tests that each validator only votes once and only votes for the block count
+func TestVoteDedup(t *testing.T) {
+	validators := newValidators(4)
+	blockHash := []byte("block")
+
+	app := newApp(t, validators, abci.NewThreshold(66, 100))
+	app.BeginBlock(types.RequestBeginBlock{Hash: blockHash})
+
+	// Repeated votes are only counted once
+	for i := 0; i < 3; i++ {
+		require.NoError(t, app.Vote(validators[0].Address, blockHash))
+		require.NoError(t, app.Vote(validators[1].Address, blockHash))
+	}
+
+	// A vote for another block is rejected
+	err := app.Vote(validators[0].Address, []byte("other"))
+	require.ErrorIs(t, err, abci.ErrDuplicateVote)
+
+	// Unknown validators can't vote
+	err = app.Vote([]byte("unknown"), blockHash)
+	require.ErrorIs(t, err, abci.ErrUnknownValidator)
+
+	// A vote for another block doesn't count for this block
+	require.NoError(t, app.Vote(validators[2].Address, []byte("other")))
+
+	app.EndBlock(types.RequestEndBlock{})
+	require.False(t, app.ProcessVotes())
+}
+
+// TestValidatorUpdates tests that the updates are returned on EndBlock and the new set is used after Commit
+func TestValidatorUpdates(t *testing.T) {
+	app := newApp(t, newValidators(2), abci.NewThreshold(66, 100))
+
+	// Add a new validator with more power and remove a existing one
+	app.BeginBlock(types.RequestBeginBlock{})
+	updates := []types.ValidatorUpdate{
+		validatorUpdate(t, 5, 10),
+		validatorUpdate(t, 1, 0),
+	}
+	for _, update := range updates {
+		require.NoError(t, app.UpdateValidator(update))
+	}
+	voteOnApp(2, app)
+	res := app.EndBlock(types.RequestEndBlock{})
+	require.ElementsMatch(t, updates, res.ValidatorUpdates)
+
+	// The current block still uses the old set
+	require.Len(t, app.GetValidators(), 2)
+	require.True(t, app.ProcessVotes())
+	app.Commit()
+
+	// The new set is used from the next block
+	added := newValidator(5, 10)
+	require.ElementsMatch(t, []abci.Validator{newValidators(1)[0], added}, app.GetValidators())
+
+	// The new validator alone has enough power
+	app.BeginBlock(types.RequestBeginBlock{})
+	require.NoError(t, app.Vote(added.Address, nil))
+	app.EndBlock(types.RequestEndBlock{})
+	require.True(t, app.ProcessVotes())
+	app.Commit()
+	require.Equal(t, int64(2), app.GetState().Height)
+}
+
+// TestValidatorUpdatesRejectedBlock tests that the updates of a rejected block are discarded
+func TestValidatorUpdatesRejectedBlock(t *testing.T) {
+	app := newApp(t, newValidators(2), abci.NewThreshold(66, 100))
+
+	app.BeginBlock(types.RequestBeginBlock{})
+	require.NoError(t, app.UpdateValidator(validatorUpdate(t, 5, 10)))
+	app.EndBlock(types.RequestEndBlock{})
+	require.False(t, app.ProcessVotes())
+	app.Commit()
+
+	require.ElementsMatch(t, newValidators(2), app.GetValidators())
+}
+
+// TestInvalidValidatorUpdates tests that invalid updates are rejected
+func TestInvalidValidatorUpdates(t *testing.T) {
+	app := newApp(t, newValidators(1), abci.NewThreshold(66, 100))
+	app.BeginBlock(types.RequestBeginBlock{})
+
+	require.ErrorIs(t, app.UpdateValidator(types.ValidatorUpdate{Power: 1}), abci.ErrInvalidValidator)
+	require.ErrorIs(t, app.UpdateValidator(validatorUpdate(t, 1, -1)), abci.ErrInvalidValidator)
+
+	// Removing every validator is not allowed
+	vs, err := abci.NewValidatorSet(newValidators(1))
+	require.NoError(t, err)
+	require.ErrorIs(t, vs.ApplyUpdates([]types.ValidatorUpdate{validatorUpdate(t, 0, 0)}, &abci.ValidatorSet{}), abci.ErrInvalidValidator)
+	require.Equal(t, 1, vs.Size())
+
+	// Jailed validators can't be updated
+	jailed, err := abci.NewValidatorSet(newValidators(2)[1:])
+	require.NoError(t, err)
+	require.ErrorIs(t, vs.ApplyUpdates([]types.ValidatorUpdate{validatorUpdate(t, 1, 1)}, jailed), abci.ErrValidatorJailed)
+	require.Equal(t, 1, vs.Size())
+}
+
+// TestValidatorUpdatesTo tests the updates between two validator sets
+func TestValidatorUpdatesTo(t *testing.T) {
+	current, err := abci.NewValidatorSet(newValidators(3))
+	require.NoError(t, err)
+	next, err := abci.NewValidatorSet([]abci.Validator{newValidator(0, 1), newValidator(1, 5), newValidator(3, 1)})
+	require.NoError(t, err)
+
+	expected := []types.ValidatorUpdate{
+		validatorUpdate(t, 1, 5),
+		validatorUpdate(t, 2, 0),
+		validatorUpdate(t, 3, 1),
+	}
+	require.ElementsMatch(t, expected, current.UpdatesTo(next))
+	require.Empty(t, current.UpdatesTo(current))
+}
+
+// validatorUpdate is a helper function that returns a update for the validator index
+func validatorUpdate(t *testing.T, i int, power int64) types.ValidatorUpdate {
+	pubKey, err := cryptoenc.PubKeyToProto(validatorKey(i).PubKey())
+	require.NoError(t, err)
+	return types.ValidatorUpdate{PubKey: pubKey, Power: power}
+}
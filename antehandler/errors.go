@@ -0,0 +1,64 @@
+package antehandler
+
+import (
+	"fmt"
+
+	errorsmod "cosmossdk.io/errors"
+)
+
+// Errors of the weighted fee antehandler and the simulated feeHandler module
+// The codes are part of the ABCI responses, so clients can branch on them
+// Codes must never be changed or reused, new errors are added with the next free code
+var (
+	ErrFeeTxDecode             = errorsmod.Register(FeeHandlerModuleName, 2, "error parsing tx into FeeTx")
+	ErrInsufficientByteFee     = errorsmod.Register(FeeHandlerModuleName, 3, "insufficient funds to pay the byte fee")
+	ErrDenomNotAccepted        = errorsmod.Register(FeeHandlerModuleName, 4, "denom not accepted to pay the byte fee")
+	ErrStalePrice              = errorsmod.Register(FeeHandlerModuleName, 5, "stale oracle price")
+	ErrInvalidParams           = errorsmod.Register(FeeHandlerModuleName, 6, "invalid params")
+	ErrMsgNestingTooDeep       = errorsmod.Register(FeeHandlerModuleName, 7, "msgs nested too deep")
+	ErrInvalidActivationHeight = errorsmod.Register(FeeHandlerModuleName, 8, "invalid activation height")
+	ErrParamsUpdateExists      = errorsmod.Register(FeeHandlerModuleName, 9, "params update already scheduled")
+	ErrParamsUpdateNotFound    = errorsmod.Register(FeeHandlerModuleName, 10, "params update not found")
+	ErrInvalidAuthority        = errorsmod.Register(FeeHandlerModuleName, 11, "invalid authority")
+	ErrInvalidRelayer          = errorsmod.Register(FeeHandlerModuleName, 12, "invalid relayer")
+	ErrRelayerNotFound         = errorsmod.Register(FeeHandlerModuleName, 13, "relayer not found")
+	ErrReceiptNotFound         = errorsmod.Register(FeeHandlerModuleName, 14, "byte fee receipt not found")
+	ErrPriceNotFound           = errorsmod.Register(FeeHandlerModuleName, 15, "oracle price not found")
+)
+
+// causedError is a registered error caused by a error of another module, such as a bank error
+// The ABCI code and codespace are the registered ones, while errors.Is matches both errors
+type causedError struct {
+	err   *errorsmod.Error
+	cause error
+	msg   string
+}
+
+// wrapWithCause returns the registered error with the given cause and description
+func wrapWithCause(err *errorsmod.Error, cause error, format string, args ...interface{}) error {
+	return &causedError{
+		err:   err,
+		cause: cause,
+		msg:   fmt.Sprintf(format, args...),
+	}
+}
+
+// Error returns the description followed by the registered error and the cause
+func (e *causedError) Error() string {
+	return fmt.Sprintf("%s: %s: %s", e.msg, e.err, e.cause)
+}
+
+// Codespace returns the codespace of the registered error
+func (e *causedError) Codespace() string {
+	return e.err.Codespace()
+}
+
+// ABCICode returns the ABCI code of the registered error
+func (e *causedError) ABCICode() uint32 {
+	return e.err.ABCICode()
+}
+
+// Unwrap returns both the registered error and the cause
+func (e *causedError) Unwrap() []error {
+	return []error{e.err, e.cause}
+}
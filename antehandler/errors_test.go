@@ -0,0 +1,57 @@
+package antehandler_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	errorsmod "cosmossdk.io/errors"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+
+	ante "ibc-fee/antehandler"
+)
+
+// TestErrorsABCICodes tests that the registered errors keep their codespace and codes
+// Clients branch on these codes, so a failure here means a breaking change
+func TestErrorsABCICodes(t *testing.T) {
+	expectedCodes := map[*errorsmod.Error]uint32{
+		ante.ErrFeeTxDecode:             2,
+		ante.ErrInsufficientByteFee:     3,
+		ante.ErrDenomNotAccepted:        4,
+		ante.ErrStalePrice:              5,
+		ante.ErrInvalidParams:           6,
+		ante.ErrMsgNestingTooDeep:       7,
+		ante.ErrInvalidActivationHeight: 8,
+		ante.ErrParamsUpdateExists:      9,
+		ante.ErrParamsUpdateNotFound:    10,
+		ante.ErrInvalidAuthority:        11,
+		ante.ErrInvalidRelayer:          12,
+		ante.ErrRelayerNotFound:         13,
+		ante.ErrReceiptNotFound:         14,
+		ante.ErrPriceNotFound:           15,
+	}
+
+	for err, code := range expectedCodes {
+		codespace, abciCode, _ := errorsmod.ABCIInfo(errorsmod.Wrap(err, "wrapped"), false)
+		require.Equal(t, ante.FeeHandlerModuleName, codespace, err.Error())
+		require.Equal(t, code, abciCode, err.Error())
+	}
+}
+
+// TestFeeAuditAnteFeeTxDecode tests the TXs that can't be parsed as a FeeTx
+func TestFeeAuditAnteFeeTxDecode(t *testing.T) {
+	s := SetupIntegrationTestSuite(t, false)
+	antehandler := sdk.ChainAnteDecorators(ante.NewFeeAuditDecorator(s.feeHandlerKeeper))
+
+	_, err := antehandler(s.ctx, noFeeTx{}, false)
+	require.ErrorIs(t, err, ante.ErrFeeTxDecode)
+}
+
+// noFeeTx is a TX that doesn't implement the FeeTx interface
+type noFeeTx struct{}
+
+// GetMsgs returns no msgs
+func (noFeeTx) GetMsgs() []sdk.Msg { return nil }
+
+// ValidateBasic does no validation
+func (noFeeTx) ValidateBasic() error { return nil }
@@ -1,17 +1,11 @@
 package antehandler
 
 import (
+	"time"
+
 	sdk "github.com/cosmos/cosmos-sdk/types"
 )
 
-// FeeHandlerParams are the params for the simulated feeHandlerModule
-type FeeHandlerParams struct {
-	// FeeBytePrice is the price for each byte in a TX
-	FeeBytePrice sdk.DecCoins
-	// The minimum size a TX must have
-	MinTxSize uint64
-}
-
 // BankKeeper defines the interface of the banking Keeper used on the weighted_fee ante handler
 type BankKeeper interface {
 	IsSendEnabledCoins(ctx sdk.Context, coins ...sdk.Coin) error
@@ -22,4 +16,40 @@ type BankKeeper interface {
 // FeeHandler defines a simulated feeHandler module
 type FeeHandler interface {
 	GetParams(ctx sdk.Context) FeeHandlerParams
+	// GetSurgeMultiplier returns the current byte price multiplier of a fee payer
+	GetSurgeMultiplier(ctx sdk.Context, feePayer sdk.AccAddress) sdk.Dec
+	// TrackTxBytes registers the bytes sent by a fee payer at the current height
+	TrackTxBytes(ctx sdk.Context, feePayer sdk.AccAddress, size uint64)
+	// TrackBytesFee registers the byte fees charged on the current block
+	TrackBytesFee(ctx sdk.Context, fee sdk.Coins)
+	// SetBytesFeeReceipt stores the byte fee receipt of a TX
+	SetBytesFeeReceipt(ctx sdk.Context, txHash []byte, receipt BytesFeeReceipt)
+	// GetRelayer returns a relayer registered by governance
+	GetRelayer(ctx sdk.Context, relayerAddr sdk.AccAddress) (Relayer, bool)
+}
+
+// FeeAuditKeeper defines the simulated feeHandler module used on the fee audit ante handler
+type FeeAuditKeeper interface {
+	// SnapshotFeeCollectorBalance stores the fee collector balance before the first fee of the current block
+	SnapshotFeeCollectorBalance(ctx sdk.Context)
+	// TrackRegularFee registers the regular TX fees charged on the current block
+	TrackRegularFee(ctx sdk.Context, fee sdk.Coins)
+}
+
+// BalanceKeeper defines the interface of the banking Keeper used by the feeHandler module
+type BalanceKeeper interface {
+	GetAllBalances(ctx sdk.Context, addr sdk.AccAddress) sdk.Coins
+}
+
+// PacketDataUnmarshaler defines the interface used to read the data of IBC packets
+// It is implemented by the IBC applications, see ibc-go/modules/core/05-port/types/module.go
+type PacketDataUnmarshaler interface {
+	UnmarshalPacketData(bz []byte) (interface{}, error)
+}
+
+// PriceOracle defines the interface of a price oracle used to pay fees in non native denoms
+type PriceOracle interface {
+	// GetExchangeRate returns how many units of the quote denom are worth one unit of the base denom
+	// It also returns the time of the last price update, found is false if there is no price
+	GetExchangeRate(ctx sdk.Context, baseDenom, quoteDenom string) (rate sdk.Dec, updatedAt time.Time, found bool)
 }
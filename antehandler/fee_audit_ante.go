@@ -0,0 +1,48 @@
+// Antehandler to register the regular TX fees on the block fees audit
+// It must be placed before the Cosmos-SDK DeductFeeDecorator and any other decorator sending coins to the fee collector
+// On the first TX of the block it takes the fee collector snapshot, before any fee of the block was charged
+// All the BeginBlockers already ran at that point, so the snapshot doesn't depend on the module order
+// Fee grants are disabled on this repo, so the fee is always sent to the fee collector
+package antehandler
+
+import (
+	errorsmod "cosmossdk.io/errors"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// Assert that the AnteDecorator function is really being implemented
+var _ sdk.AnteDecorator = (*FeeAuditDecorator)(nil)
+
+// FeeAuditDecorator is the decorator responsible of registering the regular TX fees charged on a block
+type FeeAuditDecorator struct {
+	feeAuditKeeper FeeAuditKeeper
+}
+
+// NewFeeAuditDecorator returns a new fee audit decorator
+func NewFeeAuditDecorator(fak FeeAuditKeeper) FeeAuditDecorator {
+	return FeeAuditDecorator{
+		feeAuditKeeper: fak,
+	}
+}
+
+// AnteHandle takes the fee collector snapshot, continues the decorator execution and registers the TX fee
+// The fee is only registered if the next decorators succeed, so only fees that were really deducted are registered
+func (fad FeeAuditDecorator) AnteHandle(ctx sdk.Context, tx sdk.Tx, simulate bool, next sdk.AnteHandler) (sdk.Context, error) {
+	feeTx, ok := tx.(sdk.FeeTx)
+	if !ok {
+		return ctx, errorsmod.Wrapf(
+			ErrFeeTxDecode, "invalid tx type %T", tx,
+		)
+	}
+
+	fad.feeAuditKeeper.SnapshotFeeCollectorBalance(ctx)
+
+	newCtx, err := next(ctx, tx, simulate)
+	if err != nil {
+		return newCtx, err
+	}
+
+	fad.feeAuditKeeper.TrackRegularFee(newCtx, feeTx.GetFee())
+
+	return newCtx, nil
+}
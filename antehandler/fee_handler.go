@@ -0,0 +1,257 @@
+// Simplified keeper of the simulated feeHandler module
+// The keeper stores its params on a KVStore and supports params updates scheduled by governance
+// Since there is no protobuf definition behind this module, the values are stored as JSON
+package antehandler
+
+import (
+	"encoding/json"
+	"strconv"
+
+	errorsmod "cosmossdk.io/errors"
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+const (
+	// FeeHandlerModuleName is the name of the simulated feeHandler module
+	FeeHandlerModuleName = "feehandler"
+	// FeeHandlerStoreKey is the store key of the simulated feeHandler module
+	FeeHandlerStoreKey = FeeHandlerModuleName
+	// FeeHandlerTransientStoreKey is the transient store key of the simulated feeHandler module
+	// It holds the fees charged on the current block
+	FeeHandlerTransientStoreKey = "transient_" + FeeHandlerModuleName
+
+	// Events emitted by the feeHandler module
+	EventTypeParamsUpdate          = "fee_handler_params_update"
+	AttributeKeyActivationHeight   = "activation_height"
+	AttributeKeyParamsFeeBytePrice = "fee_byte_price"
+	AttributeKeyParamsMinTxSize    = "min_tx_size"
+)
+
+var (
+	// ParamsKey is the key of the current params
+	ParamsKey = []byte{0x01}
+	// ScheduledParamsPrefix is the prefix of the params updates, keyed by activation height
+	ScheduledParamsPrefix = []byte{0x02}
+)
+
+// Assert that the FeeHandler interface is implemented by the keeper
+var _ FeeHandler = (*FeeHandlerKeeper)(nil)
+
+// ScheduledParamsUpdate is a params update that activates at a future block height
+type ScheduledParamsUpdate struct {
+	// ActivationHeight is the height where the params are swapped in
+	ActivationHeight int64
+	// Params are the params that will be active from the activation height
+	Params FeeHandlerParams
+}
+
+// FeeHandlerKeeper is the keeper of the simulated feeHandler module
+type FeeHandlerKeeper struct {
+	storeKey          storetypes.StoreKey
+	transientStoreKey storetypes.StoreKey
+	bankKeeper        BalanceKeeper
+	// authority is the address allowed to change the params, usually the gov module account
+	authority string
+}
+
+// NewFeeHandlerKeeper returns a new feeHandler keeper
+func NewFeeHandlerKeeper(
+	storeKey storetypes.StoreKey,
+	transientStoreKey storetypes.StoreKey,
+	bk BalanceKeeper,
+	authority string,
+) FeeHandlerKeeper {
+	return FeeHandlerKeeper{
+		storeKey:          storeKey,
+		transientStoreKey: transientStoreKey,
+		bankKeeper:        bk,
+		authority:         authority,
+	}
+}
+
+// GetAuthority returns the address allowed to change the params
+func (k FeeHandlerKeeper) GetAuthority() string {
+	return k.authority
+}
+
+// GetParams returns the current params
+// If no params were set, the default params are returned
+func (k FeeHandlerKeeper) GetParams(ctx sdk.Context) FeeHandlerParams {
+	bz := ctx.KVStore(k.storeKey).Get(ParamsKey)
+	if bz == nil {
+		return DefaultFeeHandlerParams()
+	}
+
+	return mustUnmarshalParams(bz)
+}
+
+// SetParams validates and stores the current params
+func (k FeeHandlerKeeper) SetParams(ctx sdk.Context, params FeeHandlerParams) error {
+	if err := params.Validate(); err != nil {
+		return err
+	}
+
+	ctx.KVStore(k.storeKey).Set(ParamsKey, mustMarshalJSON(params))
+	return nil
+}
+
+// ScheduleParamsUpdate schedules new params to be swapped in at the activation height
+// Only the authority can schedule updates and only a single update can exist per height
+func (k FeeHandlerKeeper) ScheduleParamsUpdate(ctx sdk.Context, authority string, update ScheduledParamsUpdate) error {
+	if err := k.validateAuthority(authority); err != nil {
+		return err
+	}
+
+	// The update must be in the future, otherwise it would never be activated
+	if update.ActivationHeight <= ctx.BlockHeight() {
+		return errorsmod.Wrapf(
+			ErrInvalidActivationHeight,
+			"activation height %d must be greater than the current height %d", update.ActivationHeight, ctx.BlockHeight(),
+		)
+	}
+
+	if err := update.Params.Validate(); err != nil {
+		return err
+	}
+
+	store := ctx.KVStore(k.storeKey)
+	key := scheduledParamsKey(update.ActivationHeight)
+	if store.Has(key) {
+		return errorsmod.Wrapf(
+			ErrParamsUpdateExists,
+			"a params update is already scheduled at height %d", update.ActivationHeight,
+		)
+	}
+
+	k.setScheduledParamsUpdate(ctx, update)
+	return nil
+}
+
+// setScheduledParamsUpdate stores a params update that was already validated
+func (k FeeHandlerKeeper) setScheduledParamsUpdate(ctx sdk.Context, update ScheduledParamsUpdate) {
+	ctx.KVStore(k.storeKey).Set(scheduledParamsKey(update.ActivationHeight), mustMarshalJSON(update))
+}
+
+// CancelParamsUpdate removes a scheduled params update
+func (k FeeHandlerKeeper) CancelParamsUpdate(ctx sdk.Context, authority string, activationHeight int64) error {
+	if err := k.validateAuthority(authority); err != nil {
+		return err
+	}
+
+	store := ctx.KVStore(k.storeKey)
+	key := scheduledParamsKey(activationHeight)
+	if !store.Has(key) {
+		return errorsmod.Wrapf(
+			ErrParamsUpdateNotFound,
+			"no params update scheduled at height %d", activationHeight,
+		)
+	}
+
+	store.Delete(key)
+	return nil
+}
+
+// GetScheduledParamsUpdates returns all the upcoming params updates ordered by activation height
+// This is the query used by wallets to warn users of upcoming fee changes
+func (k FeeHandlerKeeper) GetScheduledParamsUpdates(ctx sdk.Context) []ScheduledParamsUpdate {
+	iterator := sdk.KVStorePrefixIterator(ctx.KVStore(k.storeKey), ScheduledParamsPrefix)
+	defer iterator.Close()
+
+	updates := []ScheduledParamsUpdate{}
+	for ; iterator.Valid(); iterator.Next() {
+		update := mustUnmarshalScheduledParamsUpdate(iterator.Value())
+		updates = append(updates, update)
+	}
+
+	return updates
+}
+
+// BeginBlock swaps in the scheduled params that reached the activation height
+// It also prunes the old receipts
+func (k FeeHandlerKeeper) BeginBlock(ctx sdk.Context) {
+	k.applyScheduledParamsUpdates(ctx)
+	k.pruneBytesFeeReceipts(ctx)
+}
+
+// applyScheduledParamsUpdates swaps in the scheduled params that reached the activation height
+// If multiple updates are due, they are applied in order and the latest one wins
+func (k FeeHandlerKeeper) applyScheduledParamsUpdates(ctx sdk.Context) {
+	store := ctx.KVStore(k.storeKey)
+
+	// Collect the due updates first, the store can't be changed while iterating
+	// Keys are big endian heights, so the iteration is ordered by height
+	dueUpdates := []ScheduledParamsUpdate{}
+	iterator := sdk.KVStorePrefixIterator(store, ScheduledParamsPrefix)
+	for ; iterator.Valid(); iterator.Next() {
+		update := mustUnmarshalScheduledParamsUpdate(iterator.Value())
+		if update.ActivationHeight > ctx.BlockHeight() {
+			break
+		}
+		dueUpdates = append(dueUpdates, update)
+	}
+	iterator.Close()
+
+	for _, update := range dueUpdates {
+		// Params were validated when scheduled
+		store.Set(ParamsKey, mustMarshalJSON(update.Params))
+		store.Delete(scheduledParamsKey(update.ActivationHeight))
+
+		ctx.EventManager().EmitEvent(
+			sdk.NewEvent(
+				EventTypeParamsUpdate,
+				sdk.NewAttribute(AttributeKeyActivationHeight, strconv.FormatInt(update.ActivationHeight, 10)),
+				sdk.NewAttribute(AttributeKeyParamsFeeBytePrice, update.Params.FeeBytePrice.String()),
+				sdk.NewAttribute(AttributeKeyParamsMinTxSize, strconv.FormatUint(update.Params.MinTxSize, 10)),
+			),
+		)
+	}
+}
+
+// validateAuthority checks if the signer is the module authority
+func (k FeeHandlerKeeper) validateAuthority(authority string) error {
+	if authority != k.authority {
+		return errorsmod.Wrapf(
+			ErrInvalidAuthority,
+			"invalid authority; expected %s, got %s", k.authority, authority,
+		)
+	}
+	return nil
+}
+
+// scheduledParamsKey returns the store key of a scheduled params update
+// The height is big endian encoded so the store iteration is ordered by height
+func scheduledParamsKey(activationHeight int64) []byte {
+	return append(append([]byte{}, ScheduledParamsPrefix...), sdk.Uint64ToBigEndian(uint64(activationHeight))...)
+}
+
+// mustUnmarshalParams unmarshals stored params over the default params
+// Params stored before a field was added don't have it, so the missing fields keep their default value
+func mustUnmarshalParams(bz []byte) FeeHandlerParams {
+	params := DefaultFeeHandlerParams()
+	mustUnmarshalJSON(bz, &params)
+	return params
+}
+
+// mustUnmarshalScheduledParamsUpdate unmarshals a stored params update, the missing params keep their default value
+func mustUnmarshalScheduledParamsUpdate(bz []byte) ScheduledParamsUpdate {
+	update := ScheduledParamsUpdate{Params: DefaultFeeHandlerParams()}
+	mustUnmarshalJSON(bz, &update)
+	return update
+}
+
+// mustMarshalJSON marshals a value that is always valid JSON, panics otherwise
+func mustMarshalJSON(v any) []byte {
+	bz, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return bz
+}
+
+// mustUnmarshalJSON unmarshals a value stored by the keeper, panics on corrupted state
+func mustUnmarshalJSON(bz []byte, v any) {
+	if err := json.Unmarshal(bz, v); err != nil {
+		panic(err)
+	}
+}
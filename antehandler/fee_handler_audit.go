@@ -0,0 +1,186 @@
+// Block fees audit of the simulated feeHandler module
+// The byte fees and the regular TX fees charged on a block are summed on a transient store
+// At the end of the block, the fee collector balance delta must match the sum of both fees
+// The audit is done on the ante phase instead of a PostHandler, since the ante state is kept even if the msgs fail
+// The fee collector snapshot is taken by the FeeAuditDecorator on the first TX of the block, after all the BeginBlockers
+// So the fees distributed on BeginBlock (e.g. by the distribution module) are never part of the delta, whatever the module order
+// A mismatch means that fees were lost or minted, so it is registered as a crisis invariant
+package antehandler
+
+import (
+	"fmt"
+	"strconv"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+)
+
+const (
+	// Events emitted by the block fees audit
+	EventTypeBlockFeesAudit      = "fee_handler_block_fees_audit"
+	AttributeKeyBlockBytesFees   = "bytes_fees"
+	AttributeKeyBlockRegularFees = "regular_fees"
+	AttributeKeyCollectorDelta   = "fee_collector_delta"
+	AttributeKeyBalanced         = "balanced"
+
+	// ByteFeesInvariantRoute is the route of the byte fees invariant
+	ByteFeesInvariantRoute = "byte-fees"
+)
+
+var (
+	// BlockBytesFeesKey is the transient key of the byte fees charged on the current block
+	BlockBytesFeesKey = []byte{0x01}
+	// BlockRegularFeesKey is the transient key of the regular TX fees charged on the current block
+	BlockRegularFeesKey = []byte{0x02}
+	// FeeCollectorSnapshotKey is the transient key of the fee collector balance before the first fee of the block
+	FeeCollectorSnapshotKey = []byte{0x03}
+)
+
+// Assert that the FeeAuditKeeper interface is implemented by the keeper
+var _ FeeAuditKeeper = (*FeeHandlerKeeper)(nil)
+
+// BlockFeesAudit is the result of the reconciliation of the fees charged on a block
+type BlockFeesAudit struct {
+	// BytesFees are the byte fees charged by the weighted fee antehandler
+	BytesFees sdk.Coins
+	// RegularFees are the TX fees charged by the regular fee antehandler
+	RegularFees sdk.Coins
+	// CollectorDelta is the increase of the fee collector balance since the beginning of the block
+	CollectorDelta sdk.Coins
+	// Balanced is true when the fee collector delta matches the sum of the fees
+	Balanced bool
+}
+
+// TrackBytesFee registers the byte fees charged on the current block
+func (k FeeHandlerKeeper) TrackBytesFee(ctx sdk.Context, fee sdk.Coins) {
+	k.addBlockFees(ctx, BlockBytesFeesKey, fee)
+}
+
+// TrackRegularFee registers the regular TX fees charged on the current block
+func (k FeeHandlerKeeper) TrackRegularFee(ctx sdk.Context, fee sdk.Coins) {
+	k.addBlockFees(ctx, BlockRegularFeesKey, fee)
+}
+
+// GetBlockBytesFees returns the byte fees charged on the current block
+func (k FeeHandlerKeeper) GetBlockBytesFees(ctx sdk.Context) sdk.Coins {
+	return k.getTransientCoins(ctx, BlockBytesFeesKey)
+}
+
+// GetBlockRegularFees returns the regular TX fees charged on the current block
+func (k FeeHandlerKeeper) GetBlockRegularFees(ctx sdk.Context) sdk.Coins {
+	return k.getTransientCoins(ctx, BlockRegularFeesKey)
+}
+
+// AuditBlockFees compares the fee collector balance delta with the fees charged on the current block
+// The fee collector snapshot is taken on the first TX, without it no fee was charged and the block is balanced
+func (k FeeHandlerKeeper) AuditBlockFees(ctx sdk.Context) BlockFeesAudit {
+	audit := BlockFeesAudit{
+		BytesFees:      k.GetBlockBytesFees(ctx),
+		RegularFees:    k.GetBlockRegularFees(ctx),
+		CollectorDelta: sdk.NewCoins(),
+		Balanced:       true,
+	}
+
+	snapshotBz := ctx.TransientStore(k.transientStoreKey).Get(FeeCollectorSnapshotKey)
+	if snapshotBz == nil {
+		return audit
+	}
+	var snapshot sdk.Coins
+	mustUnmarshalJSON(snapshotBz, &snapshot)
+
+	// The fee collector balance can't go down after the first TX, the fees are only distributed on BeginBlock
+	delta, hasNeg := k.feeCollectorBalance(ctx).SafeSub(snapshot...)
+	if hasNeg {
+		audit.Balanced = false
+		return audit
+	}
+	audit.CollectorDelta = delta
+
+	expected := audit.BytesFees.Add(audit.RegularFees...)
+	audit.Balanced = coinsEqual(delta, expected)
+
+	return audit
+}
+
+// EndBlock audits the fees charged on the block and emits the result as a event
+func (k FeeHandlerKeeper) EndBlock(ctx sdk.Context) BlockFeesAudit {
+	audit := k.AuditBlockFees(ctx)
+
+	ctx.EventManager().EmitEvent(
+		sdk.NewEvent(
+			EventTypeBlockFeesAudit,
+			sdk.NewAttribute(AttributeKeyBlockBytesFees, audit.BytesFees.String()),
+			sdk.NewAttribute(AttributeKeyBlockRegularFees, audit.RegularFees.String()),
+			sdk.NewAttribute(AttributeKeyCollectorDelta, audit.CollectorDelta.String()),
+			sdk.NewAttribute(AttributeKeyBalanced, strconv.FormatBool(audit.Balanced)),
+		),
+	)
+
+	return audit
+}
+
+// RegisterInvariants registers the feeHandler invariants on the crisis module
+// The byte fees invariant requires the FeeAuditDecorator to be placed before the DeductFeeDecorator
+func RegisterInvariants(ir sdk.InvariantRegistry, k FeeHandlerKeeper) {
+	ir.RegisterRoute(FeeHandlerModuleName, ByteFeesInvariantRoute, ByteFeesInvariant(k))
+}
+
+// ByteFeesInvariant checks that the fee collector received exactly the fees charged on the current block
+func ByteFeesInvariant(k FeeHandlerKeeper) sdk.Invariant {
+	return func(ctx sdk.Context) (string, bool) {
+		audit := k.AuditBlockFees(ctx)
+
+		return sdk.FormatInvariant(
+			FeeHandlerModuleName, ByteFeesInvariantRoute,
+			fmt.Sprintf(
+				"\tbytes fees: %s\n\tregular fees: %s\n\tfee collector delta: %s\n",
+				audit.BytesFees, audit.RegularFees, audit.CollectorDelta,
+			),
+		), !audit.Balanced
+	}
+}
+
+// SnapshotFeeCollectorBalance stores the fee collector balance before the first fee of the current block
+// The snapshot is only taken once per block, the next calls are no-ops
+func (k FeeHandlerKeeper) SnapshotFeeCollectorBalance(ctx sdk.Context) {
+	if k.bankKeeper == nil {
+		return
+	}
+	store := ctx.TransientStore(k.transientStoreKey)
+	if store.Has(FeeCollectorSnapshotKey) {
+		return
+	}
+	store.Set(FeeCollectorSnapshotKey, mustMarshalJSON(k.feeCollectorBalance(ctx)))
+}
+
+// feeCollectorBalance returns the current balance of the fee collector module account
+func (k FeeHandlerKeeper) feeCollectorBalance(ctx sdk.Context) sdk.Coins {
+	return k.bankKeeper.GetAllBalances(ctx, authtypes.NewModuleAddress(authtypes.FeeCollectorName))
+}
+
+// addBlockFees adds the fees to the block total stored on the given transient key
+func (k FeeHandlerKeeper) addBlockFees(ctx sdk.Context, key []byte, fee sdk.Coins) {
+	if fee.IsZero() {
+		return
+	}
+	total := k.getTransientCoins(ctx, key).Add(fee...)
+	ctx.TransientStore(k.transientStoreKey).Set(key, mustMarshalJSON(total))
+}
+
+// getTransientCoins returns the coins stored on the given transient key, empty if not set
+func (k FeeHandlerKeeper) getTransientCoins(ctx sdk.Context, key []byte) sdk.Coins {
+	bz := ctx.TransientStore(k.transientStoreKey).Get(key)
+	if bz == nil {
+		return sdk.NewCoins()
+	}
+
+	var coins sdk.Coins
+	mustUnmarshalJSON(bz, &coins)
+	return coins
+}
+
+// coinsEqual returns true if both coins have the same denoms and amounts
+// Unlike Coins.IsEqual, it doesn't panic when the denoms differ
+func coinsEqual(a, b sdk.Coins) bool {
+	return a.IsAllGTE(b) && b.IsAllGTE(a)
+}
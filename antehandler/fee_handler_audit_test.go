@@ -0,0 +1,131 @@
+package antehandler_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"cosmossdk.io/math"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	authante "github.com/cosmos/cosmos-sdk/x/auth/ante"
+	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+
+	ante "ibc-fee/antehandler"
+)
+
+// newAuditAnteHandler returns a antehandler charging the regular and the byte fees, both registered on the audit
+func newAuditAnteHandler(s *IntegrationTestSuite) sdk.AnteHandler {
+	return sdk.ChainAnteDecorators(
+		ante.NewFeeAuditDecorator(s.feeHandlerKeeper),
+		authante.NewDeductFeeDecorator(s.accountKeeper, s.bankKeeper, nil, nil),
+		ante.NewWeightedFeeDecorator(s.bankKeeper, s.feeHandlerKeeper),
+	)
+}
+
+// TestBlockFeesAudit tests the reconciliation of the block fees with the fee collector balance
+func TestBlockFeesAudit(t *testing.T) {
+	s := SetupIntegrationTestSuite(t, false)
+	require.NoError(t, s.feeHandlerKeeper.SetParams(s.ctx, newTestParams(1, DefaultMinTxSize)))
+	antehandler := newAuditAnteHandler(s)
+	invariant := ante.ByteFeesInvariant(s.feeHandlerKeeper)
+
+	initialBalance := sdk.NewCoins(sdk.NewCoin("testcoin", math.NewInt(100_000)))
+	payer := s.CreateFundedAccount(t, initialBalance)
+	receiver := s.CreateFundedAccount(t, nil)
+
+	// Fees already on the fee collector from previous blocks are not part of the audit
+	previousFees := sdk.NewCoins(sdk.NewCoin("testcoin", math.NewInt(1_000)))
+	require.NoError(t, s.bankKeeper.SendCoinsFromAccountToModule(s.ctx, payer.Address, authtypes.FeeCollectorName, previousFees))
+	s.feeHandlerKeeper.BeginBlock(s.ctx)
+
+	// Run a few TXs paying both the regular and the byte fees
+	regularFee := sdk.NewCoins(sdk.NewCoin("testcoin", math.NewInt(50)))
+	expectedBytesFees := sdk.NewCoins()
+	for i := 1; i <= 3; i++ {
+		tx := s.CreateSignedTxWithFee(t, newBankSendMsgs(payer, receiver, i), regularFee, payer)
+		_, err := s.RunAnteHandler(antehandler, tx)
+		require.NoError(t, err)
+		expectedBytesFees = expectedBytesFees.Add(expectedBytesFee(t, s, tx)...)
+	}
+	require.False(t, expectedBytesFees.IsZero())
+
+	// A failed TX doesn't leave any fee behind
+	errFailingDecorator := fmt.Errorf("failing decorator")
+	failingAnteHandler := sdk.ChainAnteDecorators(
+		ante.NewFeeAuditDecorator(s.feeHandlerKeeper),
+		authante.NewDeductFeeDecorator(s.accountKeeper, s.bankKeeper, nil, nil),
+		ante.NewWeightedFeeDecorator(s.bankKeeper, s.feeHandlerKeeper),
+		failingDecorator{err: errFailingDecorator},
+	)
+	tx := s.CreateSignedTxWithFee(t, newBankSendMsgs(payer, receiver, 2), regularFee, payer)
+	_, err := s.RunAnteHandler(failingAnteHandler, tx)
+	require.ErrorIs(t, err, errFailingDecorator)
+
+	// The fee collector received exactly the charged fees
+	audit := s.feeHandlerKeeper.EndBlock(s.ctx)
+	require.True(t, audit.Balanced)
+	require.Equal(t, expectedBytesFees, audit.BytesFees)
+	require.Equal(t, sdk.NewCoins(sdk.NewCoin("testcoin", math.NewInt(150))), audit.RegularFees)
+	require.Equal(t, audit.BytesFees.Add(audit.RegularFees...), audit.CollectorDelta)
+
+	msg, broken := invariant(s.ctx)
+	require.False(t, broken, msg)
+
+	// Coins sent to the fee collector without being charged as fees break the invariant
+	untrackedFees := sdk.NewCoins(sdk.NewCoin("testcoin", math.NewInt(1)))
+	require.NoError(t, s.bankKeeper.SendCoinsFromAccountToModule(s.ctx, payer.Address, authtypes.FeeCollectorName, untrackedFees))
+
+	audit = s.feeHandlerKeeper.EndBlock(s.ctx)
+	require.False(t, audit.Balanced)
+	_, broken = invariant(s.ctx)
+	require.True(t, broken)
+}
+
+// TestBlockFeesAuditDistributedFees tests the fee collector being drained after the feeHandler BeginBlock
+// This is the case when the distribution BeginBlocker runs after the feeHandler one
+func TestBlockFeesAuditDistributedFees(t *testing.T) {
+	s := SetupIntegrationTestSuite(t, false)
+	require.NoError(t, s.feeHandlerKeeper.SetParams(s.ctx, newTestParams(1, DefaultMinTxSize)))
+	antehandler := newAuditAnteHandler(s)
+
+	payer := s.CreateFundedAccount(t, sdk.NewCoins(sdk.NewCoin("testcoin", math.NewInt(100_000))))
+	receiver := s.CreateFundedAccount(t, nil)
+	distribution := s.CreateFundedAccount(t, nil)
+
+	// Fees of the previous block are on the fee collector when the feeHandler BeginBlock runs
+	previousFees := sdk.NewCoins(sdk.NewCoin("testcoin", math.NewInt(1_000)))
+	require.NoError(t, s.bankKeeper.SendCoinsFromAccountToModule(s.ctx, payer.Address, authtypes.FeeCollectorName, previousFees))
+	s.feeHandlerKeeper.BeginBlock(s.ctx)
+
+	// A later BeginBlocker distributes them
+	require.NoError(t, s.bankKeeper.SendCoinsFromModuleToAccount(s.ctx, authtypes.FeeCollectorName, distribution.Address, previousFees))
+
+	// The snapshot is taken on the first TX, after the fees were distributed
+	regularFee := sdk.NewCoins(sdk.NewCoin("testcoin", math.NewInt(50)))
+	expectedBytesFees := sdk.NewCoins()
+	for i := 1; i <= 2; i++ {
+		tx := s.CreateSignedTxWithFee(t, newBankSendMsgs(payer, receiver, i), regularFee, payer)
+		_, err := s.RunAnteHandler(antehandler, tx)
+		require.NoError(t, err)
+		expectedBytesFees = expectedBytesFees.Add(expectedBytesFee(t, s, tx)...)
+	}
+
+	audit := s.feeHandlerKeeper.EndBlock(s.ctx)
+	require.True(t, audit.Balanced)
+	require.Equal(t, sdk.NewCoins(sdk.NewCoin("testcoin", math.NewInt(100))), audit.RegularFees)
+	require.Equal(t, expectedBytesFees.Add(audit.RegularFees...), audit.CollectorDelta)
+
+	msg, broken := ante.ByteFeesInvariant(s.feeHandlerKeeper)(s.ctx)
+	require.False(t, broken, msg)
+}
+
+// TestBlockFeesAuditWithoutSnapshot tests that the audit doesn't fail on a block without TXs
+func TestBlockFeesAuditWithoutSnapshot(t *testing.T) {
+	s := SetupIntegrationTestSuite(t, false)
+	payer := s.CreateFundedAccount(t, sdk.NewCoins(sdk.NewCoin("testcoin", math.NewInt(100))))
+	require.NoError(t, s.bankKeeper.SendCoinsFromAccountToModule(s.ctx, payer.Address, authtypes.FeeCollectorName, sdk.NewCoins(sdk.NewCoin("testcoin", math.NewInt(10)))))
+
+	_, broken := ante.ByteFeesInvariant(s.feeHandlerKeeper)(s.ctx)
+	require.False(t, broken)
+}
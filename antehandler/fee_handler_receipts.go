@@ -0,0 +1,106 @@
+// Byte fee receipts of the simulated feeHandler module
+// A compact receipt is stored for each TX charged with byte fees, keyed by the TX hash
+// Receipts are indexed by height, so the ones older than the retention are pruned on BeginBlock
+package antehandler
+
+import (
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+var (
+	// ReceiptsPrefix is the prefix of the byte fee receipts, keyed by TX hash
+	ReceiptsPrefix = []byte{0x04}
+	// ReceiptsByHeightPrefix is the prefix of the receipts height index, keyed by height and TX hash
+	ReceiptsByHeightPrefix = []byte{0x05}
+)
+
+// BytesFeeReceipt is the record of the byte fee charged on a TX
+type BytesFeeReceipt struct {
+	// Height is the height where the TX was charged
+	Height int64
+	// FeePayer is the account charged with the byte fee
+	FeePayer string
+	// TxSize is the priced size of the TX, excluding the memo if it has its own price
+	TxSize uint64
+	// ExtraBytes are the bytes charged above the min TX size
+	ExtraBytes uint64
+	// BytePrice is the effective byte price, after the oracle conversion and the surge multiplier
+	BytePrice sdk.DecCoins
+	// ExtraMemoBytes are the memo bytes charged above the free memo bytes
+	ExtraMemoBytes uint64
+	// MemoBytePrice is the effective memo byte price
+	MemoBytePrice sdk.DecCoins
+	// Amount is the total byte fee charged, including the memo fee
+	Amount sdk.Coins
+}
+
+// SetBytesFeeReceipt stores the byte fee receipt of a TX
+// The receipt and its height index are written with the TX gas meter, so the TX pays for them
+// Nothing is stored if the receipts are disabled
+func (k FeeHandlerKeeper) SetBytesFeeReceipt(ctx sdk.Context, txHash []byte, receipt BytesFeeReceipt) {
+	if !k.GetParams(ctx).IsReceiptsEnabled() {
+		return
+	}
+
+	store := ctx.KVStore(k.storeKey)
+	store.Set(receiptKey(txHash), mustMarshalJSON(receipt))
+	store.Set(receiptHeightKey(receipt.Height, txHash), []byte{})
+}
+
+// GetBytesFeeReceipt returns the byte fee receipt of a TX
+// This is the query used by the support tooling to explain the byte fee charged on a TX
+func (k FeeHandlerKeeper) GetBytesFeeReceipt(ctx sdk.Context, txHash []byte) (BytesFeeReceipt, bool) {
+	bz := ctx.KVStore(k.storeKey).Get(receiptKey(txHash))
+	if bz == nil {
+		return BytesFeeReceipt{}, false
+	}
+
+	var receipt BytesFeeReceipt
+	mustUnmarshalJSON(bz, &receipt)
+	return receipt, true
+}
+
+// pruneBytesFeeReceipts removes the receipts stored more than the retention blocks ago
+// If the receipts are disabled, all the remaining receipts are pruned
+func (k FeeHandlerKeeper) pruneBytesFeeReceipts(ctx sdk.Context) {
+	retentionBlocks := k.GetParams(ctx).ReceiptRetentionBlocks
+
+	// The receipts at heights lower than the first retained height are removed
+	firstRetainedHeight := ctx.BlockHeight() - int64(retentionBlocks)
+	if firstRetainedHeight <= 0 {
+		return
+	}
+
+	store := ctx.KVStore(k.storeKey)
+
+	// Collect the stale keys first, the store can't be changed while iterating
+	// The index keys are ordered by height
+	staleIndexKeys := [][]byte{}
+	iterator := store.Iterator(ReceiptsByHeightPrefix, receiptHeightPrefix(firstRetainedHeight))
+	for ; iterator.Valid(); iterator.Next() {
+		staleIndexKeys = append(staleIndexKeys, iterator.Key())
+	}
+	iterator.Close()
+
+	for _, indexKey := range staleIndexKeys {
+		txHash := indexKey[len(ReceiptsByHeightPrefix)+8:]
+		store.Delete(receiptKey(txHash))
+		store.Delete(indexKey)
+	}
+}
+
+// receiptKey returns the store key of a byte fee receipt
+func receiptKey(txHash []byte) []byte {
+	return append(append([]byte{}, ReceiptsPrefix...), txHash...)
+}
+
+// receiptHeightPrefix returns the prefix of the receipts index at a height
+// The height is big endian encoded so the store iteration is ordered by height
+func receiptHeightPrefix(height int64) []byte {
+	return append(append([]byte{}, ReceiptsByHeightPrefix...), sdk.Uint64ToBigEndian(uint64(height))...)
+}
+
+// receiptHeightKey returns the store key of a receipt on the height index
+func receiptHeightKey(height int64, txHash []byte) []byte {
+	return append(receiptHeightPrefix(height), txHash...)
+}
@@ -0,0 +1,164 @@
+package antehandler_test
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/cometbft/cometbft/crypto/tmhash"
+	"github.com/stretchr/testify/require"
+
+	"cosmossdk.io/math"
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	errortypes "github.com/cosmos/cosmos-sdk/types/errors"
+	authtx "github.com/cosmos/cosmos-sdk/x/auth/tx"
+
+	ante "ibc-fee/antehandler"
+)
+
+// newTestReceipt returns a receipt charged at the given height
+func newTestReceipt(height int64) ante.BytesFeeReceipt {
+	return ante.BytesFeeReceipt{
+		Height:         height,
+		FeePayer:       sdk.AccAddress([]byte("payer")).String(),
+		TxSize:         150,
+		ExtraBytes:     50,
+		BytePrice:      DefaultFeeBytePrice,
+		ExtraMemoBytes: 0,
+		MemoBytePrice:  sdk.NewDecCoins(),
+		Amount:         sdk.NewCoins(sdk.NewCoin("testcoin", math.NewInt(50))),
+	}
+}
+
+// TestBytesFeeReceiptsPruning tests storing receipts and pruning them after the retention blocks
+func TestBytesFeeReceiptsPruning(t *testing.T) {
+	ctx, keeper := setupFeeHandlerKeeper(t, 10)
+	params := newTestParams(1, DefaultMinTxSize)
+	params.ReceiptRetentionBlocks = 5
+	require.NoError(t, keeper.SetParams(ctx, params))
+
+	// Store a receipt at height 10 and 12
+	oldHash := tmhash.Sum([]byte("old tx"))
+	newHash := tmhash.Sum([]byte("new tx"))
+	keeper.SetBytesFeeReceipt(ctx, oldHash, newTestReceipt(10))
+	keeper.SetBytesFeeReceipt(ctx.WithBlockHeight(12), newHash, newTestReceipt(12))
+
+	receipt, found := keeper.GetBytesFeeReceipt(ctx, oldHash)
+	require.True(t, found)
+	require.Equal(t, newTestReceipt(10), receipt)
+
+	// The receipts are kept during the retention blocks
+	keeper.BeginBlock(ctx.WithBlockHeight(15))
+	_, found = keeper.GetBytesFeeReceipt(ctx, oldHash)
+	require.True(t, found)
+
+	// The old receipt is pruned once it leaves the retention
+	keeper.BeginBlock(ctx.WithBlockHeight(16))
+	_, found = keeper.GetBytesFeeReceipt(ctx, oldHash)
+	require.False(t, found)
+	_, found = keeper.GetBytesFeeReceipt(ctx, newHash)
+	require.True(t, found)
+
+	// Disabling the receipts prunes the remaining ones and stops storing new ones
+	params.ReceiptRetentionBlocks = 0
+	require.NoError(t, keeper.SetParams(ctx, params))
+	keeper.BeginBlock(ctx.WithBlockHeight(17))
+	_, found = keeper.GetBytesFeeReceipt(ctx, newHash)
+	require.False(t, found)
+
+	keeper.SetBytesFeeReceipt(ctx.WithBlockHeight(17), oldHash, newTestReceipt(17))
+	_, found = keeper.GetBytesFeeReceipt(ctx, oldHash)
+	require.False(t, found)
+}
+
+// TestWeightedFeeAnteIntegrationReceipts tests the receipt stored for a charged TX
+func TestWeightedFeeAnteIntegrationReceipts(t *testing.T) {
+	s := SetupIntegrationTestSuite(t, false)
+	require.NoError(t, s.feeHandlerKeeper.SetParams(s.ctx, newTestParams(1, DefaultMinTxSize)))
+	antehandler := sdk.ChainAnteDecorators(
+		ante.NewWeightedFeeDecorator(s.bankKeeper, s.feeHandlerKeeper),
+	)
+
+	payer := s.CreateFundedAccount(t, sdk.NewCoins(sdk.NewCoin("testcoin", math.NewInt(10_000))))
+	receiver := s.CreateFundedAccount(t, nil)
+	tx := s.CreateSignedTx(t, newBankSendMsgs(payer, receiver, 2), payer)
+
+	_, err := s.RunAnteHandler(antehandler, tx)
+	require.NoError(t, err)
+
+	// The receipt is found by the TX hash
+	txBytes, err := authtx.DefaultTxEncoder()(tx)
+	require.NoError(t, err)
+	receipt, found := s.feeHandlerKeeper.GetBytesFeeReceipt(s.ctx, tmhash.Sum(txBytes))
+	require.True(t, found)
+
+	expectedFee := expectedBytesFee(t, s, tx)
+	require.Equal(t, s.ctx.BlockHeight(), receipt.Height)
+	require.Equal(t, payer.Address.String(), receipt.FeePayer)
+	require.Equal(t, uint64(len(txBytes)), receipt.TxSize)
+	require.Equal(t, uint64(len(txBytes))-DefaultMinTxSize, receipt.ExtraBytes)
+	require.Equal(t, DefaultFeeBytePrice, receipt.BytePrice)
+	require.Equal(t, expectedFee, receipt.Amount)
+}
+
+// TestQueryBytesFeeReceipt tests the receipt query by hex encoded TX hash
+func TestQueryBytesFeeReceipt(t *testing.T) {
+	ctx, keeper := setupFeeHandlerKeeper(t, 10)
+	require.NoError(t, keeper.SetParams(ctx, newTestParams(1, DefaultMinTxSize)))
+	querier := ante.NewQuerier(keeper)
+
+	txHash := tmhash.Sum([]byte("tx"))
+	keeper.SetBytesFeeReceipt(ctx, txHash, newTestReceipt(10))
+
+	res, err := querier.BytesFeeReceipt(sdk.WrapSDKContext(ctx), &ante.QueryBytesFeeReceiptRequest{TxHash: hex.EncodeToString(txHash)})
+	require.NoError(t, err)
+	require.Equal(t, newTestReceipt(10), res.Receipt)
+
+	// Upper case hashes, as shown by the explorers, are also accepted
+	res, err = querier.BytesFeeReceipt(sdk.WrapSDKContext(ctx), &ante.QueryBytesFeeReceiptRequest{TxHash: strings.ToUpper(hex.EncodeToString(txHash))})
+	require.NoError(t, err)
+	require.Equal(t, newTestReceipt(10), res.Receipt)
+
+	_, err = querier.BytesFeeReceipt(sdk.WrapSDKContext(ctx), &ante.QueryBytesFeeReceiptRequest{TxHash: hex.EncodeToString(tmhash.Sum([]byte("other tx")))})
+	require.ErrorIs(t, err, ante.ErrReceiptNotFound)
+
+	for _, req := range []*ante.QueryBytesFeeReceiptRequest{nil, {TxHash: ""}, {TxHash: "not hex"}} {
+		_, err = querier.BytesFeeReceipt(sdk.WrapSDKContext(ctx), req)
+		require.ErrorIs(t, err, errortypes.ErrInvalidRequest)
+	}
+}
+
+// TestWeightedFeeAnteIntegrationReceiptsGas tests the gas the receipt writes add to a charged TX
+// The TX pays the two store writes of the receipt and its height index, disabling the receipts removes them
+func TestWeightedFeeAnteIntegrationReceiptsGas(t *testing.T) {
+	s := SetupIntegrationTestSuite(t, false)
+	antehandler := sdk.ChainAnteDecorators(
+		ante.NewWeightedFeeDecorator(s.bankKeeper, s.feeHandlerKeeper),
+	)
+
+	payer := s.CreateFundedAccount(t, sdk.NewCoins(sdk.NewCoin("testcoin", math.NewInt(10_000))))
+	receiver := s.CreateFundedAccount(t, nil)
+	tx := s.CreateSignedTx(t, newBankSendMsgs(payer, receiver, 2), payer)
+
+	gasUsed := func(retentionBlocks uint64) uint64 {
+		params := newTestParams(1, DefaultMinTxSize)
+		params.ReceiptRetentionBlocks = retentionBlocks
+		require.NoError(t, s.feeHandlerKeeper.SetParams(s.ctx, params))
+
+		gasBefore := s.ctx.GasMeter().GasConsumed()
+		_, err := s.RunAnteHandler(antehandler, tx)
+		require.NoError(t, err)
+		return s.ctx.GasMeter().GasConsumed() - gasBefore
+	}
+
+	// The first run pays the first writes of the fee collector balance
+	gasUsed(0)
+	withoutReceipts := gasUsed(0)
+	withReceipts := gasUsed(ante.DefaultReceiptRetentionBlocks)
+	receiptsGas := withReceipts - withoutReceipts
+
+	// Each write pays a flat cost plus a cost per byte, a receipt of a simple TX costs about 14k gas
+	require.Greater(t, receiptsGas, 2*storetypes.KVGasConfig().WriteCostFlat)
+	require.Less(t, receiptsGas, uint64(20_000))
+}
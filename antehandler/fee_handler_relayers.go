@@ -0,0 +1,156 @@
+// Relayer registry of the simulated feeHandler module
+// Governance registers the relayers that get a discount on the byte fees of IBC core TXs
+// A relayer can be scoped to a list of channels, otherwise it is registered for every channel
+package antehandler
+
+import (
+	errorsmod "cosmossdk.io/errors"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/address"
+	host "github.com/cosmos/ibc-go/v7/modules/core/24-host"
+)
+
+// RelayersPrefix is the prefix of the registered relayers, keyed by relayer address
+var RelayersPrefix = []byte{0x06}
+
+// Relayer is a relayer registered by governance
+type Relayer struct {
+	// Address is the account of the relayer
+	Address string
+	// Channels are the channels on this chain where the relayer gets the discount
+	// If empty, the relayer gets the discount on every channel
+	Channels []string
+}
+
+// RelayerStatus is the registration status of a address
+type RelayerStatus struct {
+	// Registered is true if the address is a registered relayer
+	Registered bool
+	// Channels are the channels where the relayer is registered, empty means every channel
+	Channels []string
+	// ByteFeeDiscount is the current discount applied to the byte fees of the relayer
+	ByteFeeDiscount sdk.Dec
+}
+
+// Validate validates the relayer address and channels
+func (r Relayer) Validate() error {
+	if _, err := sdk.AccAddressFromBech32(r.Address); err != nil {
+		return errorsmod.Wrapf(ErrInvalidRelayer, "invalid relayer address: %s", err)
+	}
+
+	seenChannels := make(map[string]bool)
+	for _, channel := range r.Channels {
+		if err := host.ChannelIdentifierValidator(channel); err != nil {
+			return errorsmod.Wrapf(ErrInvalidRelayer, "invalid relayer channel: %s", err)
+		}
+		if seenChannels[channel] {
+			return errorsmod.Wrapf(ErrInvalidRelayer, "duplicated relayer channel %s", channel)
+		}
+		seenChannels[channel] = true
+	}
+
+	return nil
+}
+
+// IsChannelAllowed returns true if the relayer gets the discount on the channel
+// A empty channel, for the msgs not tied to a channel, is only allowed for the relayers of every channel
+func (r Relayer) IsChannelAllowed(channel string) bool {
+	if len(r.Channels) == 0 {
+		return true
+	}
+	for _, allowedChannel := range r.Channels {
+		if allowedChannel == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// SetRelayer registers a relayer, or replaces the channels of a registered relayer
+// Only the authority can register relayers
+func (k FeeHandlerKeeper) SetRelayer(ctx sdk.Context, authority string, relayer Relayer) error {
+	if err := k.validateAuthority(authority); err != nil {
+		return err
+	}
+	if err := relayer.Validate(); err != nil {
+		return err
+	}
+
+	k.setRelayer(ctx, relayer)
+	return nil
+}
+
+// setRelayer stores a relayer that was already validated
+func (k FeeHandlerKeeper) setRelayer(ctx sdk.Context, relayer Relayer) {
+	relayerAddr := sdk.MustAccAddressFromBech32(relayer.Address)
+	ctx.KVStore(k.storeKey).Set(relayerKey(relayerAddr), mustMarshalJSON(relayer))
+}
+
+// RemoveRelayer removes a registered relayer
+// Only the authority can remove relayers
+func (k FeeHandlerKeeper) RemoveRelayer(ctx sdk.Context, authority string, relayerAddr sdk.AccAddress) error {
+	if err := k.validateAuthority(authority); err != nil {
+		return err
+	}
+
+	store := ctx.KVStore(k.storeKey)
+	key := relayerKey(relayerAddr)
+	if !store.Has(key) {
+		return errorsmod.Wrapf(ErrRelayerNotFound, "relayer %s is not registered", relayerAddr)
+	}
+
+	store.Delete(key)
+	return nil
+}
+
+// GetRelayer returns a registered relayer
+func (k FeeHandlerKeeper) GetRelayer(ctx sdk.Context, relayerAddr sdk.AccAddress) (Relayer, bool) {
+	bz := ctx.KVStore(k.storeKey).Get(relayerKey(relayerAddr))
+	if bz == nil {
+		return Relayer{}, false
+	}
+
+	var relayer Relayer
+	mustUnmarshalJSON(bz, &relayer)
+	return relayer, true
+}
+
+// GetRelayers returns all the registered relayers
+func (k FeeHandlerKeeper) GetRelayers(ctx sdk.Context) []Relayer {
+	iterator := sdk.KVStorePrefixIterator(ctx.KVStore(k.storeKey), RelayersPrefix)
+	defer iterator.Close()
+
+	relayers := []Relayer{}
+	for ; iterator.Valid(); iterator.Next() {
+		var relayer Relayer
+		mustUnmarshalJSON(iterator.Value(), &relayer)
+		relayers = append(relayers, relayer)
+	}
+
+	return relayers
+}
+
+// GetRelayerStatus returns the registration status of a address
+// This is the query used by the relayers to check their own status
+func (k FeeHandlerKeeper) GetRelayerStatus(ctx sdk.Context, relayerAddr sdk.AccAddress) RelayerStatus {
+	relayer, found := k.GetRelayer(ctx, relayerAddr)
+	if !found {
+		return RelayerStatus{Registered: false, Channels: []string{}, ByteFeeDiscount: sdk.ZeroDec()}
+	}
+
+	channels := relayer.Channels
+	if channels == nil {
+		channels = []string{}
+	}
+
+	return RelayerStatus{
+		Registered:      true,
+		Channels:        channels,
+		ByteFeeDiscount: k.GetParams(ctx).RelayerByteFeeDiscount,
+	}
+}
+
+// relayerKey returns the store key of a registered relayer
+func relayerKey(relayerAddr sdk.AccAddress) []byte {
+	return append(append([]byte{}, RelayersPrefix...), address.MustLengthPrefix(relayerAddr)...)
+}
@@ -0,0 +1,127 @@
+package antehandler_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	errortypes "github.com/cosmos/cosmos-sdk/types/errors"
+
+	ante "ibc-fee/antehandler"
+)
+
+// TestRelayerRegistry tests registering, querying and removing relayers
+func TestRelayerRegistry(t *testing.T) {
+	ctx, keeper := setupFeeHandlerKeeper(t, 1)
+	authority := keeper.GetAuthority()
+	relayerAddr := sdk.AccAddress([]byte("relayer"))
+	relayer := ante.Relayer{Address: relayerAddr.String(), Channels: []string{"channel-0"}}
+
+	// All the test cases
+	testCases := []struct {
+		name        string
+		authority   string
+		relayer     ante.Relayer
+		expectedErr error
+	}{
+		{
+			name:      "Valid relayer",
+			authority: authority,
+			relayer:   relayer,
+		},
+		{
+			name:        "Invalid authority",
+			authority:   relayerAddr.String(),
+			relayer:     relayer,
+			expectedErr: ante.ErrInvalidAuthority,
+		},
+		{
+			name:        "Invalid address",
+			authority:   authority,
+			relayer:     ante.Relayer{Address: "invalid"},
+			expectedErr: ante.ErrInvalidRelayer,
+		},
+		{
+			name:        "Invalid channel",
+			authority:   authority,
+			relayer:     ante.Relayer{Address: relayerAddr.String(), Channels: []string{"c"}},
+			expectedErr: ante.ErrInvalidRelayer,
+		},
+		{
+			name:        "Duplicated channel",
+			authority:   authority,
+			relayer:     ante.Relayer{Address: relayerAddr.String(), Channels: []string{"channel-0", "channel-0"}},
+			expectedErr: ante.ErrInvalidRelayer,
+		},
+	}
+
+	for _, tc := range testCases {
+		err := keeper.SetRelayer(ctx, tc.authority, tc.relayer)
+		if tc.expectedErr != nil {
+			require.ErrorIs(t, err, tc.expectedErr, tc.name)
+		} else {
+			require.NoError(t, err, tc.name)
+		}
+	}
+
+	// Only the valid relayer is registered
+	require.Equal(t, []ante.Relayer{relayer}, keeper.GetRelayers(ctx))
+
+	// The relayer can check its own status, there is no discount by default
+	status := keeper.GetRelayerStatus(ctx, relayerAddr)
+	require.True(t, status.Registered)
+	require.Equal(t, relayer.Channels, status.Channels)
+	require.Equal(t, sdk.ZeroDec(), status.ByteFeeDiscount)
+
+	params := ante.DefaultFeeHandlerParams()
+	params.RelayerByteFeeDiscount = sdk.OneDec()
+	require.NoError(t, keeper.SetParams(ctx, params))
+	require.Equal(t, sdk.OneDec(), keeper.GetRelayerStatus(ctx, relayerAddr).ByteFeeDiscount)
+
+	// Remove the relayer
+	require.ErrorIs(t, keeper.RemoveRelayer(ctx, relayerAddr.String(), relayerAddr), ante.ErrInvalidAuthority)
+	require.NoError(t, keeper.RemoveRelayer(ctx, authority, relayerAddr))
+	require.ErrorIs(t, keeper.RemoveRelayer(ctx, authority, relayerAddr), ante.ErrRelayerNotFound)
+
+	status = keeper.GetRelayerStatus(ctx, relayerAddr)
+	require.False(t, status.Registered)
+	require.Equal(t, sdk.ZeroDec(), status.ByteFeeDiscount)
+}
+
+// TestQueryRelayerStatus tests the query of the registration status of a relayer
+func TestQueryRelayerStatus(t *testing.T) {
+	ctx, keeper := setupFeeHandlerKeeper(t, 1)
+	querier := ante.NewQuerier(keeper)
+	relayerAddr := sdk.AccAddress([]byte("relayer"))
+
+	// Unregistered addresses are not a error
+	res, err := querier.RelayerStatus(sdk.WrapSDKContext(ctx), &ante.QueryRelayerStatusRequest{Address: relayerAddr.String()})
+	require.NoError(t, err)
+	require.False(t, res.Status.Registered)
+
+	relayer := ante.Relayer{Address: relayerAddr.String(), Channels: []string{"channel-0"}}
+	require.NoError(t, keeper.SetRelayer(ctx, keeper.GetAuthority(), relayer))
+
+	res, err = querier.RelayerStatus(sdk.WrapSDKContext(ctx), &ante.QueryRelayerStatusRequest{Address: relayerAddr.String()})
+	require.NoError(t, err)
+	require.Equal(t, keeper.GetRelayerStatus(ctx, relayerAddr), res.Status)
+	require.True(t, res.Status.Registered)
+
+	_, err = querier.RelayerStatus(sdk.WrapSDKContext(ctx), nil)
+	require.ErrorIs(t, err, errortypes.ErrInvalidRequest)
+	_, err = querier.RelayerStatus(sdk.WrapSDKContext(ctx), &ante.QueryRelayerStatusRequest{Address: "invalid"})
+	require.ErrorIs(t, err, errortypes.ErrInvalidAddress)
+}
+
+// TestRelayerDiscountValidation tests the validation of the relayer discount param
+func TestRelayerDiscountValidation(t *testing.T) {
+	params := newTestParams(1, DefaultMinTxSize)
+
+	params.RelayerByteFeeDiscount = sdk.ZeroDec()
+	require.NoError(t, params.Validate())
+	params.RelayerByteFeeDiscount = sdk.NewDecWithPrec(11, 1)
+	require.ErrorIs(t, params.Validate(), ante.ErrInvalidParams)
+	params.RelayerByteFeeDiscount = sdk.NewDec(-1)
+	require.ErrorIs(t, params.Validate(), ante.ErrInvalidParams)
+}
@@ -0,0 +1,113 @@
+// Surge pricing of the simulated feeHandler module
+// Each fee payer has a sliding window, counted in blocks, with the amount of bytes sent at each height
+// Once a fee payer sends more bytes than the budget, its byte price multiplier goes up as:
+// 1 + Step * (window bytes - budget) / budget, capped by the max multiplier
+// As the window slides the old heights are dropped, so the multiplier decays back over time
+package antehandler
+
+import (
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/address"
+)
+
+// SurgeBytesPrefix is the prefix of the bytes sent by a fee payer, keyed by fee payer and height
+var SurgeBytesPrefix = []byte{0x03}
+
+// GetSurgeMultiplier returns the current byte price multiplier of a fee payer
+// This is also the query used to check the multiplier of a address
+func (k FeeHandlerKeeper) GetSurgeMultiplier(ctx sdk.Context, feePayer sdk.AccAddress) sdk.Dec {
+	params := k.GetParams(ctx)
+	if !params.IsSurgePricingEnabled() {
+		return sdk.OneDec()
+	}
+
+	windowBytes := k.GetWindowBytes(ctx, feePayer)
+	if windowBytes <= params.SurgeByteBudget {
+		return sdk.OneDec()
+	}
+
+	// Increase the multiplier proportionally to the bytes above the budget
+	budget := sdk.NewDecFromInt(sdk.NewIntFromUint64(params.SurgeByteBudget))
+	overBudget := sdk.NewDecFromInt(sdk.NewIntFromUint64(windowBytes - params.SurgeByteBudget))
+	multiplier := sdk.OneDec().Add(params.SurgeMultiplierStep.Mul(overBudget).Quo(budget))
+
+	return sdk.MinDec(multiplier, params.MaxSurgeMultiplier)
+}
+
+// GetWindowBytes returns the amount of bytes sent by a fee payer inside the current window
+func (k FeeHandlerKeeper) GetWindowBytes(ctx sdk.Context, feePayer sdk.AccAddress) uint64 {
+	params := k.GetParams(ctx)
+	windowStart := surgeWindowStart(ctx.BlockHeight(), params.SurgeWindowBlocks)
+
+	iterator := sdk.KVStorePrefixIterator(ctx.KVStore(k.storeKey), surgeBytesPayerPrefix(feePayer))
+	defer iterator.Close()
+
+	total := uint64(0)
+	for ; iterator.Valid(); iterator.Next() {
+		// Heights outside the window are not considered, even if not pruned yet
+		height := surgeBytesKeyHeight(iterator.Key())
+		if height < windowStart {
+			continue
+		}
+		total += sdk.BigEndianToUint64(iterator.Value())
+	}
+
+	return total
+}
+
+// TrackTxBytes registers the bytes sent by a fee payer at the current height
+// The heights that left the window are pruned from the store
+// Nothing is tracked if the surge pricing is disabled
+func (k FeeHandlerKeeper) TrackTxBytes(ctx sdk.Context, feePayer sdk.AccAddress, size uint64) {
+	params := k.GetParams(ctx)
+	if !params.IsSurgePricingEnabled() {
+		return
+	}
+
+	store := ctx.KVStore(k.storeKey)
+	windowStart := surgeWindowStart(ctx.BlockHeight(), params.SurgeWindowBlocks)
+
+	// Collect the heights that left the window, keys are ordered by height
+	staleKeys := [][]byte{}
+	iterator := sdk.KVStorePrefixIterator(store, surgeBytesPayerPrefix(feePayer))
+	for ; iterator.Valid(); iterator.Next() {
+		if surgeBytesKeyHeight(iterator.Key()) >= windowStart {
+			break
+		}
+		staleKeys = append(staleKeys, iterator.Key())
+	}
+	iterator.Close()
+
+	for _, key := range staleKeys {
+		store.Delete(key)
+	}
+
+	// Add the bytes to the current height
+	key := surgeBytesKey(feePayer, ctx.BlockHeight())
+	total := size
+	if bz := store.Get(key); bz != nil {
+		total += sdk.BigEndianToUint64(bz)
+	}
+	store.Set(key, sdk.Uint64ToBigEndian(total))
+}
+
+// surgeWindowStart returns the first height inside the window
+func surgeWindowStart(height int64, windowBlocks uint64) int64 {
+	return height - int64(windowBlocks) + 1
+}
+
+// surgeBytesPayerPrefix returns the prefix of all the heights of a fee payer
+func surgeBytesPayerPrefix(feePayer sdk.AccAddress) []byte {
+	return append(append([]byte{}, SurgeBytesPrefix...), address.MustLengthPrefix(feePayer)...)
+}
+
+// surgeBytesKey returns the store key of the bytes sent by a fee payer at a height
+func surgeBytesKey(feePayer sdk.AccAddress, height int64) []byte {
+	return append(surgeBytesPayerPrefix(feePayer), sdk.Uint64ToBigEndian(uint64(height))...)
+}
+
+// surgeBytesKeyHeight returns the height from a surge bytes store key
+// The height is always the last 8 bytes of the key
+func surgeBytesKeyHeight(key []byte) int64 {
+	return int64(sdk.BigEndianToUint64(key[len(key)-8:]))
+}
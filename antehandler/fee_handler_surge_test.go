@@ -0,0 +1,199 @@
+package antehandler_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"cosmossdk.io/math"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	errortypes "github.com/cosmos/cosmos-sdk/types/errors"
+
+	ante "ibc-fee/antehandler"
+)
+
+// newSurgeParams returns params with the surge pricing enabled
+func newSurgeParams(windowBlocks, byteBudget uint64, step, maxMultiplier sdk.Dec) ante.FeeHandlerParams {
+	params := newTestParams(1, DefaultMinTxSize)
+	params.SurgeWindowBlocks = windowBlocks
+	params.SurgeByteBudget = byteBudget
+	params.SurgeMultiplierStep = step
+	params.MaxSurgeMultiplier = maxMultiplier
+	return params
+}
+
+// TestSurgeParamsValidation tests the validation of the surge params
+func TestSurgeParamsValidation(t *testing.T) {
+	// All the test cases
+	testCases := []struct {
+		name      string
+		params    ante.FeeHandlerParams
+		expectErr bool
+	}{
+		{
+			name:   "Disabled surge pricing skips the surge validation",
+			params: newSurgeParams(0, 0, sdk.ZeroDec(), sdk.ZeroDec()),
+		},
+		{
+			name:   "Valid surge params",
+			params: newSurgeParams(10, 1000, sdk.OneDec(), sdk.NewDec(3)),
+		},
+		{
+			name:      "Empty window",
+			params:    newSurgeParams(0, 1000, sdk.OneDec(), sdk.NewDec(3)),
+			expectErr: true,
+		},
+		{
+			name:      "Zero multiplier step",
+			params:    newSurgeParams(10, 1000, sdk.ZeroDec(), sdk.NewDec(3)),
+			expectErr: true,
+		},
+		{
+			name:      "Max multiplier bellow one",
+			params:    newSurgeParams(10, 1000, sdk.OneDec(), sdk.NewDecWithPrec(5, 1)),
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		err := tc.params.Validate()
+		if tc.expectErr {
+			require.ErrorIs(t, err, ante.ErrInvalidParams, tc.name)
+		} else {
+			require.NoError(t, err, tc.name)
+		}
+	}
+}
+
+// TestSurgeMultiplier tests the multiplier growth, cap and decay over the window
+func TestSurgeMultiplier(t *testing.T) {
+	ctx, keeper := setupFeeHandlerKeeper(t, 1)
+	feePayer := sdk.AccAddress([]byte("acc1"))
+	otherPayer := sdk.AccAddress([]byte("acc2"))
+
+	// Window of 3 blocks with a budget of 1000 bytes
+	require.NoError(t, keeper.SetParams(ctx, newSurgeParams(3, 1000, sdk.OneDec(), sdk.NewDec(3))))
+
+	// Bellow the budget there is no surge
+	keeper.TrackTxBytes(ctx, feePayer, 500)
+	require.Equal(t, uint64(500), keeper.GetWindowBytes(ctx, feePayer))
+	require.Equal(t, sdk.OneDec(), keeper.GetSurgeMultiplier(ctx, feePayer))
+
+	// Half a budget above the budget
+	keeper.TrackTxBytes(ctx, feePayer, 1000)
+	require.Equal(t, sdk.NewDecWithPrec(15, 1), keeper.GetSurgeMultiplier(ctx, feePayer))
+
+	// Other payers are not affected
+	require.Equal(t, sdk.OneDec(), keeper.GetSurgeMultiplier(ctx, otherPayer))
+
+	// The multiplier is capped
+	ctx = ctx.WithBlockHeight(2)
+	keeper.TrackTxBytes(ctx, feePayer, 2000)
+	require.Equal(t, uint64(3500), keeper.GetWindowBytes(ctx, feePayer))
+	require.Equal(t, sdk.NewDec(3), keeper.GetSurgeMultiplier(ctx, feePayer))
+
+	// Height 1 leaves the window
+	ctx = ctx.WithBlockHeight(4)
+	require.Equal(t, uint64(2000), keeper.GetWindowBytes(ctx, feePayer))
+	require.Equal(t, sdk.NewDec(2), keeper.GetSurgeMultiplier(ctx, feePayer))
+
+	// Every height leaves the window and the multiplier decays back to one
+	ctx = ctx.WithBlockHeight(5)
+	keeper.TrackTxBytes(ctx, feePayer, 100)
+	require.Equal(t, uint64(100), keeper.GetWindowBytes(ctx, feePayer))
+	require.Equal(t, sdk.OneDec(), keeper.GetSurgeMultiplier(ctx, feePayer))
+}
+
+// TestSurgeMultiplierDisabled tests that nothing is tracked without surge pricing
+func TestSurgeMultiplierDisabled(t *testing.T) {
+	ctx, keeper := setupFeeHandlerKeeper(t, 1)
+	feePayer := sdk.AccAddress([]byte("acc1"))
+
+	keeper.TrackTxBytes(ctx, feePayer, 10_000)
+	require.Equal(t, uint64(0), keeper.GetWindowBytes(ctx, feePayer))
+	require.Equal(t, sdk.OneDec(), keeper.GetSurgeMultiplier(ctx, feePayer))
+}
+
+// TestQuerySurgeMultiplier tests the query of the surge multiplier of a fee payer
+func TestQuerySurgeMultiplier(t *testing.T) {
+	ctx, keeper := setupFeeHandlerKeeper(t, 1)
+	querier := ante.NewQuerier(keeper)
+	feePayer := sdk.AccAddress([]byte("acc1"))
+
+	require.NoError(t, keeper.SetParams(ctx, newSurgeParams(3, 1000, sdk.OneDec(), sdk.NewDec(3))))
+	keeper.TrackTxBytes(ctx, feePayer, 1500)
+
+	res, err := querier.SurgeMultiplier(sdk.WrapSDKContext(ctx), &ante.QuerySurgeMultiplierRequest{Address: feePayer.String()})
+	require.NoError(t, err)
+	require.Equal(t, sdk.NewDecWithPrec(15, 1), res.Multiplier)
+
+	_, err = querier.SurgeMultiplier(sdk.WrapSDKContext(ctx), nil)
+	require.ErrorIs(t, err, errortypes.ErrInvalidRequest)
+	_, err = querier.SurgeMultiplier(sdk.WrapSDKContext(ctx), &ante.QuerySurgeMultiplierRequest{Address: "invalid"})
+	require.ErrorIs(t, err, errortypes.ErrInvalidAddress)
+}
+
+// TestWeightedFeeAnteIntegrationSurge tests repeated large TXs paying surge prices
+func TestWeightedFeeAnteIntegrationSurge(t *testing.T) {
+	s := SetupIntegrationTestSuite(t, false)
+	require.NoError(t, s.feeHandlerKeeper.SetParams(s.ctx, newSurgeParams(10, 300, sdk.OneDec(), sdk.NewDec(5))))
+
+	antehandler := sdk.ChainAnteDecorators(
+		ante.NewWeightedFeeDecorator(s.bankKeeper, s.feeHandlerKeeper),
+	)
+
+	initialBalance := sdk.NewCoins(sdk.NewCoin("testcoin", math.NewInt(100_000)))
+	payer := s.CreateFundedAccount(t, initialBalance)
+	receiver := s.CreateFundedAccount(t, nil)
+	tx := s.CreateSignedTx(t, newBankSendMsgs(payer, receiver, 3), payer)
+
+	baseFee := expectedBytesFee(t, s, tx)
+	previousBalance := initialBalance
+	previousMultiplier := sdk.ZeroDec()
+	for i := 0; i < 3; i++ {
+		expectedMultiplier := s.feeHandlerKeeper.GetSurgeMultiplier(s.ctx, payer.Address)
+
+		events, err := s.RunAnteHandler(antehandler, tx)
+		require.NoError(t, err)
+
+		// The fee is the base fee times the multiplier
+		expectedFee, _ := sdk.NewDecCoinsFromCoins(baseFee...).MulDec(expectedMultiplier).TruncateDecimal()
+		balance := s.bankKeeper.GetAllBalances(s.ctx, payer.Address)
+		require.Equal(t, previousBalance.Sub(expectedFee...), balance)
+
+		// The multiplier is emitted and grows with each TX
+		event, found := findBytesFeeEvent(events)
+		require.True(t, found)
+		require.Equal(t, expectedMultiplier.String(), eventAttribute(event, ante.AttributeKeySurgeMultiplier))
+		require.True(t, expectedMultiplier.GT(previousMultiplier))
+
+		previousBalance = balance
+		previousMultiplier = expectedMultiplier
+	}
+}
+
+// TestWeightedFeeAnteIntegrationSurgeSmallTxs tests that TXs bellow the min size count on the surge window
+func TestWeightedFeeAnteIntegrationSurgeSmallTxs(t *testing.T) {
+	s := SetupIntegrationTestSuite(t, false)
+	params := newSurgeParams(10, 300, sdk.OneDec(), sdk.NewDec(5))
+	params.MinTxSize = 10_000
+	require.NoError(t, s.feeHandlerKeeper.SetParams(s.ctx, params))
+
+	antehandler := sdk.ChainAnteDecorators(
+		ante.NewWeightedFeeDecorator(s.bankKeeper, s.feeHandlerKeeper),
+	)
+
+	initialBalance := sdk.NewCoins(sdk.NewCoin("testcoin", math.NewInt(100_000)))
+	payer := s.CreateFundedAccount(t, initialBalance)
+	receiver := s.CreateFundedAccount(t, nil)
+	tx := s.CreateSignedTx(t, newBankSendMsgs(payer, receiver, 1), payer)
+
+	// The small TXs are free but their bytes are tracked
+	for i := 0; i < 3; i++ {
+		_, err := s.RunAnteHandler(antehandler, tx)
+		require.NoError(t, err)
+	}
+	require.Equal(t, initialBalance, s.bankKeeper.GetAllBalances(s.ctx, payer.Address))
+	require.Greater(t, s.feeHandlerKeeper.GetWindowBytes(s.ctx, payer.Address), uint64(300))
+	require.True(t, s.feeHandlerKeeper.GetSurgeMultiplier(s.ctx, payer.Address).GT(sdk.OneDec()))
+}
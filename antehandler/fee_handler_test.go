@@ -0,0 +1,231 @@
+package antehandler_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+	"github.com/cosmos/cosmos-sdk/testutil"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	errortypes "github.com/cosmos/cosmos-sdk/types/errors"
+	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+
+	ante "ibc-fee/antehandler"
+)
+
+// setupFeeHandlerKeeper returns a new feeHandler keeper with a testing context at the given height
+func setupFeeHandlerKeeper(t *testing.T, height int64) (sdk.Context, ante.FeeHandlerKeeper) {
+	ctx, keeper, _ := setupFeeHandlerKeeperWithKey(t, height)
+	return ctx, keeper
+}
+
+// setupFeeHandlerKeeperWithKey returns a new feeHandler keeper and its store key, used to write raw stored values
+func setupFeeHandlerKeeperWithKey(t *testing.T, height int64) (sdk.Context, ante.FeeHandlerKeeper, storetypes.StoreKey) {
+	key := sdk.NewKVStoreKey(ante.FeeHandlerStoreKey)
+	transientKey := sdk.NewTransientStoreKey(ante.FeeHandlerTransientStoreKey)
+	testCtx := testutil.DefaultContextWithDB(t, key, transientKey)
+	ctx := testCtx.Ctx.WithBlockHeight(height)
+
+	keeper := ante.NewFeeHandlerKeeper(key, transientKey, NewBalanceKeeperMock(), authtypes.NewModuleAddress("gov").String())
+	return ctx, keeper, key
+}
+
+// newTestParams returns the default params with the given price for the testcoin denom
+func newTestParams(price int64, minTxSize uint64) ante.FeeHandlerParams {
+	params := ante.DefaultFeeHandlerParams()
+	params.Enabled = true
+	params.FeeBytePrice = sdk.NewDecCoins(sdk.NewDecCoinFromDec("testcoin", sdk.NewDec(price)))
+	params.MinTxSize = minTxSize
+	return params
+}
+
+// TestFeeHandlerParams tests the default params and setting new params
+func TestFeeHandlerParams(t *testing.T) {
+	ctx, keeper := setupFeeHandlerKeeper(t, 1)
+
+	// Without params the defaults are returned
+	require.Equal(t, ante.DefaultFeeHandlerParams(), keeper.GetParams(ctx))
+
+	// Set and get new params
+	params := newTestParams(2, 100)
+	require.NoError(t, keeper.SetParams(ctx, params))
+	require.Equal(t, params, keeper.GetParams(ctx))
+
+	// Invalid params are rejected
+	invalidParams := ante.FeeHandlerParams{
+		FeeBytePrice: sdk.DecCoins{sdk.DecCoin{Denom: "testcoin", Amount: sdk.NewDec(-1)}},
+	}
+	require.ErrorIs(t, keeper.SetParams(ctx, invalidParams), ante.ErrInvalidParams)
+
+	// Negative activation heights are rejected
+	invalidParams = newTestParams(2, 100)
+	invalidParams.ActivationHeight = -1
+	require.ErrorIs(t, keeper.SetParams(ctx, invalidParams), ante.ErrInvalidParams)
+}
+
+// TestFeeHandlerParamsMissingFields tests the params stored before some fields were added
+// The missing fields keep their default value instead of being left empty
+func TestFeeHandlerParamsMissingFields(t *testing.T) {
+	ctx, keeper, key := setupFeeHandlerKeeperWithKey(t, 1)
+
+	// Store the params and a params update without the relayer discount and the nesting depth
+	params := newTestParams(2, 100)
+	oldParams := make(map[string]json.RawMessage)
+	bz, err := json.Marshal(params)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(bz, &oldParams))
+	delete(oldParams, "RelayerByteFeeDiscount")
+	delete(oldParams, "MaxMsgNestingDepth")
+	oldParamsBz, err := json.Marshal(oldParams)
+	require.NoError(t, err)
+
+	store := ctx.KVStore(key)
+	store.Set(ante.ParamsKey, oldParamsBz)
+	updateKey := append(append([]byte{}, ante.ScheduledParamsPrefix...), sdk.Uint64ToBigEndian(10)...)
+	store.Set(updateKey, []byte(fmt.Sprintf(`{"ActivationHeight":10,"Params":%s}`, oldParamsBz)))
+
+	require.Equal(t, params, keeper.GetParams(ctx))
+	require.Equal(t, []ante.ScheduledParamsUpdate{{ActivationHeight: 10, Params: params}}, keeper.GetScheduledParamsUpdates(ctx))
+
+	// The applied update keeps the default values
+	keeper.BeginBlock(ctx.WithBlockHeight(10))
+	require.Equal(t, params, keeper.GetParams(ctx))
+	require.True(t, keeper.GetParams(ctx).RelayerByteFeeDiscount.IsZero())
+}
+
+// TestScheduleParamsUpdate tests the validations when scheduling and cancelling params updates
+func TestScheduleParamsUpdate(t *testing.T) {
+	ctx, keeper := setupFeeHandlerKeeper(t, 10)
+	authority := keeper.GetAuthority()
+
+	// All the test cases
+	testCases := []struct {
+		name        string
+		authority   string
+		update      ante.ScheduledParamsUpdate
+		expectedErr error
+	}{
+		{
+			name:      "Valid update",
+			authority: authority,
+			update:    ante.ScheduledParamsUpdate{ActivationHeight: 20, Params: newTestParams(2, 100)},
+		},
+		{
+			name:        "Invalid authority",
+			authority:   sdk.AccAddress([]byte("acc1")).String(),
+			update:      ante.ScheduledParamsUpdate{ActivationHeight: 21, Params: newTestParams(2, 100)},
+			expectedErr: ante.ErrInvalidAuthority,
+		},
+		{
+			name:        "Activation height at the current height",
+			authority:   authority,
+			update:      ante.ScheduledParamsUpdate{ActivationHeight: 10, Params: newTestParams(2, 100)},
+			expectedErr: ante.ErrInvalidActivationHeight,
+		},
+		{
+			name:        "Duplicated activation height",
+			authority:   authority,
+			update:      ante.ScheduledParamsUpdate{ActivationHeight: 20, Params: newTestParams(3, 100)},
+			expectedErr: ante.ErrParamsUpdateExists,
+		},
+		{
+			name:      "Invalid params",
+			authority: authority,
+			update: ante.ScheduledParamsUpdate{
+				ActivationHeight: 22,
+				Params:           ante.FeeHandlerParams{FeeBytePrice: sdk.DecCoins{sdk.DecCoin{Denom: "testcoin", Amount: sdk.ZeroDec()}}},
+			},
+			expectedErr: ante.ErrInvalidParams,
+		},
+	}
+
+	// The cases share the same keeper, so the order matters
+	for _, tc := range testCases {
+		err := keeper.ScheduleParamsUpdate(ctx, tc.authority, tc.update)
+		if tc.expectedErr != nil {
+			require.ErrorIs(t, err, tc.expectedErr, tc.name)
+		} else {
+			require.NoError(t, err, tc.name)
+		}
+	}
+
+	// Only the valid update should be listed
+	updates := keeper.GetScheduledParamsUpdates(ctx)
+	require.Equal(t, []ante.ScheduledParamsUpdate{testCases[0].update}, updates)
+
+	// Cancel the update
+	require.ErrorIs(t, keeper.CancelParamsUpdate(ctx, sdk.AccAddress([]byte("acc1")).String(), 20), ante.ErrInvalidAuthority)
+	require.ErrorIs(t, keeper.CancelParamsUpdate(ctx, authority, 30), ante.ErrParamsUpdateNotFound)
+	require.NoError(t, keeper.CancelParamsUpdate(ctx, authority, 20))
+	require.Empty(t, keeper.GetScheduledParamsUpdates(ctx))
+}
+
+// TestScheduledParamsActivation tests the params being swapped in at BeginBlock
+func TestScheduledParamsActivation(t *testing.T) {
+	ctx, keeper := setupFeeHandlerKeeper(t, 1)
+	authority := keeper.GetAuthority()
+
+	initialParams := newTestParams(1, 100)
+	require.NoError(t, keeper.SetParams(ctx, initialParams))
+
+	// Schedule updates out of order
+	update1 := ante.ScheduledParamsUpdate{ActivationHeight: 5, Params: newTestParams(2, 100)}
+	update2 := ante.ScheduledParamsUpdate{ActivationHeight: 3, Params: newTestParams(3, 50)}
+	update3 := ante.ScheduledParamsUpdate{ActivationHeight: 4, Params: newTestParams(4, 50)}
+	require.NoError(t, keeper.ScheduleParamsUpdate(ctx, authority, update1))
+	require.NoError(t, keeper.ScheduleParamsUpdate(ctx, authority, update2))
+	require.NoError(t, keeper.ScheduleParamsUpdate(ctx, authority, update3))
+
+	// The query must be ordered by height
+	require.Equal(t, []ante.ScheduledParamsUpdate{update2, update3, update1}, keeper.GetScheduledParamsUpdates(ctx))
+
+	// Nothing changes before the activation height
+	ctx = ctx.WithBlockHeight(2)
+	keeper.BeginBlock(ctx)
+	require.Equal(t, initialParams, keeper.GetParams(ctx))
+
+	// Skip a height, both updates are due and the latest one wins
+	ctx = ctx.WithBlockHeight(4).WithEventManager(sdk.NewEventManager())
+	keeper.BeginBlock(ctx)
+	require.Equal(t, update3.Params, keeper.GetParams(ctx))
+	require.Equal(t, []ante.ScheduledParamsUpdate{update1}, keeper.GetScheduledParamsUpdates(ctx))
+
+	// An event is emitted for each activated update
+	events := ctx.EventManager().Events()
+	require.Len(t, events, 2)
+	require.Equal(t, ante.EventTypeParamsUpdate, events[0].Type)
+	require.Equal(t, "3", eventAttribute(events[0], ante.AttributeKeyActivationHeight))
+	require.Equal(t, "4", eventAttribute(events[1], ante.AttributeKeyActivationHeight))
+
+	// Last update
+	ctx = ctx.WithBlockHeight(5)
+	keeper.BeginBlock(ctx)
+	require.Equal(t, update1.Params, keeper.GetParams(ctx))
+	require.Empty(t, keeper.GetScheduledParamsUpdates(ctx))
+}
+
+// TestQueryScheduledParamsUpdates tests the query of the upcoming params updates
+func TestQueryScheduledParamsUpdates(t *testing.T) {
+	ctx, keeper := setupFeeHandlerKeeper(t, 1)
+	querier := ante.NewQuerier(keeper)
+
+	res, err := querier.ScheduledParamsUpdates(sdk.WrapSDKContext(ctx), &ante.QueryScheduledParamsUpdatesRequest{})
+	require.NoError(t, err)
+	require.Empty(t, res.Updates)
+
+	// The updates are ordered by height
+	update1 := ante.ScheduledParamsUpdate{ActivationHeight: 5, Params: newTestParams(2, 100)}
+	update2 := ante.ScheduledParamsUpdate{ActivationHeight: 3, Params: newTestParams(3, 50)}
+	require.NoError(t, keeper.ScheduleParamsUpdate(ctx, keeper.GetAuthority(), update1))
+	require.NoError(t, keeper.ScheduleParamsUpdate(ctx, keeper.GetAuthority(), update2))
+
+	res, err = querier.ScheduledParamsUpdates(sdk.WrapSDKContext(ctx), &ante.QueryScheduledParamsUpdatesRequest{})
+	require.NoError(t, err)
+	require.Equal(t, []ante.ScheduledParamsUpdate{update2, update1}, res.Updates)
+
+	_, err = querier.ScheduledParamsUpdates(sdk.WrapSDKContext(ctx), nil)
+	require.ErrorIs(t, err, errortypes.ErrInvalidRequest)
+}
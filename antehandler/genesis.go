@@ -0,0 +1,108 @@
+// Genesis of the simulated feeHandler module
+// Since there is no protobuf definition behind this module, the genesis is encoded as JSON
+package antehandler
+
+import (
+	"encoding/json"
+
+	errorsmod "cosmossdk.io/errors"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// FeeHandlerGenesisState is the genesis state of the simulated feeHandler module
+type FeeHandlerGenesisState struct {
+	// Params are the initial params
+	Params FeeHandlerParams
+	// Relayers are the initial registered relayers
+	Relayers []Relayer
+	// ScheduledParamsUpdates are the params updates approved by governance that are not active yet
+	ScheduledParamsUpdates []ScheduledParamsUpdate
+}
+
+// DefaultFeeHandlerGenesisState returns the default genesis state, with the default params, no relayers and no updates
+func DefaultFeeHandlerGenesisState() FeeHandlerGenesisState {
+	return FeeHandlerGenesisState{
+		Params:                 DefaultFeeHandlerParams(),
+		Relayers:               []Relayer{},
+		ScheduledParamsUpdates: []ScheduledParamsUpdate{},
+	}
+}
+
+// Validate validates the params, the relayers and the scheduled params updates
+func (gs FeeHandlerGenesisState) Validate() error {
+	if err := gs.Params.Validate(); err != nil {
+		return err
+	}
+
+	seenRelayers := make(map[string]bool)
+	for _, relayer := range gs.Relayers {
+		if err := relayer.Validate(); err != nil {
+			return err
+		}
+		if seenRelayers[relayer.Address] {
+			return errorsmod.Wrapf(ErrInvalidRelayer, "duplicated relayer %s", relayer.Address)
+		}
+		seenRelayers[relayer.Address] = true
+	}
+
+	seenHeights := make(map[int64]bool)
+	for _, update := range gs.ScheduledParamsUpdates {
+		if update.ActivationHeight <= 0 {
+			return errorsmod.Wrapf(ErrInvalidActivationHeight, "activation height %d must be positive", update.ActivationHeight)
+		}
+		if err := update.Params.Validate(); err != nil {
+			return err
+		}
+		if seenHeights[update.ActivationHeight] {
+			return errorsmod.Wrapf(
+				ErrParamsUpdateExists,
+				"duplicated params update at height %d", update.ActivationHeight,
+			)
+		}
+		seenHeights[update.ActivationHeight] = true
+	}
+
+	return nil
+}
+
+// UnmarshalFeeHandlerGenesisState decodes and validates a JSON encoded genesis state
+// The genesis is decoded over the default genesis, so the params missing on older genesis files keep their default
+func UnmarshalFeeHandlerGenesisState(bz json.RawMessage) (FeeHandlerGenesisState, error) {
+	gs := DefaultFeeHandlerGenesisState()
+	if err := json.Unmarshal(bz, &gs); err != nil {
+		return FeeHandlerGenesisState{}, err
+	}
+	if err := gs.Validate(); err != nil {
+		return FeeHandlerGenesisState{}, err
+	}
+	return gs, nil
+}
+
+// InitGenesis sets the genesis params, relayers and scheduled params updates, invalid genesis states panic
+// The updates were already approved, so they are imported even if their height is not in the future
+// and are applied on the next BeginBlock
+func (k FeeHandlerKeeper) InitGenesis(ctx sdk.Context, gs FeeHandlerGenesisState) {
+	if err := gs.Validate(); err != nil {
+		panic(err)
+	}
+
+	if err := k.SetParams(ctx, gs.Params); err != nil {
+		panic(err)
+	}
+	for _, relayer := range gs.Relayers {
+		k.setRelayer(ctx, relayer)
+	}
+	for _, update := range gs.ScheduledParamsUpdates {
+		k.setScheduledParamsUpdate(ctx, update)
+	}
+}
+
+// ExportGenesis returns the current params, relayers and scheduled params updates
+// The surge windows, the block fees audit and the receipts are not exported
+func (k FeeHandlerKeeper) ExportGenesis(ctx sdk.Context) FeeHandlerGenesisState {
+	return FeeHandlerGenesisState{
+		Params:                 k.GetParams(ctx),
+		Relayers:               k.GetRelayers(ctx),
+		ScheduledParamsUpdates: k.GetScheduledParamsUpdates(ctx),
+	}
+}
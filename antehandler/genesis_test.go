@@ -0,0 +1,90 @@
+package antehandler_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+
+	ante "ibc-fee/antehandler"
+)
+
+// TestGenesisExportImport tests that a exported genesis keeps the relayers and the scheduled params updates
+func TestGenesisExportImport(t *testing.T) {
+	ctx, keeper := setupFeeHandlerKeeper(t, 5)
+	authority := authtypes.NewModuleAddress("gov").String()
+
+	relayer := ante.Relayer{Address: sdk.AccAddress([]byte("relayer")).String(), Channels: []string{"channel-0"}}
+	require.NoError(t, keeper.SetParams(ctx, newTestParams(1, DefaultMinTxSize)))
+	require.NoError(t, keeper.SetRelayer(ctx, authority, relayer))
+	updates := []ante.ScheduledParamsUpdate{
+		{ActivationHeight: 10, Params: newTestParams(2, DefaultMinTxSize)},
+		{ActivationHeight: 20, Params: newTestParams(3, DefaultMinTxSize)},
+	}
+	for _, update := range updates {
+		require.NoError(t, keeper.ScheduleParamsUpdate(ctx, authority, update))
+	}
+
+	// Export and import the genesis as JSON on a new chain
+	bz, err := json.Marshal(keeper.ExportGenesis(ctx))
+	require.NoError(t, err)
+	genesis, err := ante.UnmarshalFeeHandlerGenesisState(bz)
+	require.NoError(t, err)
+
+	newCtx, newKeeper := setupFeeHandlerKeeper(t, 1)
+	newKeeper.InitGenesis(newCtx, genesis)
+	require.Equal(t, newTestParams(1, DefaultMinTxSize), newKeeper.GetParams(newCtx))
+	require.Equal(t, []ante.Relayer{relayer}, newKeeper.GetRelayers(newCtx))
+	require.Equal(t, updates, newKeeper.GetScheduledParamsUpdates(newCtx))
+
+	// The imported updates are still applied at their height
+	newKeeper.BeginBlock(newCtx.WithBlockHeight(10))
+	require.Equal(t, updates[0].Params, newKeeper.GetParams(newCtx))
+}
+
+// TestGenesisValidate tests the validation of the scheduled params updates of the genesis
+func TestGenesisValidate(t *testing.T) {
+	validUpdate := ante.ScheduledParamsUpdate{ActivationHeight: 10, Params: newTestParams(1, DefaultMinTxSize)}
+	invalidParams := newTestParams(1, DefaultMinTxSize)
+	invalidParams.MaxMsgNestingDepth = 0
+
+	testCases := []struct {
+		name        string
+		updates     []ante.ScheduledParamsUpdate
+		expectedErr error
+	}{
+		{
+			name:    "Valid updates",
+			updates: []ante.ScheduledParamsUpdate{validUpdate},
+		},
+		{
+			name:        "Not positive activation height",
+			updates:     []ante.ScheduledParamsUpdate{{ActivationHeight: 0, Params: validUpdate.Params}},
+			expectedErr: ante.ErrInvalidActivationHeight,
+		},
+		{
+			name:        "Invalid params",
+			updates:     []ante.ScheduledParamsUpdate{{ActivationHeight: 10, Params: invalidParams}},
+			expectedErr: ante.ErrInvalidParams,
+		},
+		{
+			name:        "Duplicated activation height",
+			updates:     []ante.ScheduledParamsUpdate{validUpdate, validUpdate},
+			expectedErr: ante.ErrParamsUpdateExists,
+		},
+	}
+
+	for _, tc := range testCases {
+		genesis := ante.DefaultFeeHandlerGenesisState()
+		genesis.ScheduledParamsUpdates = tc.updates
+		err := genesis.Validate()
+		if tc.expectedErr != nil {
+			require.ErrorIs(t, err, tc.expectedErr, tc.name)
+		} else {
+			require.NoError(t, err, tc.name)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+// Integration suite for the weighted antehandler test
+// Differently from the AnteTestSuite, this suite runs real auth and bank keepers over an in memory store
+// This implementation was heavily inspired on cosmos-sdk/x/auth/ante/testutil_test.go and x/bank/keeper/keeper_test.go
+package antehandler_test
+
+import (
+	"testing"
+
+	dbm "github.com/cometbft/cometbft-db"
+	"github.com/cometbft/cometbft/libs/log"
+	tmproto "github.com/cometbft/cometbft/proto/tendermint/types"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosmos/cosmos-sdk/client"
+	"github.com/cosmos/cosmos-sdk/crypto/keys/secp256k1"
+	cryptotypes "github.com/cosmos/cosmos-sdk/crypto/types"
+	"github.com/cosmos/cosmos-sdk/store"
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	moduletestutil "github.com/cosmos/cosmos-sdk/types/module/testutil"
+	"github.com/cosmos/cosmos-sdk/types/tx/signing"
+	"github.com/cosmos/cosmos-sdk/x/auth"
+	authkeeper "github.com/cosmos/cosmos-sdk/x/auth/keeper"
+	authsigning "github.com/cosmos/cosmos-sdk/x/auth/signing"
+	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+	"github.com/cosmos/cosmos-sdk/x/bank"
+	bankkeeper "github.com/cosmos/cosmos-sdk/x/bank/keeper"
+	banktestutil "github.com/cosmos/cosmos-sdk/x/bank/testutil"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	minttypes "github.com/cosmos/cosmos-sdk/x/mint/types"
+
+	ante "ibc-fee/antehandler"
+)
+
+// IntegrationChainID is the chain id used to sign the integration TXs
+const IntegrationChainID = "ibc-fee-test"
+
+// IntegrationTestAccount is a funded account with its private key
+type IntegrationTestAccount struct {
+	PrivKey cryptotypes.PrivKey
+	Address sdk.AccAddress
+}
+
+// IntegrationTestSuite is a test suite with real keepers to be used on the weighted fee antehandler tests
+type IntegrationTestSuite struct {
+	ctx           sdk.Context
+	encCfg        moduletestutil.TestEncodingConfig
+	accountKeeper authkeeper.AccountKeeper
+	bankKeeper    bankkeeper.BaseKeeper
+	feeHandler    FeeHandlerMock
+	// feeHandlerKeeper is the real feeHandler keeper, used when the state of the module is tested
+	feeHandlerKeeper ante.FeeHandlerKeeper
+}
+
+// SetupIntegrationTestSuite setups a new test with real auth and bank keepers
+func SetupIntegrationTestSuite(t *testing.T, isCheckTx bool) *IntegrationTestSuite {
+	suite := &IntegrationTestSuite{}
+
+	// Mount the auth and bank stores on a in memory database
+	authKey := sdk.NewKVStoreKey(authtypes.StoreKey)
+	bankKey := sdk.NewKVStoreKey(banktypes.StoreKey)
+	feeHandlerKey := sdk.NewKVStoreKey(ante.FeeHandlerStoreKey)
+	feeHandlerTransientKey := sdk.NewTransientStoreKey(ante.FeeHandlerTransientStoreKey)
+
+	db := dbm.NewMemDB()
+	cms := store.NewCommitMultiStore(db)
+	cms.MountStoreWithDB(authKey, storetypes.StoreTypeIAVL, db)
+	cms.MountStoreWithDB(bankKey, storetypes.StoreTypeIAVL, db)
+	cms.MountStoreWithDB(feeHandlerKey, storetypes.StoreTypeIAVL, db)
+	cms.MountStoreWithDB(feeHandlerTransientKey, storetypes.StoreTypeTransient, db)
+	require.NoError(t, cms.LoadLatestVersion())
+
+	suite.ctx = sdk.NewContext(cms, tmproto.Header{ChainID: IntegrationChainID}, isCheckTx, log.NewNopLogger()).
+		WithBlockHeight(1)
+
+	// Initialize the codecs with the auth and bank interfaces
+	suite.encCfg = moduletestutil.MakeTestEncodingConfig(auth.AppModuleBasic{}, bank.AppModuleBasic{})
+
+	// Initialize the keepers, the mint module is only used to fund accounts
+	authority := authtypes.NewModuleAddress("gov").String()
+	maccPerms := map[string][]string{
+		authtypes.FeeCollectorName: nil,
+		minttypes.ModuleName:       {authtypes.Minter},
+	}
+	suite.accountKeeper = authkeeper.NewAccountKeeper(
+		suite.encCfg.Codec,
+		authKey,
+		authtypes.ProtoBaseAccount,
+		maccPerms,
+		sdk.GetConfig().GetBech32AccountAddrPrefix(),
+		authority,
+	)
+	suite.bankKeeper = bankkeeper.NewBaseKeeper(
+		suite.encCfg.Codec,
+		bankKey,
+		suite.accountKeeper,
+		map[string]bool{},
+		authority,
+	)
+
+	// Initialize the feeHandler mock and keeper
+	suite.feeHandler = NewFeeHandlerMock()
+	suite.feeHandlerKeeper = ante.NewFeeHandlerKeeper(feeHandlerKey, feeHandlerTransientKey, suite.bankKeeper, authority)
+
+	return suite
+}
+
+// CreateFundedAccount creates a new account on the auth keeper and funds it with the given coins
+func (s *IntegrationTestSuite) CreateFundedAccount(t *testing.T, coins sdk.Coins) IntegrationTestAccount {
+	privKey := secp256k1.GenPrivKey()
+	addr := sdk.AccAddress(privKey.PubKey().Address())
+
+	acc := s.accountKeeper.NewAccountWithAddress(s.ctx, addr)
+	s.accountKeeper.SetAccount(s.ctx, acc)
+
+	if !coins.IsZero() {
+		require.NoError(t, banktestutil.FundAccount(s.bankKeeper, s.ctx, addr, coins))
+	}
+
+	return IntegrationTestAccount{PrivKey: privKey, Address: addr}
+}
+
+// FeeCollectorBalance returns the balance of the fee collector module account
+func (s *IntegrationTestSuite) FeeCollectorBalance() sdk.Coins {
+	return s.bankKeeper.GetAllBalances(s.ctx, s.accountKeeper.GetModuleAddress(authtypes.FeeCollectorName))
+}
+
+// RunAnteHandler runs the antehandler over a cached context, similarly to the baseapp
+// The state changes are only written if the antehandler succeeds
+func (s *IntegrationTestSuite) RunAnteHandler(anteHandler sdk.AnteHandler, tx sdk.Tx) (sdk.Events, error) {
+	cacheCtx, write := s.ctx.CacheContext()
+
+	newCtx, err := anteHandler(cacheCtx, tx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	write()
+	return newCtx.EventManager().Events(), nil
+}
+
+// CreateSignedTx creates a TX with the given msgs signed by the given accounts
+// The first signer is the fee payer
+func (s *IntegrationTestSuite) CreateSignedTx(t *testing.T, msgs []sdk.Msg, signers ...IntegrationTestAccount) authsigning.Tx {
+	return s.CreateSignedTxWithFee(t, msgs, sdk.NewCoins(), signers...)
+}
+
+// CreateSignedTxWithFee creates a TX with the given msgs and regular fee signed by the given accounts
+// The first signer is the fee payer
+func (s *IntegrationTestSuite) CreateSignedTxWithFee(
+	t *testing.T,
+	msgs []sdk.Msg,
+	fee sdk.Coins,
+	signers ...IntegrationTestAccount,
+) authsigning.Tx {
+	txBuilder := s.encCfg.TxConfig.NewTxBuilder()
+	require.NoError(t, txBuilder.SetMsgs(msgs...))
+	txBuilder.SetGasLimit(200_000)
+	txBuilder.SetFeeAmount(fee)
+
+	signMode := s.encCfg.TxConfig.SignModeHandler().DefaultMode()
+
+	// First round, set the signer infos with empty signatures
+	sigs := make([]signing.SignatureV2, len(signers))
+	for i, signer := range signers {
+		acc := s.accountKeeper.GetAccount(s.ctx, signer.Address)
+		sigs[i] = signing.SignatureV2{
+			PubKey:   signer.PrivKey.PubKey(),
+			Data:     &signing.SingleSignatureData{SignMode: signMode},
+			Sequence: acc.GetSequence(),
+		}
+	}
+	require.NoError(t, txBuilder.SetSignatures(sigs...))
+
+	// Second round, every signer signs the TX
+	for i, signer := range signers {
+		sigs[i] = signTx(t, s.encCfg.TxConfig, txBuilder, s.accountKeeper.GetAccount(s.ctx, signer.Address), signer.PrivKey, signMode)
+	}
+	require.NoError(t, txBuilder.SetSignatures(sigs...))
+
+	return txBuilder.GetTx()
+}
+
+// signTx signs the current TX bytes with the given private key
+func signTx(
+	t *testing.T,
+	txConfig client.TxConfig,
+	txBuilder client.TxBuilder,
+	acc authtypes.AccountI,
+	privKey cryptotypes.PrivKey,
+	signMode signing.SignMode,
+) signing.SignatureV2 {
+	signerData := authsigning.SignerData{
+		Address:       acc.GetAddress().String(),
+		ChainID:       IntegrationChainID,
+		AccountNumber: acc.GetAccountNumber(),
+		Sequence:      acc.GetSequence(),
+		PubKey:        privKey.PubKey(),
+	}
+
+	signBytes, err := txConfig.SignModeHandler().GetSignBytes(signMode, signerData, txBuilder.GetTx())
+	require.NoError(t, err)
+
+	sig, err := privKey.Sign(signBytes)
+	require.NoError(t, err)
+
+	return signing.SignatureV2{
+		PubKey:   privKey.PubKey(),
+		Data:     &signing.SingleSignatureData{SignMode: signMode, Signature: sig},
+		Sequence: acc.GetSequence(),
+	}
+}
@@ -1,6 +1,8 @@
 package antehandler_test
 
 import (
+	"time"
+
 	sdk "github.com/cosmos/cosmos-sdk/types"
 
 	"ibc-fee/antehandler"
@@ -8,7 +10,8 @@ import (
 
 // Mock the FeeHandlerMock for tests
 type FeeHandlerMock struct {
-	params antehandler.FeeHandlerParams
+	params   antehandler.FeeHandlerParams
+	relayers map[string]antehandler.Relayer
 }
 
 var (
@@ -18,12 +21,16 @@ var (
 )
 
 // NewFeeHandlerMock returns a FeeHandlerMock
+// The params start from the default params with the mock prices
 func NewFeeHandlerMock() FeeHandlerMock {
+	params := antehandler.DefaultFeeHandlerParams()
+	params.Enabled = true
+	params.FeeBytePrice = DefaultFeeBytePrice
+	params.MinTxSize = DefaultMinTxSize
+
 	return FeeHandlerMock{
-		params: antehandler.FeeHandlerParams{
-			FeeBytePrice: DefaultFeeBytePrice,
-			MinTxSize:    DefaultMinTxSize,
-		},
+		params:   params,
+		relayers: make(map[string]antehandler.Relayer),
 	}
 }
 
@@ -31,3 +38,80 @@ func NewFeeHandlerMock() FeeHandlerMock {
 func (fhm FeeHandlerMock) GetParams(ctx sdk.Context) antehandler.FeeHandlerParams {
 	return fhm.params
 }
+
+// GetSurgeMultiplier returns no surge, the mock doesn't track bytes
+func (fhm FeeHandlerMock) GetSurgeMultiplier(ctx sdk.Context, feePayer sdk.AccAddress) sdk.Dec {
+	return sdk.OneDec()
+}
+
+// TrackTxBytes does nothing on the mock
+func (fhm FeeHandlerMock) TrackTxBytes(ctx sdk.Context, feePayer sdk.AccAddress, size uint64) {}
+
+// TrackBytesFee does nothing on the mock
+func (fhm FeeHandlerMock) TrackBytesFee(ctx sdk.Context, fee sdk.Coins) {}
+
+// SetRelayer registers a relayer on the mock
+func (fhm FeeHandlerMock) SetRelayer(relayer antehandler.Relayer) {
+	fhm.relayers[relayer.Address] = relayer
+}
+
+// GetRelayer returns a relayer registered on the mock
+func (fhm FeeHandlerMock) GetRelayer(ctx sdk.Context, relayerAddr sdk.AccAddress) (antehandler.Relayer, bool) {
+	relayer, found := fhm.relayers[relayerAddr.String()]
+	return relayer, found
+}
+
+// SetBytesFeeReceipt does nothing on the mock
+func (fhm FeeHandlerMock) SetBytesFeeReceipt(ctx sdk.Context, txHash []byte, receipt antehandler.BytesFeeReceipt) {
+}
+
+// BalanceKeeperMock is a local stub bank keeper with fixed balances
+type BalanceKeeperMock struct {
+	balances map[string]sdk.Coins
+}
+
+// NewBalanceKeeperMock returns a BalanceKeeperMock without balances
+func NewBalanceKeeperMock() BalanceKeeperMock {
+	return BalanceKeeperMock{
+		balances: make(map[string]sdk.Coins),
+	}
+}
+
+// SetBalance sets the balance of a address
+func (bkm BalanceKeeperMock) SetBalance(addr sdk.AccAddress, coins sdk.Coins) {
+	bkm.balances[addr.String()] = coins
+}
+
+// GetAllBalances returns the balance of a address
+func (bkm BalanceKeeperMock) GetAllBalances(ctx sdk.Context, addr sdk.AccAddress) sdk.Coins {
+	return bkm.balances[addr.String()]
+}
+
+// PriceOracleMock is a local stub oracle with fixed exchange rates
+type PriceOracleMock struct {
+	prices map[string]PriceOracleMockPrice
+}
+
+// PriceOracleMockPrice is a exchange rate with its last update time
+type PriceOracleMockPrice struct {
+	Rate      sdk.Dec
+	UpdatedAt time.Time
+}
+
+// NewPriceOracleMock returns a PriceOracleMock without prices
+func NewPriceOracleMock() PriceOracleMock {
+	return PriceOracleMock{
+		prices: make(map[string]PriceOracleMockPrice),
+	}
+}
+
+// SetExchangeRate sets the exchange rate between two denoms
+func (pom PriceOracleMock) SetExchangeRate(baseDenom, quoteDenom string, rate sdk.Dec, updatedAt time.Time) {
+	pom.prices[baseDenom+"/"+quoteDenom] = PriceOracleMockPrice{Rate: rate, UpdatedAt: updatedAt}
+}
+
+// GetExchangeRate returns the exchange rate between two denoms
+func (pom PriceOracleMock) GetExchangeRate(ctx sdk.Context, baseDenom, quoteDenom string) (sdk.Dec, time.Time, bool) {
+	price, found := pom.prices[baseDenom+"/"+quoteDenom]
+	return price.Rate, price.UpdatedAt, found
+}
@@ -0,0 +1,78 @@
+// Unwrapping of nested msgs
+// Wrapper msgs, such as authz MsgExec or ICA MsgSendTx, carry other msgs inside
+// The byte fee rules are applied to the inner msgs, so wrapping a msg doesn't escape any type specific rule
+package antehandler
+
+import (
+	errorsmod "cosmossdk.io/errors"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	errortypes "github.com/cosmos/cosmos-sdk/types/errors"
+	icacontrollertypes "github.com/cosmos/ibc-go/v7/modules/apps/27-interchain-accounts/controller/types"
+	icatypes "github.com/cosmos/ibc-go/v7/modules/apps/27-interchain-accounts/types"
+)
+
+// nestedMsgsWrapper is implemented by msgs that wrap other msgs, such as authz MsgExec
+type nestedMsgsWrapper interface {
+	GetMessages() ([]sdk.Msg, error)
+}
+
+// unwrapMsgs returns the inner msgs of the known wrappers, recursively
+// Only the msgs that are not wrappers are returned, msgs nested deeper than the max depth return an error
+func (wfd WeightedFeeDecorator) unwrapMsgs(msgs []sdk.Msg, maxDepth uint64) ([]sdk.Msg, error) {
+	return wfd.unwrapMsgsAtDepth(msgs, 0, maxDepth)
+}
+
+// unwrapMsgsAtDepth unwraps the msgs found at the given depth, top level msgs are at depth 0
+func (wfd WeightedFeeDecorator) unwrapMsgsAtDepth(msgs []sdk.Msg, depth, maxDepth uint64) ([]sdk.Msg, error) {
+	unwrapped := []sdk.Msg{}
+	for _, msg := range msgs {
+		innerMsgs, isWrapper, err := wfd.getInnerMsgs(msg)
+		if err != nil {
+			return nil, err
+		}
+		if !isWrapper {
+			unwrapped = append(unwrapped, msg)
+			continue
+		}
+
+		if depth+1 > maxDepth {
+			return nil, errorsmod.Wrapf(
+				ErrMsgNestingTooDeep,
+				"msgs are nested deeper than the max depth %d", maxDepth,
+			)
+		}
+
+		innerUnwrapped, err := wfd.unwrapMsgsAtDepth(innerMsgs, depth+1, maxDepth)
+		if err != nil {
+			return nil, err
+		}
+		unwrapped = append(unwrapped, innerUnwrapped...)
+	}
+
+	return unwrapped, nil
+}
+
+// getInnerMsgs returns the msgs inside a wrapper msg and if the msg is a known wrapper
+func (wfd WeightedFeeDecorator) getInnerMsgs(msg sdk.Msg) ([]sdk.Msg, bool, error) {
+	switch wrapper := msg.(type) {
+	case *icacontrollertypes.MsgSendTx:
+		// The ICA msgs can only be decoded with the codec, without it the msg is kept as is
+		if wfd.cdc == nil {
+			return nil, false, nil
+		}
+		// The packet data is only validated on the host chain, so data that can't be decoded is kept as is
+		innerMsgs, err := icatypes.DeserializeCosmosTx(wfd.cdc, wrapper.PacketData.Data)
+		if err != nil {
+			return nil, false, nil
+		}
+		return innerMsgs, true, nil
+	case nestedMsgsWrapper:
+		innerMsgs, err := wrapper.GetMessages()
+		if err != nil {
+			return nil, true, errorsmod.Wrap(errortypes.ErrTxDecode, err.Error())
+		}
+		return innerMsgs, true, nil
+	default:
+		return nil, false, nil
+	}
+}
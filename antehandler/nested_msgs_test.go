@@ -0,0 +1,138 @@
+package antehandler_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/cosmos/gogoproto/proto"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosmos/cosmos-sdk/codec"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	moduletestutil "github.com/cosmos/cosmos-sdk/types/module/testutil"
+	"github.com/cosmos/cosmos-sdk/x/authz"
+	authzmodule "github.com/cosmos/cosmos-sdk/x/authz/module"
+	"github.com/cosmos/cosmos-sdk/x/bank"
+	banktyppes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	icacontrollertypes "github.com/cosmos/ibc-go/v7/modules/apps/27-interchain-accounts/controller/types"
+	icatypes "github.com/cosmos/ibc-go/v7/modules/apps/27-interchain-accounts/types"
+	"github.com/cosmos/ibc-go/v7/modules/apps/transfer"
+	clienttypes "github.com/cosmos/ibc-go/v7/modules/core/02-client/types"
+	channeltypes "github.com/cosmos/ibc-go/v7/modules/core/04-channel/types"
+
+	ante "ibc-fee/antehandler"
+)
+
+// newMsgExec wraps the msgs in a authz MsgExec
+func newMsgExec(grantee sdk.AccAddress, msgs ...sdk.Msg) *authz.MsgExec {
+	msgExec := authz.NewMsgExec(grantee, msgs)
+	return &msgExec
+}
+
+// newMsgSendTx wraps the msgs in a ICA MsgSendTx
+func newMsgSendTx(t *testing.T, cdc codec.BinaryCodec, owner sdk.AccAddress, msgs ...proto.Message) *icacontrollertypes.MsgSendTx {
+	data, err := icatypes.SerializeCosmosTx(cdc, msgs)
+	require.NoError(t, err)
+
+	packetData := icatypes.InterchainAccountPacketData{
+		Type: icatypes.EXECUTE_TX,
+		Data: data,
+	}
+	return icacontrollertypes.NewMsgSendTx(owner.String(), "connection-0", 100, packetData)
+}
+
+// TestWeightedFeeAnteNestedMsgsDepth tests the max depth of nested msgs
+func TestWeightedFeeAnteNestedMsgsDepth(t *testing.T) {
+	// Prepare the testing data
+	accAddr1 := sdk.AccAddress([]byte("acc1"))
+	accAddr2 := sdk.AccAddress([]byte("acc2"))
+	bankSend := banktyppes.NewMsgSend(accAddr1, accAddr2, sdk.NewCoins(sdk.NewCoin("utestcoin", sdk.OneInt())))
+	encCfg := moduletestutil.MakeTestEncodingConfig(authzmodule.AppModuleBasic{}, bank.AppModuleBasic{})
+
+	// All the test cases
+	testCases := []struct {
+		name        string
+		msgs        []sdk.Msg
+		withCodec   bool
+		expectedErr error
+	}{
+		{
+			name:      "Msgs at the max depth",
+			msgs:      []sdk.Msg{newMsgExec(accAddr1, newMsgExec(accAddr1, bankSend))},
+			withCodec: false,
+		},
+		{
+			name:        "Msgs deeper than the max depth",
+			msgs:        []sdk.Msg{newMsgExec(accAddr1, newMsgExec(accAddr1, newMsgExec(accAddr1, bankSend)))},
+			withCodec:   false,
+			expectedErr: ante.ErrMsgNestingTooDeep,
+		},
+		{
+			name:      "ICA msgs are not unwrapped without the codec",
+			msgs:      []sdk.Msg{newMsgSendTx(t, encCfg.Codec, accAddr1, newMsgExec(accAddr1, newMsgExec(accAddr1, bankSend)))},
+			withCodec: false,
+		},
+		{
+			name:        "ICA msgs deeper than the max depth",
+			msgs:        []sdk.Msg{newMsgSendTx(t, encCfg.Codec, accAddr1, newMsgExec(accAddr1, newMsgExec(accAddr1, bankSend)))},
+			withCodec:   true,
+			expectedErr: ante.ErrMsgNestingTooDeep,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("Case %s", tc.name), func(t *testing.T) {
+			// At each run we restart our setup with a new fee decorator
+			s := SetupTestSuite(t, false)
+			s.feeHandler.params.MaxMsgNestingDepth = 2
+
+			var cdc codec.BinaryCodec
+			if tc.withCodec {
+				cdc = encCfg.Codec
+			}
+			dfd := ante.NewWeightedFeeDecorator(s.bankKeeper, s.feeHandler, ante.WithCodec(cdc))
+			antehandler := sdk.ChainAnteDecorators(dfd)
+
+			tx := createTX(t, tc.msgs)
+
+			// Accepted TXs are above the threshold and are charged
+			if tc.expectedErr == nil {
+				s.bankKeeper.EXPECT().SendCoinsFromAccountToModule(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+			}
+
+			// Run the antehandler
+			_, err := antehandler(s.ctx, tx, false)
+			if tc.expectedErr != nil {
+				require.ErrorIs(t, err, tc.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+// TestWeightedFeeAnteNestedPacketMsgs tests the packet data pricing applied to packet msgs inside a MsgExec
+func TestWeightedFeeAnteNestedPacketMsgs(t *testing.T) {
+	relayer := sdk.AccAddress([]byte("relayer"))
+	sender := sdk.AccAddress([]byte("sender"))
+	proof := bytes.Repeat([]byte{1}, 500)
+	ack := channeltypes.NewMsgAcknowledgement(
+		newTransferPacket(sender.String()), []byte("ack"), proof, clienttypes.NewHeight(0, 10), relayer.String(),
+	)
+
+	s := SetupTestSuite(t, false)
+	s.feeHandler.params.PacketDataPricing = true
+	dfd := ante.NewWeightedFeeDecorator(s.bankKeeper, s.feeHandler, ante.WithPacketDataUnmarshaler(transfer.IBCModule{}))
+	antehandler := sdk.ChainAnteDecorators(dfd)
+
+	// The relayer executes the acknowledgement through authz
+	tx := createTX(t, []sdk.Msg{newMsgExec(relayer, ack)})
+
+	// The original sender is charged, as if the acknowledgement was a top level msg
+	s.bankKeeper.EXPECT().SendCoinsFromAccountToModule(gomock.Any(), sender, gomock.Any(), gomock.Any()).Return(nil)
+
+	_, err := antehandler(s.ctx, tx, false)
+	require.NoError(t, err)
+}
@@ -0,0 +1,68 @@
+// Byte fees paid in non native denoms
+// The byte prices are quoted in the native denoms, users holding only accepted denoms (such as IBC vouchers)
+// pay the byte fees with the prices converted by a price oracle
+package antehandler
+
+import (
+	errorsmod "cosmossdk.io/errors"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// getFeeDenom returns the accepted denom used to pay the byte fees
+// The denom is taken from the TX fee, an empty denom means the byte fees are paid with the native prices
+// A TX fee offering only denoms that are neither native nor accepted is rejected
+func (wfd WeightedFeeDecorator) getFeeDenom(feeTx sdk.FeeTx, params FeeHandlerParams) (string, error) {
+	if wfd.priceOracle == nil || len(params.AcceptedFeeDenoms) == 0 {
+		return "", nil
+	}
+
+	fee := feeTx.GetFee()
+
+	// If the user is paying with a native denom, there is nothing to convert
+	for _, price := range params.FeeBytePrice {
+		if fee.AmountOf(price.Denom).IsPositive() {
+			return "", nil
+		}
+	}
+
+	// Coins are sorted, so the first accepted denom is deterministic
+	for _, coin := range fee {
+		if params.IsAcceptedFeeDenom(coin.Denom) {
+			return coin.Denom, nil
+		}
+	}
+
+	// Without a fee the byte fees are paid with the native prices
+	if fee.IsZero() {
+		return "", nil
+	}
+
+	return "", errorsmod.Wrapf(
+		ErrDenomNotAccepted, "fee %s has no native or accepted denom", fee,
+	)
+}
+
+// convertPrice converts a byte price quoted in native denoms into the fee denom
+// Every native denom is converted at the current rate and summed, missing or stale prices return an error
+func (wfd WeightedFeeDecorator) convertPrice(ctx sdk.Context, price sdk.DecCoins, feeDenom string, params FeeHandlerParams) (sdk.DecCoins, error) {
+	total := sdk.ZeroDec()
+	for _, coin := range price {
+		rate, updatedAt, found := wfd.priceOracle.GetExchangeRate(ctx, coin.Denom, feeDenom)
+		if !found || !rate.IsPositive() {
+			return nil, errorsmod.Wrapf(
+				ErrPriceNotFound,
+				"no price for %s in %s", coin.Denom, feeDenom,
+			)
+		}
+		if ctx.BlockTime().Sub(updatedAt) > params.MaxPriceAge {
+			return nil, errorsmod.Wrapf(
+				ErrStalePrice,
+				"stale price for %s in %s, last updated at %s", coin.Denom, feeDenom, updatedAt,
+			)
+		}
+
+		total = total.Add(coin.Amount.Mul(rate))
+	}
+
+	return sdk.NewDecCoins(sdk.NewDecCoinFromDec(feeDenom, total)), nil
+}
@@ -0,0 +1,179 @@
+package antehandler_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	"cosmossdk.io/math"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/module/testutil"
+	"github.com/cosmos/cosmos-sdk/x/auth/signing"
+	authtx "github.com/cosmos/cosmos-sdk/x/auth/tx"
+	banktyppes "github.com/cosmos/cosmos-sdk/x/bank/types"
+
+	ante "ibc-fee/antehandler"
+)
+
+// IBCTestDenom is a IBC voucher accepted to pay byte fees
+const IBCTestDenom = "ibc/27394FB092D2ECCD56123C74F36E4C1F926001CEADA9CA97EA622B25F41E5EB2"
+
+// TestWeightedFeeAnteOracle tests paying byte fees in non native denoms through the price oracle
+func TestWeightedFeeAnteOracle(t *testing.T) {
+	// Prepare the testing data
+	accAddr1 := sdk.AccAddress([]byte("acc1"))
+	accAddr2 := sdk.AccAddress([]byte("acc2"))
+	msgs := []sdk.Msg{
+		banktyppes.NewMsgSend(accAddr1, accAddr2, sdk.NewCoins(sdk.NewCoin("utestcoin", sdk.OneInt()))),
+		banktyppes.NewMsgSend(accAddr1, accAddr2, sdk.NewCoins(sdk.NewCoin("utestcoin", sdk.OneInt()))),
+	}
+	blockTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	freshPrice := blockTime.Add(-30 * time.Second)
+	stalePrice := blockTime.Add(-2 * time.Minute)
+
+	// All the test cases
+	testCases := []struct {
+		name            string
+		txFee           sdk.Coins
+		withOracle      bool
+		priceUpdatedAt  *time.Time
+		expectedDenom   string
+		expectedRateMul int64
+		expectedErr     error
+	}{
+		{
+			name:            "Native denom on the TX fee is charged with the native price",
+			txFee:           sdk.NewCoins(sdk.NewCoin("testcoin", math.OneInt()), sdk.NewCoin(IBCTestDenom, math.OneInt())),
+			withOracle:      true,
+			priceUpdatedAt:  &freshPrice,
+			expectedDenom:   "testcoin",
+			expectedRateMul: 1,
+		},
+		{
+			name:            "Accepted denom is charged with the converted price",
+			txFee:           sdk.NewCoins(sdk.NewCoin(IBCTestDenom, math.OneInt())),
+			withOracle:      true,
+			priceUpdatedAt:  &freshPrice,
+			expectedDenom:   IBCTestDenom,
+			expectedRateMul: 2,
+		},
+		{
+			name:           "Only not accepted denoms are rejected",
+			txFee:          sdk.NewCoins(sdk.NewCoin("notaccepted", math.OneInt())),
+			withOracle:     true,
+			priceUpdatedAt: &freshPrice,
+			expectedErr:    ante.ErrDenomNotAccepted,
+		},
+		{
+			name:            "Not accepted denom next to a accepted denom is charged with the converted price",
+			txFee:           sdk.NewCoins(sdk.NewCoin("notaccepted", math.OneInt()), sdk.NewCoin(IBCTestDenom, math.OneInt())),
+			withOracle:      true,
+			priceUpdatedAt:  &freshPrice,
+			expectedDenom:   IBCTestDenom,
+			expectedRateMul: 2,
+		},
+		{
+			name:            "Empty TX fee is charged with the native price",
+			txFee:           sdk.NewCoins(),
+			withOracle:      true,
+			priceUpdatedAt:  &freshPrice,
+			expectedDenom:   "testcoin",
+			expectedRateMul: 1,
+		},
+		{
+			name:            "Without oracle the native price is charged",
+			txFee:           sdk.NewCoins(sdk.NewCoin(IBCTestDenom, math.OneInt())),
+			withOracle:      false,
+			expectedDenom:   "testcoin",
+			expectedRateMul: 1,
+		},
+		{
+			name:           "Missing price is rejected",
+			txFee:          sdk.NewCoins(sdk.NewCoin(IBCTestDenom, math.OneInt())),
+			withOracle:     true,
+			priceUpdatedAt: nil,
+			expectedErr:    ante.ErrPriceNotFound,
+		},
+		{
+			name:           "Stale price is rejected",
+			txFee:          sdk.NewCoins(sdk.NewCoin(IBCTestDenom, math.OneInt())),
+			withOracle:     true,
+			priceUpdatedAt: &stalePrice,
+			expectedErr:    ante.ErrStalePrice,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("Case %s", tc.name), func(t *testing.T) {
+			// At each run we restart our setup with a new fee decorator
+			s := SetupTestSuite(t, false)
+			s.ctx = s.ctx.WithBlockTime(blockTime)
+			s.feeHandler.params.AcceptedFeeDenoms = []string{IBCTestDenom}
+			s.feeHandler.params.MaxPriceAge = time.Minute
+
+			// 1 testcoin is worth 2 IBC coins
+			var oracle ante.PriceOracle
+			if tc.withOracle {
+				oracleMock := NewPriceOracleMock()
+				if tc.priceUpdatedAt != nil {
+					oracleMock.SetExchangeRate("testcoin", IBCTestDenom, sdk.NewDec(2), *tc.priceUpdatedAt)
+				}
+				oracle = oracleMock
+			}
+
+			dfd := ante.NewWeightedFeeDecorator(s.bankKeeper, s.feeHandler, ante.WithPriceOracle(oracle))
+			antehandler := sdk.ChainAnteDecorators(dfd)
+
+			tx := createTXWithFee(t, msgs, tc.txFee)
+
+			if tc.expectedErr == nil {
+				txBytes, err := authtx.DefaultTxEncoder()(tx)
+				require.NoError(t, err)
+				extraBytes := int64(len(txBytes)) - int64(DefaultMinTxSize)
+				expectedFee := sdk.NewCoins(sdk.NewCoin(tc.expectedDenom, math.NewInt(extraBytes*tc.expectedRateMul)))
+
+				s.bankKeeper.EXPECT().SendCoinsFromAccountToModule(gomock.Any(), gomock.Any(), gomock.Any(), expectedFee).Return(nil)
+			}
+
+			// Run the antehandler
+			_, err := antehandler(s.ctx, tx, false)
+			if tc.expectedErr != nil {
+				require.ErrorIs(t, err, tc.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+// TestAcceptedFeeDenomsValidation tests the validation of the accepted fee denoms params
+func TestAcceptedFeeDenomsValidation(t *testing.T) {
+	params := newTestParams(1, DefaultMinTxSize)
+	params.AcceptedFeeDenoms = []string{IBCTestDenom}
+
+	// A max price age is required
+	require.ErrorIs(t, params.Validate(), ante.ErrInvalidParams)
+	params.MaxPriceAge = time.Minute
+	require.NoError(t, params.Validate())
+
+	// Duplicated and invalid denoms are rejected
+	params.AcceptedFeeDenoms = []string{IBCTestDenom, IBCTestDenom}
+	require.ErrorIs(t, params.Validate(), ante.ErrInvalidParams)
+	params.AcceptedFeeDenoms = []string{"1invalid"}
+	require.ErrorIs(t, params.Validate(), ante.ErrInvalidParams)
+}
+
+// createTXWithFee creates a new testing tx with a fee from current encoding
+func createTXWithFee(t *testing.T, msgs []sdk.Msg, fee sdk.Coins) signing.Tx {
+	encodingConfig := testutil.MakeTestEncodingConfig()
+	txBuilder := encodingConfig.TxConfig.NewTxBuilder()
+
+	err := txBuilder.SetMsgs(msgs...)
+	require.NoError(t, err)
+	txBuilder.SetFeeAmount(fee)
+
+	return txBuilder.GetTx()
+}
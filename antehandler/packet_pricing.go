@@ -0,0 +1,96 @@
+// Packet data aware pricing for IBC packet msgs
+// A relay TX carries proofs that the packet sender never controlled, so only the packet data is priced
+// For acknowledgements and timeouts this chain is the sending chain, so the original sender can be charged
+package antehandler
+
+import (
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	channeltypes "github.com/cosmos/ibc-go/v7/modules/core/04-channel/types"
+	ibcexported "github.com/cosmos/ibc-go/v7/modules/core/exported"
+)
+
+// packetPricing is the result of the packet data aware pricing of a TX
+type packetPricing struct {
+	// excludedBytes are the bytes of the packet msgs that are not packet data, such as proofs
+	excludedBytes int
+	// sender is the original sender of the packets
+	// It is only set if all the packets have the same sender on this chain
+	sender sdk.AccAddress
+}
+
+// getPacketPricing returns the bytes to be excluded from the TX size and the original packets sender
+// The msgs should already be unwrapped, so packets inside wrappers are also priced by their data
+func (wfd WeightedFeeDecorator) getPacketPricing(msgs []sdk.Msg) packetPricing {
+	pricing := packetPricing{}
+	senders := []sdk.AccAddress{}
+	totalPackets := 0
+
+	for _, msg := range msgs {
+		var packet channeltypes.Packet
+		var msgSize int
+		// The sender is only valid on the chain that sent the packet
+		isSendingChain := false
+
+		switch packetMsg := msg.(type) {
+		case *channeltypes.MsgRecvPacket:
+			packet, msgSize = packetMsg.Packet, packetMsg.Size()
+		case *channeltypes.MsgAcknowledgement:
+			packet, msgSize, isSendingChain = packetMsg.Packet, packetMsg.Size(), true
+		case *channeltypes.MsgTimeout:
+			packet, msgSize, isSendingChain = packetMsg.Packet, packetMsg.Size(), true
+		case *channeltypes.MsgTimeoutOnClose:
+			packet, msgSize, isSendingChain = packetMsg.Packet, packetMsg.Size(), true
+		default:
+			continue
+		}
+
+		// Only the packet data is kept from the msg
+		totalPackets++
+		pricing.excludedBytes += msgSize - len(packet.Data)
+
+		if !isSendingChain {
+			continue
+		}
+		if sender := wfd.getPacketSender(packet); sender != nil {
+			senders = append(senders, sender)
+		}
+	}
+
+	// The sender pays only if every packet has the same sender, otherwise the fee payer pays
+	if len(senders) == 0 || len(senders) != totalPackets {
+		return pricing
+	}
+	for _, sender := range senders[1:] {
+		if !sender.Equals(senders[0]) {
+			return pricing
+		}
+	}
+	pricing.sender = senders[0]
+
+	return pricing
+}
+
+// getPacketSender returns the original sender of a packet, if present and valid on this chain
+func (wfd WeightedFeeDecorator) getPacketSender(packet channeltypes.Packet) sdk.AccAddress {
+	if wfd.packetDataUnmarshaler == nil {
+		return nil
+	}
+
+	data, err := wfd.packetDataUnmarshaler.UnmarshalPacketData(packet.GetData())
+	if err != nil {
+		return nil
+	}
+
+	// Not all the packet data have a sender
+	packetData, ok := data.(ibcexported.PacketData)
+	if !ok {
+		return nil
+	}
+
+	sender, err := sdk.AccAddressFromBech32(packetData.GetPacketSender(packet.GetSourcePort()))
+	if err != nil {
+		return nil
+	}
+
+	return sender
+}
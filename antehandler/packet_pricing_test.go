@@ -0,0 +1,186 @@
+package antehandler_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	"cosmossdk.io/math"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	"github.com/cosmos/ibc-go/v7/modules/apps/transfer"
+	transfertypes "github.com/cosmos/ibc-go/v7/modules/apps/transfer/types"
+	clienttypes "github.com/cosmos/ibc-go/v7/modules/core/02-client/types"
+	channeltypes "github.com/cosmos/ibc-go/v7/modules/core/04-channel/types"
+
+	ante "ibc-fee/antehandler"
+)
+
+// newTransferPacket returns a transfer packet sent by the given sender
+func newTransferPacket(sender string) channeltypes.Packet {
+	data := transfertypes.NewFungibleTokenPacketData("testcoin", "100", sender, "receiver", "")
+	return channeltypes.NewPacket(
+		data.GetBytes(), 1, "transfer", "channel-0", "transfer", "channel-1", clienttypes.NewHeight(0, 100), 0,
+	)
+}
+
+// TestWeightedFeeAntePacketData tests the packet data aware pricing of IBC packet msgs
+func TestWeightedFeeAntePacketData(t *testing.T) {
+	// Prepare the testing data
+	relayer := sdk.AccAddress([]byte("relayer"))
+	sender := sdk.AccAddress([]byte("sender"))
+	otherSender := sdk.AccAddress([]byte("other_sender"))
+	remoteSender := "osmo1qqqsyqcyq5rqwzqfpg9scrgwpugpzysnrujsuw" // Sender on the counterparty chain
+	proof := bytes.Repeat([]byte{1}, 500)
+	proofHeight := clienttypes.NewHeight(0, 10)
+
+	// All the test cases, the expected size is the priced size of the TX
+	// Without the proofs the packet msgs only keep their packet data
+	testCases := []struct {
+		name              string
+		msgs              []sdk.Msg
+		packetDataPricing bool
+		expectedSize      int
+		expectedPayer     sdk.AccAddress
+	}{
+		{
+			name: "Full size charged to the relayer without packet data pricing",
+			msgs: []sdk.Msg{
+				channeltypes.NewMsgAcknowledgement(newTransferPacket(sender.String()), []byte("ack"), proof, proofHeight, relayer.String()),
+			},
+			packetDataPricing: false,
+			expectedSize:      738,
+			expectedPayer:     relayer,
+		},
+		{
+			name: "Recv packet is charged to the relayer, the sender is on the counterparty chain",
+			msgs: []sdk.Msg{
+				channeltypes.NewMsgRecvPacket(newTransferPacket(remoteSender), proof, proofHeight, relayer.String()),
+			},
+			packetDataPricing: true,
+			expectedSize:      161,
+			expectedPayer:     relayer,
+		},
+		{
+			name: "Acknowledgement is charged to the original sender",
+			msgs: []sdk.Msg{
+				channeltypes.NewMsgAcknowledgement(newTransferPacket(sender.String()), []byte("ack"), proof, proofHeight, relayer.String()),
+			},
+			packetDataPricing: true,
+			expectedSize:      146,
+			expectedPayer:     sender,
+		},
+		{
+			name: "Timeout is charged to the original sender",
+			msgs: []sdk.Msg{
+				channeltypes.NewMsgTimeout(newTransferPacket(sender.String()), 1, proof, proofHeight, relayer.String()),
+			},
+			packetDataPricing: true,
+			expectedSize:      138,
+			expectedPayer:     sender,
+		},
+		{
+			name: "Timeout on close is charged to the original sender",
+			msgs: []sdk.Msg{
+				channeltypes.NewMsgTimeoutOnClose(newTransferPacket(sender.String()), 1, proof, proof, proofHeight, relayer.String()),
+			},
+			packetDataPricing: true,
+			expectedSize:      145,
+			expectedPayer:     sender,
+		},
+		{
+			name: "Packets with different senders are charged to the relayer",
+			msgs: []sdk.Msg{
+				channeltypes.NewMsgTimeout(newTransferPacket(sender.String()), 1, proof, proofHeight, relayer.String()),
+				channeltypes.NewMsgTimeout(newTransferPacket(otherSender.String()), 1, proof, proofHeight, relayer.String()),
+			},
+			packetDataPricing: true,
+			expectedSize:      279,
+			expectedPayer:     relayer,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("Case %s", tc.name), func(t *testing.T) {
+			// At each run we restart our setup with a new fee decorator
+			s := SetupTestSuite(t, false)
+			s.feeHandler.params.PacketDataPricing = tc.packetDataPricing
+			dfd := ante.NewWeightedFeeDecorator(s.bankKeeper, s.feeHandler, ante.WithPacketDataUnmarshaler(transfer.IBCModule{}))
+			antehandler := sdk.ChainAnteDecorators(dfd)
+
+			tx := createTX(t, tc.msgs)
+
+			expectedFee := sdk.NewCoins(sdk.NewCoin("testcoin", math.NewInt(int64(tc.expectedSize)-int64(DefaultMinTxSize))))
+
+			// Expect the call with the correct payer and balance
+			s.bankKeeper.EXPECT().SendCoinsFromAccountToModule(gomock.Any(), tc.expectedPayer, gomock.Any(), expectedFee).Return(nil)
+
+			// Run the antehandler
+			newCtx, err := antehandler(s.ctx, tx, false)
+			require.NoError(t, err)
+
+			event, found := findBytesFeeEvent(newCtx.EventManager().Events())
+			require.True(t, found)
+			require.Equal(t, tc.expectedPayer.String(), eventAttribute(event, sdk.AttributeKeyFeePayer))
+		})
+	}
+}
+
+// TestWeightedFeeAntePacketSenderFallback tests the TX fee payer being charged when the packet sender can't pay
+func TestWeightedFeeAntePacketSenderFallback(t *testing.T) {
+	relayer := sdk.AccAddress([]byte("relayer"))
+	sender := sdk.AccAddress([]byte("sender"))
+	proof := bytes.Repeat([]byte{1}, 500)
+	msgs := []sdk.Msg{
+		channeltypes.NewMsgTimeout(newTransferPacket(sender.String()), 1, proof, clienttypes.NewHeight(0, 10), relayer.String()),
+	}
+	expectedFee := sdk.NewCoins(sdk.NewCoin("testcoin", math.NewInt(138-int64(DefaultMinTxSize))))
+
+	// All the test cases
+	testCases := []struct {
+		name          string
+		relayerErr    error
+		expectedPayer sdk.AccAddress
+		expectedErr   error
+	}{
+		{
+			name:          "Relayer pays for a sender without funds",
+			relayerErr:    nil,
+			expectedPayer: relayer,
+		},
+		{
+			name:        "Relayer without funds is rejected",
+			relayerErr:  sdkerrors.ErrInsufficientFunds,
+			expectedErr: ante.ErrInsufficientByteFee,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("Case %s", tc.name), func(t *testing.T) {
+			s := SetupTestSuite(t, false)
+			s.feeHandler.params.PacketDataPricing = true
+			dfd := ante.NewWeightedFeeDecorator(s.bankKeeper, s.feeHandler, ante.WithPacketDataUnmarshaler(transfer.IBCModule{}))
+			antehandler := sdk.ChainAnteDecorators(dfd)
+
+			// The sender is tried first, then the relayer
+			gomock.InOrder(
+				s.bankKeeper.EXPECT().SendCoinsFromAccountToModule(gomock.Any(), sender, gomock.Any(), expectedFee).Return(sdkerrors.ErrInsufficientFunds),
+				s.bankKeeper.EXPECT().SendCoinsFromAccountToModule(gomock.Any(), relayer, gomock.Any(), expectedFee).Return(tc.relayerErr),
+			)
+
+			newCtx, err := antehandler(s.ctx, createTX(t, msgs), false)
+			if tc.expectedErr != nil {
+				require.ErrorIs(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+
+			event, found := findBytesFeeEvent(newCtx.EventManager().Events())
+			require.True(t, found)
+			require.Equal(t, tc.expectedPayer.String(), eventAttribute(event, sdk.AttributeKeyFeePayer))
+		})
+	}
+}
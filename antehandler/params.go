@@ -0,0 +1,166 @@
+package antehandler
+
+import (
+	"time"
+
+	errorsmod "cosmossdk.io/errors"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+const (
+	// DefaultMaxMsgNestingDepth is the default max depth of msgs nested inside wrapper msgs
+	DefaultMaxMsgNestingDepth uint64 = 5
+	// DefaultReceiptRetentionBlocks is the default amount of blocks the byte fee receipts are kept, a day of 5s blocks
+	DefaultReceiptRetentionBlocks uint64 = 17_280
+)
+
+// FeeHandlerParams are the params for the simulated feeHandlerModule
+type FeeHandlerParams struct {
+	// Enabled is the feature gate of the byte fees, while disabled no byte fee is charged
+	Enabled bool
+	// ActivationHeight is the first height where the byte fees are charged, zero charges from any height
+	ActivationHeight int64
+	// FeeBytePrice is the price for each byte in a TX
+	FeeBytePrice sdk.DecCoins
+	// The minimum size a TX must have
+	MinTxSize uint64
+	// SurgeWindowBlocks is the size, in blocks, of the window used to track the bytes sent by each fee payer
+	SurgeWindowBlocks uint64
+	// SurgeByteBudget is the amount of bytes a fee payer can send on the window before paying surge prices
+	// Zero disables the surge pricing
+	SurgeByteBudget uint64
+	// SurgeMultiplierStep is how much the price multiplier increases for each budget sent above the budget
+	SurgeMultiplierStep sdk.Dec
+	// MaxSurgeMultiplier caps the price multiplier of a fee payer
+	MaxSurgeMultiplier sdk.Dec
+	// MemoBytePrice is the price for each memo byte above the free allowance
+	// If empty, the memo bytes are priced as any other byte
+	MemoBytePrice sdk.DecCoins
+	// FreeMemoBytes is the amount of memo bytes that are not charged
+	FreeMemoBytes uint64
+	// PacketDataPricing prices IBC packet msgs only by their packet data size, excluding proofs
+	PacketDataPricing bool
+	// AcceptedFeeDenoms are the non native denoms, such as IBC vouchers, accepted to pay the byte fees
+	// The byte prices are converted to these denoms with the price oracle
+	AcceptedFeeDenoms []string
+	// MaxPriceAge is the maximum age of a oracle price, older prices are considered stale
+	MaxPriceAge time.Duration
+	// MaxMsgNestingDepth is the max depth of msgs nested inside wrappers, such as authz MsgExec
+	// TXs with msgs nested deeper are rejected
+	MaxMsgNestingDepth uint64
+	// ReceiptRetentionBlocks is the amount of blocks the byte fee receipts are kept before being pruned
+	// Each receipt adds two store writes paid by the TX gas, zero disables the receipts
+	ReceiptRetentionBlocks uint64
+	// RelayerByteFeeDiscount is the discount on the byte fees of the registered relayers IBC core TXs
+	// Zero disables the discount and one exempts the registered relayers from the byte fees
+	RelayerByteFeeDiscount sdk.Dec
+}
+
+// DefaultFeeHandlerParams returns the default params
+// By default no extra fee is charged since the byte fees are disabled and there is no byte price
+func DefaultFeeHandlerParams() FeeHandlerParams {
+	return FeeHandlerParams{
+		Enabled:                false,
+		ActivationHeight:       0,
+		FeeBytePrice:           sdk.NewDecCoins(),
+		MinTxSize:              0,
+		SurgeWindowBlocks:      0,
+		SurgeByteBudget:        0,
+		SurgeMultiplierStep:    sdk.ZeroDec(),
+		MaxSurgeMultiplier:     sdk.OneDec(),
+		MemoBytePrice:          sdk.NewDecCoins(),
+		FreeMemoBytes:          0,
+		PacketDataPricing:      false,
+		AcceptedFeeDenoms:      []string{},
+		MaxPriceAge:            0,
+		MaxMsgNestingDepth:     DefaultMaxMsgNestingDepth,
+		ReceiptRetentionBlocks: DefaultReceiptRetentionBlocks,
+		RelayerByteFeeDiscount: sdk.ZeroDec(),
+	}
+}
+
+// Validate validates the params
+func (p FeeHandlerParams) Validate() error {
+	if p.ActivationHeight < 0 {
+		return errorsmod.Wrap(ErrInvalidParams, "activation height can't be negative")
+	}
+	if err := p.FeeBytePrice.Validate(); err != nil {
+		return errorsmod.Wrapf(ErrInvalidParams, "invalid fee byte price: %s", err)
+	}
+	if err := p.MemoBytePrice.Validate(); err != nil {
+		return errorsmod.Wrapf(ErrInvalidParams, "invalid memo byte price: %s", err)
+	}
+	if err := validateAcceptedFeeDenoms(p.AcceptedFeeDenoms); err != nil {
+		return err
+	}
+	if len(p.AcceptedFeeDenoms) > 0 && p.MaxPriceAge <= 0 {
+		return errorsmod.Wrap(ErrInvalidParams, "max price age must be positive if fee denoms are accepted")
+	}
+	if p.RelayerByteFeeDiscount.IsNil() || p.RelayerByteFeeDiscount.IsNegative() || p.RelayerByteFeeDiscount.GT(sdk.OneDec()) {
+		return errorsmod.Wrap(ErrInvalidParams, "relayer byte fee discount must be between zero and one")
+	}
+	if p.MaxMsgNestingDepth == 0 {
+		return errorsmod.Wrap(ErrInvalidParams, "max msg nesting depth must be positive")
+	}
+
+	// The surge params are only validated if the surge pricing is enabled
+	if !p.IsSurgePricingEnabled() {
+		return nil
+	}
+	if p.SurgeWindowBlocks == 0 {
+		return errorsmod.Wrap(ErrInvalidParams, "surge window blocks must be positive")
+	}
+	if p.SurgeMultiplierStep.IsNil() || !p.SurgeMultiplierStep.IsPositive() {
+		return errorsmod.Wrap(ErrInvalidParams, "surge multiplier step must be positive")
+	}
+	if p.MaxSurgeMultiplier.IsNil() || p.MaxSurgeMultiplier.LT(sdk.OneDec()) {
+		return errorsmod.Wrap(ErrInvalidParams, "max surge multiplier must be greater or equal to one")
+	}
+
+	return nil
+}
+
+// IsActive returns true if the byte fees are charged at the given height
+func (p FeeHandlerParams) IsActive(height int64) bool {
+	return p.Enabled && height >= p.ActivationHeight
+}
+
+// IsSurgePricingEnabled returns true if fee payers are charged more after passing the byte budget
+func (p FeeHandlerParams) IsSurgePricingEnabled() bool {
+	return p.SurgeByteBudget > 0
+}
+
+// IsMemoPricingEnabled returns true if the memo bytes have their own price
+func (p FeeHandlerParams) IsMemoPricingEnabled() bool {
+	return !p.MemoBytePrice.IsZero()
+}
+
+// IsReceiptsEnabled returns true if a receipt is stored for each TX charged with byte fees
+func (p FeeHandlerParams) IsReceiptsEnabled() bool {
+	return p.ReceiptRetentionBlocks > 0
+}
+
+// IsAcceptedFeeDenom returns true if the denom can be used to pay the byte fees through the oracle
+func (p FeeHandlerParams) IsAcceptedFeeDenom(denom string) bool {
+	for _, acceptedDenom := range p.AcceptedFeeDenoms {
+		if acceptedDenom == denom {
+			return true
+		}
+	}
+	return false
+}
+
+// validateAcceptedFeeDenoms checks that the denoms are valid and not duplicated
+func validateAcceptedFeeDenoms(denoms []string) error {
+	seenDenoms := make(map[string]bool)
+	for _, denom := range denoms {
+		if err := sdk.ValidateDenom(denom); err != nil {
+			return errorsmod.Wrapf(ErrInvalidParams, "invalid accepted fee denom: %s", err)
+		}
+		if seenDenoms[denom] {
+			return errorsmod.Wrapf(ErrInvalidParams, "duplicated accepted fee denom %s", denom)
+		}
+		seenDenoms[denom] = true
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+// Queries of the simulated feeHandler module
+// Since there is no protobuf definition behind this module, the querier follows the gRPC query server signatures
+// so it can be registered as a query service once the module has one
+package antehandler
+
+import (
+	"context"
+	"encoding/hex"
+
+	errorsmod "cosmossdk.io/errors"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	errortypes "github.com/cosmos/cosmos-sdk/types/errors"
+)
+
+// QueryBytesFeeReceiptRequest is the request of the byte fee receipt of a TX
+type QueryBytesFeeReceiptRequest struct {
+	// TxHash is the hex encoded hash of the TX, as shown by the explorers
+	TxHash string
+}
+
+// QueryBytesFeeReceiptResponse is the response with the byte fee receipt of a TX
+type QueryBytesFeeReceiptResponse struct {
+	Receipt BytesFeeReceipt
+}
+
+// QueryScheduledParamsUpdatesRequest is the request of the upcoming params updates
+type QueryScheduledParamsUpdatesRequest struct{}
+
+// QueryScheduledParamsUpdatesResponse is the response with the upcoming params updates ordered by activation height
+type QueryScheduledParamsUpdatesResponse struct {
+	Updates []ScheduledParamsUpdate
+}
+
+// QuerySurgeMultiplierRequest is the request of the surge multiplier of a fee payer
+type QuerySurgeMultiplierRequest struct {
+	// Address is the bech32 address of the fee payer
+	Address string
+}
+
+// QuerySurgeMultiplierResponse is the response with the current byte price multiplier of a fee payer
+type QuerySurgeMultiplierResponse struct {
+	Multiplier sdk.Dec
+}
+
+// QueryRelayerStatusRequest is the request of the registration status of a relayer
+type QueryRelayerStatusRequest struct {
+	// Address is the bech32 address of the relayer
+	Address string
+}
+
+// QueryRelayerStatusResponse is the response with the registration status of a relayer
+type QueryRelayerStatusResponse struct {
+	Status RelayerStatus
+}
+
+// Querier serves the queries of the feeHandler module
+type Querier struct {
+	keeper FeeHandlerKeeper
+}
+
+// NewQuerier returns a new querier over the feeHandler keeper
+func NewQuerier(keeper FeeHandlerKeeper) Querier {
+	return Querier{keeper: keeper}
+}
+
+// BytesFeeReceipt returns the byte fee receipt of a TX
+// This is the query used by the support tooling to explain the byte fee charged on a TX
+func (q Querier) BytesFeeReceipt(goCtx context.Context, req *QueryBytesFeeReceiptRequest) (*QueryBytesFeeReceiptResponse, error) {
+	if req == nil {
+		return nil, errorsmod.Wrap(errortypes.ErrInvalidRequest, "empty request")
+	}
+
+	txHash, err := hex.DecodeString(req.TxHash)
+	if err != nil || len(txHash) == 0 {
+		return nil, errorsmod.Wrapf(errortypes.ErrInvalidRequest, "invalid tx hash %q", req.TxHash)
+	}
+
+	receipt, found := q.keeper.GetBytesFeeReceipt(sdk.UnwrapSDKContext(goCtx), txHash)
+	if !found {
+		return nil, errorsmod.Wrapf(ErrReceiptNotFound, "no receipt for tx %s, it was not charged or was pruned", req.TxHash)
+	}
+
+	return &QueryBytesFeeReceiptResponse{Receipt: receipt}, nil
+}
+
+// ScheduledParamsUpdates returns the upcoming params updates
+// This is the query used by wallets to warn users of upcoming fee changes
+func (q Querier) ScheduledParamsUpdates(goCtx context.Context, req *QueryScheduledParamsUpdatesRequest) (*QueryScheduledParamsUpdatesResponse, error) {
+	if req == nil {
+		return nil, errorsmod.Wrap(errortypes.ErrInvalidRequest, "empty request")
+	}
+
+	updates := q.keeper.GetScheduledParamsUpdates(sdk.UnwrapSDKContext(goCtx))
+	return &QueryScheduledParamsUpdatesResponse{Updates: updates}, nil
+}
+
+// SurgeMultiplier returns the current byte price multiplier of a fee payer
+func (q Querier) SurgeMultiplier(goCtx context.Context, req *QuerySurgeMultiplierRequest) (*QuerySurgeMultiplierResponse, error) {
+	if req == nil {
+		return nil, errorsmod.Wrap(errortypes.ErrInvalidRequest, "empty request")
+	}
+
+	feePayer, err := sdk.AccAddressFromBech32(req.Address)
+	if err != nil {
+		return nil, errorsmod.Wrapf(errortypes.ErrInvalidAddress, "invalid fee payer address: %s", err)
+	}
+
+	multiplier := q.keeper.GetSurgeMultiplier(sdk.UnwrapSDKContext(goCtx), feePayer)
+	return &QuerySurgeMultiplierResponse{Multiplier: multiplier}, nil
+}
+
+// RelayerStatus returns the registration status of a address
+// This is the query used by the relayers to check their own status, unregistered addresses are not a error
+func (q Querier) RelayerStatus(goCtx context.Context, req *QueryRelayerStatusRequest) (*QueryRelayerStatusResponse, error) {
+	if req == nil {
+		return nil, errorsmod.Wrap(errortypes.ErrInvalidRequest, "empty request")
+	}
+
+	relayerAddr, err := sdk.AccAddressFromBech32(req.Address)
+	if err != nil {
+		return nil, errorsmod.Wrapf(errortypes.ErrInvalidAddress, "invalid relayer address: %s", err)
+	}
+
+	status := q.keeper.GetRelayerStatus(sdk.UnwrapSDKContext(goCtx), relayerAddr)
+	return &QueryRelayerStatusResponse{Status: status}, nil
+}
@@ -0,0 +1,78 @@
+// Byte fee discount of the registered relayers
+// Relaying pays large TXs, such as MsgUpdateClient with the validator set, so governance can discount them
+// The discount only applies to TXs paid by a registered relayer and made up only of IBC core msgs
+package antehandler
+
+import (
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	clienttypes "github.com/cosmos/ibc-go/v7/modules/core/02-client/types"
+	connectiontypes "github.com/cosmos/ibc-go/v7/modules/core/03-connection/types"
+	channeltypes "github.com/cosmos/ibc-go/v7/modules/core/04-channel/types"
+)
+
+// getRelayerDiscount returns the byte fee discount of the fee payer
+// The msgs should already be unwrapped, so wrapped non IBC msgs are not discounted
+func (wfd WeightedFeeDecorator) getRelayerDiscount(
+	ctx sdk.Context,
+	feePayer sdk.AccAddress,
+	msgs []sdk.Msg,
+	params FeeHandlerParams,
+) sdk.Dec {
+	if len(msgs) == 0 || params.RelayerByteFeeDiscount.IsNil() || !params.RelayerByteFeeDiscount.IsPositive() {
+		return sdk.ZeroDec()
+	}
+
+	relayer, found := wfd.feeHandler.GetRelayer(ctx, feePayer)
+	if !found {
+		return sdk.ZeroDec()
+	}
+
+	for _, msg := range msgs {
+		channel, isIBCCore := getIBCCoreMsgChannel(msg)
+		if !isIBCCore {
+			return sdk.ZeroDec()
+		}
+		// Msgs that aren't tied to a channel, such as client updates, are only discounted for relayers of every channel
+		if !relayer.IsChannelAllowed(channel) {
+			return sdk.ZeroDec()
+		}
+	}
+
+	return params.RelayerByteFeeDiscount
+}
+
+// getIBCCoreMsgChannel returns the channel on this chain of a IBC core msg and if the msg is a IBC core msg
+// The channel is empty for the msgs that are not tied to an existing channel
+func getIBCCoreMsgChannel(msg sdk.Msg) (string, bool) {
+	switch ibcMsg := msg.(type) {
+	// Packets received by this chain are on the destination channel
+	case *channeltypes.MsgRecvPacket:
+		return ibcMsg.Packet.DestinationChannel, true
+	// Acknowledgements and timeouts are for packets sent by this chain, so they are on the source channel
+	case *channeltypes.MsgAcknowledgement:
+		return ibcMsg.Packet.SourceChannel, true
+	case *channeltypes.MsgTimeout:
+		return ibcMsg.Packet.SourceChannel, true
+	case *channeltypes.MsgTimeoutOnClose:
+		return ibcMsg.Packet.SourceChannel, true
+	// Channel handshakes after the channel was created on this chain
+	case *channeltypes.MsgChannelOpenAck:
+		return ibcMsg.ChannelId, true
+	case *channeltypes.MsgChannelOpenConfirm:
+		return ibcMsg.ChannelId, true
+	case *channeltypes.MsgChannelCloseInit:
+		return ibcMsg.ChannelId, true
+	case *channeltypes.MsgChannelCloseConfirm:
+		return ibcMsg.ChannelId, true
+	case *channeltypes.MsgChannelOpenInit, *channeltypes.MsgChannelOpenTry:
+		return "", true
+	case *clienttypes.MsgCreateClient, *clienttypes.MsgUpdateClient,
+		*clienttypes.MsgUpgradeClient, *clienttypes.MsgSubmitMisbehaviour:
+		return "", true
+	case *connectiontypes.MsgConnectionOpenInit, *connectiontypes.MsgConnectionOpenTry,
+		*connectiontypes.MsgConnectionOpenAck, *connectiontypes.MsgConnectionOpenConfirm:
+		return "", true
+	default:
+		return "", false
+	}
+}
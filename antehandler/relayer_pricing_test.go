@@ -0,0 +1,160 @@
+package antehandler_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	authtx "github.com/cosmos/cosmos-sdk/x/auth/tx"
+	banktyppes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	clienttypes "github.com/cosmos/ibc-go/v7/modules/core/02-client/types"
+	channeltypes "github.com/cosmos/ibc-go/v7/modules/core/04-channel/types"
+
+	ante "ibc-fee/antehandler"
+)
+
+// newUpdateClientMsg returns a large MsgUpdateClient signed by the relayer
+func newUpdateClientMsg(relayer sdk.AccAddress) *clienttypes.MsgUpdateClient {
+	return &clienttypes.MsgUpdateClient{
+		ClientId:      "07-tendermint-0",
+		ClientMessage: &codectypes.Any{TypeUrl: "/ibc.lightclients.tendermint.v1.Header", Value: bytes.Repeat([]byte{1}, 500)},
+		Signer:        relayer.String(),
+	}
+}
+
+// TestWeightedFeeAnteRelayerDiscount tests the byte fee discount of the registered relayers
+func TestWeightedFeeAnteRelayerDiscount(t *testing.T) {
+	// Prepare the testing data
+	relayer := sdk.AccAddress([]byte("relayer"))
+	proof := bytes.Repeat([]byte{1}, 500)
+	proofHeight := clienttypes.NewHeight(0, 10)
+	recvPacket := channeltypes.NewMsgRecvPacket(newTransferPacket("sender"), proof, proofHeight, relayer.String())
+	bankSend := banktyppes.NewMsgSend(relayer, relayer, sdk.NewCoins(sdk.NewCoin("utestcoin", sdk.OneInt())))
+
+	// All the test cases
+	testCases := []struct {
+		name             string
+		msgs             []sdk.Msg
+		registered       bool
+		channels         []string
+		discount         sdk.Dec
+		expectedDiscount sdk.Dec
+	}{
+		{
+			name:             "Not registered relayer pays the full byte fee",
+			msgs:             []sdk.Msg{newUpdateClientMsg(relayer), recvPacket},
+			registered:       false,
+			discount:         sdk.OneDec(),
+			expectedDiscount: sdk.ZeroDec(),
+		},
+		{
+			name:             "Registered relayer is exempt on IBC core msgs",
+			msgs:             []sdk.Msg{newUpdateClientMsg(relayer), recvPacket},
+			registered:       true,
+			discount:         sdk.OneDec(),
+			expectedDiscount: sdk.OneDec(),
+		},
+		{
+			name:             "Registered relayer gets a partial discount",
+			msgs:             []sdk.Msg{newUpdateClientMsg(relayer), recvPacket},
+			registered:       true,
+			discount:         sdk.NewDecWithPrec(5, 1),
+			expectedDiscount: sdk.NewDecWithPrec(5, 1),
+		},
+		{
+			name:             "Registered relayer pays the full byte fee with non IBC msgs",
+			msgs:             []sdk.Msg{newUpdateClientMsg(relayer), bankSend},
+			registered:       true,
+			discount:         sdk.OneDec(),
+			expectedDiscount: sdk.ZeroDec(),
+		},
+		{
+			name:             "Registered relayer pays the full byte fee for wrapped non IBC msgs",
+			msgs:             []sdk.Msg{newUpdateClientMsg(relayer), newMsgExec(relayer, bankSend)},
+			registered:       true,
+			discount:         sdk.OneDec(),
+			expectedDiscount: sdk.ZeroDec(),
+		},
+		{
+			name:             "Channel scoped relayer is exempt on its channel",
+			msgs:             []sdk.Msg{recvPacket},
+			registered:       true,
+			channels:         []string{recvPacket.Packet.DestinationChannel},
+			discount:         sdk.OneDec(),
+			expectedDiscount: sdk.OneDec(),
+		},
+		{
+			name:             "Channel scoped relayer pays the full byte fee on msgs not tied to a channel",
+			msgs:             []sdk.Msg{newUpdateClientMsg(relayer), recvPacket},
+			registered:       true,
+			channels:         []string{recvPacket.Packet.DestinationChannel},
+			discount:         sdk.OneDec(),
+			expectedDiscount: sdk.ZeroDec(),
+		},
+		{
+			name:             "Channel scoped relayer pays the full byte fee on channel handshakes without a channel",
+			msgs:             []sdk.Msg{&channeltypes.MsgChannelOpenInit{PortId: "transfer", Signer: relayer.String()}, recvPacket},
+			registered:       true,
+			channels:         []string{recvPacket.Packet.DestinationChannel},
+			discount:         sdk.OneDec(),
+			expectedDiscount: sdk.ZeroDec(),
+		},
+		{
+			name:             "Params stored without a discount don't discount",
+			msgs:             []sdk.Msg{newUpdateClientMsg(relayer), recvPacket},
+			registered:       true,
+			discount:         sdk.Dec{},
+			expectedDiscount: sdk.ZeroDec(),
+		},
+		{
+			name:             "Channel scoped relayer pays the full byte fee on other channels",
+			msgs:             []sdk.Msg{newUpdateClientMsg(relayer), recvPacket},
+			registered:       true,
+			channels:         []string{"channel-7"},
+			discount:         sdk.OneDec(),
+			expectedDiscount: sdk.ZeroDec(),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("Case %s", tc.name), func(t *testing.T) {
+			// At each run we restart our setup with a new fee decorator
+			s := SetupTestSuite(t, false)
+			s.feeHandler.params.RelayerByteFeeDiscount = tc.discount
+			if tc.registered {
+				s.feeHandler.SetRelayer(ante.Relayer{Address: relayer.String(), Channels: tc.channels})
+			}
+			dfd := ante.NewWeightedFeeDecorator(s.bankKeeper, s.feeHandler)
+			antehandler := sdk.ChainAnteDecorators(dfd)
+
+			// The relayer signs the first msg, so it is the fee payer
+			tx := createTX(t, tc.msgs)
+
+			// The expected fee is the full fee with the discount applied
+			txBytes, err := authtx.DefaultTxEncoder()(tx)
+			require.NoError(t, err)
+			fullFee := sdk.NewDec(int64(len(txBytes)) - int64(DefaultMinTxSize))
+			expectedFee, _ := sdk.NewDecCoins(
+				sdk.NewDecCoinFromDec("testcoin", fullFee.Mul(sdk.OneDec().Sub(tc.expectedDiscount))),
+			).TruncateDecimal()
+
+			// Exempt relayers are never charged
+			if !expectedFee.IsZero() {
+				s.bankKeeper.EXPECT().SendCoinsFromAccountToModule(gomock.Any(), relayer, gomock.Any(), expectedFee).Return(nil)
+			}
+
+			// Run the antehandler
+			newCtx, err := antehandler(s.ctx, tx, false)
+			require.NoError(t, err)
+
+			event, found := findBytesFeeEvent(newCtx.EventManager().Events())
+			require.True(t, found)
+			require.Equal(t, tc.expectedDiscount.String(), eventAttribute(event, ante.AttributeKeyRelayerDiscount))
+		})
+	}
+}
@@ -0,0 +1,205 @@
+// Minimal simulation app for the feeHandler operations tests
+// It runs the real auth and bank keepers on a baseapp, with the weighted fee decorator on the antehandler
+// The consensus params keeper and a single genesis validator let it run under the Cosmos-SDK SimulateFromSeed
+package simulation_test
+
+import (
+	"encoding/json"
+	"math/rand"
+	"testing"
+	"time"
+
+	dbm "github.com/cometbft/cometbft-db"
+	abci "github.com/cometbft/cometbft/abci/types"
+	"github.com/cometbft/cometbft/libs/log"
+	tmproto "github.com/cometbft/cometbft/proto/tendermint/types"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosmos/cosmos-sdk/baseapp"
+	cryptocodec "github.com/cosmos/cosmos-sdk/crypto/codec"
+	"github.com/cosmos/cosmos-sdk/crypto/keys/ed25519"
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/module"
+	moduletestutil "github.com/cosmos/cosmos-sdk/types/module/testutil"
+	simtypes "github.com/cosmos/cosmos-sdk/types/simulation"
+	"github.com/cosmos/cosmos-sdk/x/auth"
+	authante "github.com/cosmos/cosmos-sdk/x/auth/ante"
+	authkeeper "github.com/cosmos/cosmos-sdk/x/auth/keeper"
+	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+	"github.com/cosmos/cosmos-sdk/x/bank"
+	bankkeeper "github.com/cosmos/cosmos-sdk/x/bank/keeper"
+	banktestutil "github.com/cosmos/cosmos-sdk/x/bank/testutil"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	consensusparamkeeper "github.com/cosmos/cosmos-sdk/x/consensus/keeper"
+	consensusparamtypes "github.com/cosmos/cosmos-sdk/x/consensus/types"
+	minttypes "github.com/cosmos/cosmos-sdk/x/mint/types"
+
+	ante "ibc-fee/antehandler"
+	"ibc-fee/antehandler/simulation"
+)
+
+// SimChainID is the chain id of the simulation app
+const SimChainID = "ibc-fee-sim"
+
+// SimApp is a baseapp with the keepers used by the feeHandler simulation
+type SimApp struct {
+	*baseapp.BaseApp
+	encCfg           moduletestutil.TestEncodingConfig
+	accountKeeper    authkeeper.AccountKeeper
+	bankKeeper       bankkeeper.BaseKeeper
+	feeHandlerKeeper ante.FeeHandlerKeeper
+	// consensusParamsKeeper stores the consensus params sent on InitChain, as required by the baseapp
+	consensusParamsKeeper consensusparamkeeper.Keeper
+	// feeHandlerKey is the feeHandler store key, used to compare the stores on the import and export
+	feeHandlerKey *storetypes.KVStoreKey
+	// sm is the simulation manager with the feeHandler simulation module
+	sm *module.SimulationManager
+
+	// The genesis accounts, set by the app state function before the chain is initialized
+	genesisAccounts []simtypes.Account
+}
+
+// NewSimApp creates a new simulation app over a in memory database
+func NewSimApp(t *testing.T) *SimApp {
+	app := &SimApp{encCfg: moduletestutil.MakeTestEncodingConfig(auth.AppModuleBasic{}, bank.AppModuleBasic{})}
+
+	app.BaseApp = baseapp.NewBaseApp(
+		"fee-handler-sim", log.NewNopLogger(), dbm.NewMemDB(), app.encCfg.TxConfig.TxDecoder(), baseapp.SetChainID(SimChainID),
+	)
+	app.SetInterfaceRegistry(app.encCfg.InterfaceRegistry)
+
+	authKey := sdk.NewKVStoreKey(authtypes.StoreKey)
+	bankKey := sdk.NewKVStoreKey(banktypes.StoreKey)
+	consensusKey := sdk.NewKVStoreKey(consensusparamtypes.StoreKey)
+	app.feeHandlerKey = sdk.NewKVStoreKey(ante.FeeHandlerStoreKey)
+	feeHandlerTransientKey := sdk.NewTransientStoreKey(ante.FeeHandlerTransientStoreKey)
+	app.MountStores(authKey, bankKey, consensusKey, app.feeHandlerKey, feeHandlerTransientKey)
+
+	// Initialize the keepers, the mint module is only used to fund the simulation accounts
+	authority := authtypes.NewModuleAddress("gov").String()
+	maccPerms := map[string][]string{
+		authtypes.FeeCollectorName: nil,
+		minttypes.ModuleName:       {authtypes.Minter},
+	}
+	app.accountKeeper = authkeeper.NewAccountKeeper(
+		app.encCfg.Codec,
+		authKey,
+		authtypes.ProtoBaseAccount,
+		maccPerms,
+		sdk.GetConfig().GetBech32AccountAddrPrefix(),
+		authority,
+	)
+	app.bankKeeper = bankkeeper.NewBaseKeeper(app.encCfg.Codec, bankKey, app.accountKeeper, map[string]bool{}, authority)
+	app.feeHandlerKeeper = ante.NewFeeHandlerKeeper(app.feeHandlerKey, feeHandlerTransientKey, app.bankKeeper, authority)
+	app.consensusParamsKeeper = consensusparamkeeper.NewKeeper(app.encCfg.Codec, consensusKey, authority)
+	app.SetParamStore(&app.consensusParamsKeeper)
+
+	banktypes.RegisterMsgServer(app.MsgServiceRouter(), bankkeeper.NewMsgServerImpl(app.bankKeeper))
+
+	app.sm = module.NewSimulationManager(
+		simulation.NewAppModule(app.encCfg.TxConfig, app.accountKeeper, app.bankKeeper, app.feeHandlerKeeper),
+	)
+	app.sm.RegisterStoreDecoders()
+
+	app.SetAnteHandler(sdk.ChainAnteDecorators(
+		authante.NewSetPubKeyDecorator(app.accountKeeper),
+		ante.NewFeeAuditDecorator(app.feeHandlerKeeper),
+		authante.NewDeductFeeDecorator(app.accountKeeper, app.bankKeeper, nil, nil),
+		authante.NewSigVerificationDecorator(app.accountKeeper, app.encCfg.TxConfig.SignModeHandler()),
+		authante.NewIncrementSequenceDecorator(app.accountKeeper),
+		ante.NewWeightedFeeDecorator(app.bankKeeper, app.feeHandlerKeeper),
+	))
+	app.SetBeginBlocker(func(ctx sdk.Context, _ abci.RequestBeginBlock) abci.ResponseBeginBlock {
+		app.feeHandlerKeeper.BeginBlock(ctx)
+		return abci.ResponseBeginBlock{}
+	})
+	app.SetEndBlocker(func(ctx sdk.Context, _ abci.RequestEndBlock) abci.ResponseEndBlock {
+		app.feeHandlerKeeper.EndBlock(ctx)
+		return abci.ResponseEndBlock{}
+	})
+
+	// The app state only carries the feeHandler genesis, the accounts are funded from the genesis accounts
+	app.SetInitChainer(func(ctx sdk.Context, req abci.RequestInitChain) abci.ResponseInitChain {
+		var appState map[string]json.RawMessage
+		require.NoError(t, json.Unmarshal(req.AppStateBytes, &appState))
+		genesis, err := ante.UnmarshalFeeHandlerGenesisState(appState[ante.FeeHandlerModuleName])
+		require.NoError(t, err)
+
+		require.NoError(t, app.accountKeeper.SetParams(ctx, authtypes.DefaultParams()))
+		require.NoError(t, app.bankKeeper.SetParams(ctx, banktypes.DefaultParams()))
+		for _, acc := range app.genesisAccounts {
+			app.accountKeeper.SetAccount(ctx, app.accountKeeper.NewAccountWithAddress(ctx, acc.Address))
+			coins := sdk.NewCoins(sdk.NewCoin(sdk.DefaultBondDenom, sdk.NewInt(1_000_000_000)))
+			require.NoError(t, banktestutil.FundAccount(app.bankKeeper, ctx, acc.Address, coins))
+		}
+		app.feeHandlerKeeper.InitGenesis(ctx, genesis)
+
+		// There is no staking module, so a fixed validator proposes every block
+		return abci.ResponseInitChain{Validators: []abci.ValidatorUpdate{genesisValidator(t)}}
+	})
+
+	require.NoError(t, app.LoadLatestVersion())
+	return app
+}
+
+// InitChain creates and funds the simulation accounts, and initializes the feeHandler with a random genesis
+func (app *SimApp) InitChain(t *testing.T, r *rand.Rand, accs []simtypes.Account) {
+	appState, _, chainID, genesisTime := app.AppStateFn(t)(r, accs, simtypes.Config{})
+	app.BaseApp.InitChain(abci.RequestInitChain{ChainId: chainID, Time: genesisTime, AppStateBytes: appState})
+}
+
+// AppStateFn returns the app state function used by SimulateFromSeed
+// It generates the random genesis states of the simulation manager and keeps the accounts to be funded on InitChain
+func (app *SimApp) AppStateFn(t *testing.T) simtypes.AppStateFn {
+	return func(r *rand.Rand, accs []simtypes.Account, _ simtypes.Config) (json.RawMessage, []simtypes.Account, string, time.Time) {
+		simState := &module.SimulationState{
+			AppParams: make(simtypes.AppParams),
+			Cdc:       app.encCfg.Codec,
+			Rand:      r,
+			GenState:  make(map[string]json.RawMessage),
+			Accounts:  accs,
+			BondDenom: sdk.DefaultBondDenom,
+		}
+		app.sm.GenerateGenesisStates(simState)
+
+		appState, err := json.Marshal(simState.GenState)
+		require.NoError(t, err)
+
+		app.genesisAccounts = accs
+		return appState, accs, SimChainID, time.Unix(0, 0)
+	}
+}
+
+// ModuleAccountAddrs returns the module accounts, which the simulation doesn't use as senders
+func (app *SimApp) ModuleAccountAddrs() map[string]bool {
+	return map[string]bool{
+		authtypes.NewModuleAddress(authtypes.FeeCollectorName).String(): true,
+		authtypes.NewModuleAddress(minttypes.ModuleName).String():       true,
+	}
+}
+
+// genesisValidator returns the single validator of the simulation app
+func genesisValidator(t *testing.T) abci.ValidatorUpdate {
+	pubKey, err := cryptocodec.ToTmProtoPublicKey(ed25519.GenPrivKeyFromSecret([]byte("validator")).PubKey())
+	require.NoError(t, err)
+	return abci.ValidatorUpdate{PubKey: pubKey, Power: 1}
+}
+
+// WeightedOperations returns the operations of the simulation manager with the default weights
+func (app *SimApp) WeightedOperations() []simtypes.WeightedOperation {
+	return app.sm.WeightedOperations(module.SimulationState{AppParams: make(simtypes.AppParams), Cdc: app.encCfg.Codec})
+}
+
+// BeginBlockAt begins a block at the given height and returns the deliver context
+func (app *SimApp) BeginBlockAt(height int64) sdk.Context {
+	header := tmproto.Header{ChainID: SimChainID, Height: height, Time: time.Unix(height*5, 0)}
+	app.BeginBlock(abci.RequestBeginBlock{Header: header})
+	return app.NewContext(false, header)
+}
+
+// EndBlockAt ends the block at the given height, commits it and returns the app hash
+func (app *SimApp) EndBlockAt(height int64) []byte {
+	app.EndBlock(abci.RequestEndBlock{Height: height})
+	return app.Commit().Data
+}
@@ -0,0 +1,32 @@
+package simulation
+
+import (
+	"bytes"
+	"fmt"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/kv"
+
+	"ibc-fee/antehandler"
+)
+
+// NewDecodeStore returns a decoder function that prints the feeHandler values of two KVPairs
+// Most values are JSON encoded, so they are printed as they are stored
+func NewDecodeStore() func(kvA, kvB kv.Pair) string {
+	return func(kvA, kvB kv.Pair) string {
+		switch {
+		case bytes.Equal(kvA.Key, antehandler.ParamsKey),
+			bytes.HasPrefix(kvA.Key, antehandler.ScheduledParamsPrefix),
+			bytes.HasPrefix(kvA.Key, antehandler.ReceiptsPrefix),
+			bytes.HasPrefix(kvA.Key, antehandler.RelayersPrefix):
+			return fmt.Sprintf("%s\n%s", kvA.Value, kvB.Value)
+		case bytes.HasPrefix(kvA.Key, antehandler.SurgeBytesPrefix):
+			return fmt.Sprintf("%d\n%d", sdk.BigEndianToUint64(kvA.Value), sdk.BigEndianToUint64(kvB.Value))
+		case bytes.HasPrefix(kvA.Key, antehandler.ReceiptsByHeightPrefix):
+			// The height index has no values, only the keys matter
+			return fmt.Sprintf("%X\n%X", kvA.Key, kvB.Key)
+		default:
+			panic(fmt.Sprintf("invalid %s key prefix %X", antehandler.FeeHandlerModuleName, kvA.Key[:1]))
+		}
+	}
+}
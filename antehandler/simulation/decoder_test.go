@@ -0,0 +1,59 @@
+package simulation_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/kv"
+
+	ante "ibc-fee/antehandler"
+	"ibc-fee/antehandler/simulation"
+)
+
+// TestDecodeStore tests that every feeHandler store prefix is decoded
+func TestDecodeStore(t *testing.T) {
+	dec := simulation.NewDecodeStore()
+
+	testCases := []struct {
+		name     string
+		kvA, kvB kv.Pair
+		expected string
+	}{
+		{
+			name:     "params",
+			kvA:      kv.Pair{Key: ante.ParamsKey, Value: []byte(`{"A":1}`)},
+			kvB:      kv.Pair{Key: ante.ParamsKey, Value: []byte(`{"A":2}`)},
+			expected: "{\"A\":1}\n{\"A\":2}",
+		},
+		{
+			name:     "relayer",
+			kvA:      kv.Pair{Key: append(ante.RelayersPrefix, 0x01), Value: []byte(`{"B":1}`)},
+			kvB:      kv.Pair{Key: append(ante.RelayersPrefix, 0x01), Value: []byte(`{"B":2}`)},
+			expected: "{\"B\":1}\n{\"B\":2}",
+		},
+		{
+			name:     "surge bytes",
+			kvA:      kv.Pair{Key: append(ante.SurgeBytesPrefix, 0x01), Value: sdk.Uint64ToBigEndian(10)},
+			kvB:      kv.Pair{Key: append(ante.SurgeBytesPrefix, 0x01), Value: sdk.Uint64ToBigEndian(20)},
+			expected: "10\n20",
+		},
+		{
+			name:     "receipts height index",
+			kvA:      kv.Pair{Key: append(ante.ReceiptsByHeightPrefix, 0x0A), Value: []byte{}},
+			kvB:      kv.Pair{Key: append(ante.ReceiptsByHeightPrefix, 0x0B), Value: []byte{}},
+			expected: fmt.Sprintf("%X\n%X", append(ante.ReceiptsByHeightPrefix, 0x0A), append(ante.ReceiptsByHeightPrefix, 0x0B)),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, dec(tc.kvA, tc.kvB))
+		})
+	}
+
+	// Unknown prefixes panic
+	require.Panics(t, func() { dec(kv.Pair{Key: []byte{0xFF}}, kv.Pair{Key: []byte{0xFF}}) })
+}
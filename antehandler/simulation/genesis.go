@@ -0,0 +1,78 @@
+package simulation
+
+import (
+	"encoding/json"
+	"math/rand"
+
+	"github.com/cosmos/cosmos-sdk/types/module"
+	simtypes "github.com/cosmos/cosmos-sdk/types/simulation"
+
+	"ibc-fee/antehandler"
+)
+
+// Simulation genesis keys, they can be used to override the generated values with the simulation params file
+const (
+	Params                 = "fee_handler_params"
+	Relayers               = "fee_handler_relayers"
+	ScheduledParamsUpdates = "fee_handler_scheduled_params_updates"
+)
+
+// RandomizedGenState generates a random genesis state for the simulated feeHandler module
+func RandomizedGenState(simState *module.SimulationState) {
+	var params antehandler.FeeHandlerParams
+	simState.AppParams.GetOrGenerate(
+		simState.Cdc, Params, &params, simState.Rand,
+		func(r *rand.Rand) { params = RandomizedParams(r, simState.BondDenom) },
+	)
+
+	var relayers []antehandler.Relayer
+	simState.AppParams.GetOrGenerate(
+		simState.Cdc, Relayers, &relayers, simState.Rand,
+		func(r *rand.Rand) { relayers = RandomGenesisRelayers(r, simState) },
+	)
+
+	var updates []antehandler.ScheduledParamsUpdate
+	simState.AppParams.GetOrGenerate(
+		simState.Cdc, ScheduledParamsUpdates, &updates, simState.Rand,
+		func(r *rand.Rand) { updates = RandomGenesisScheduledParamsUpdates(r, simState.BondDenom) },
+	)
+
+	genesis := antehandler.FeeHandlerGenesisState{
+		Params:                 params,
+		Relayers:               relayers,
+		ScheduledParamsUpdates: updates,
+	}
+
+	bz, err := json.Marshal(genesis)
+	if err != nil {
+		panic(err)
+	}
+	simState.GenState[antehandler.FeeHandlerModuleName] = bz
+}
+
+// RandomGenesisRelayers registers a random tenth of the simulation accounts as relayers
+func RandomGenesisRelayers(r *rand.Rand, simState *module.SimulationState) []antehandler.Relayer {
+	relayers := []antehandler.Relayer{}
+	for _, acc := range simState.Accounts {
+		if r.Intn(10) != 0 {
+			continue
+		}
+		relayers = append(relayers, antehandler.Relayer{Address: acc.Address.String(), Channels: []string{}})
+	}
+	return relayers
+}
+
+// RandomGenesisScheduledParamsUpdates returns up to two random params updates on the first blocks
+func RandomGenesisScheduledParamsUpdates(r *rand.Rand, denom string) []antehandler.ScheduledParamsUpdate {
+	updates := []antehandler.ScheduledParamsUpdate{}
+	activationHeight := int64(1)
+	for i := r.Intn(3); i > 0; i-- {
+		// The heights are increasing, so they are never duplicated
+		activationHeight += int64(simtypes.RandIntBetween(r, 1, 10))
+		updates = append(updates, antehandler.ScheduledParamsUpdate{
+			ActivationHeight: activationHeight,
+			Params:           RandomizedParams(r, denom),
+		})
+	}
+	return updates
+}
@@ -0,0 +1,78 @@
+package simulation_test
+
+import (
+	"encoding/json"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/module"
+	moduletestutil "github.com/cosmos/cosmos-sdk/types/module/testutil"
+	simtypes "github.com/cosmos/cosmos-sdk/types/simulation"
+
+	ante "ibc-fee/antehandler"
+	"ibc-fee/antehandler/simulation"
+)
+
+// TestRandomizedGenState tests that the random genesis states are valid
+// The validation also covers the scheduled params updates, their heights must be positive and unique
+func TestRandomizedGenState(t *testing.T) {
+	for seed := int64(0); seed < 50; seed++ {
+		r := rand.New(rand.NewSource(seed))
+		simState := &module.SimulationState{
+			AppParams: make(simtypes.AppParams),
+			Cdc:       moduletestutil.MakeTestEncodingConfig().Codec,
+			Rand:      r,
+			GenState:  make(map[string]json.RawMessage),
+			Accounts:  simtypes.RandomAccounts(r, 20),
+			BondDenom: sdk.DefaultBondDenom,
+		}
+
+		simulation.RandomizedGenState(simState)
+
+		genesis, err := ante.UnmarshalFeeHandlerGenesisState(simState.GenState[ante.FeeHandlerModuleName])
+		require.NoError(t, err)
+		require.Equal(t, sdk.DefaultBondDenom, genesis.Params.FeeBytePrice[0].Denom)
+	}
+}
+
+// TestRandomizedGenStateOverride tests that the simulation params file overrides the generated genesis
+func TestRandomizedGenStateOverride(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	params := ante.DefaultFeeHandlerParams()
+	params.MinTxSize = 1234
+
+	paramsBz, err := json.Marshal(params)
+	require.NoError(t, err)
+
+	simState := &module.SimulationState{
+		AppParams: simtypes.AppParams{
+			simulation.Params:                 paramsBz,
+			simulation.Relayers:               json.RawMessage("[]"),
+			simulation.ScheduledParamsUpdates: json.RawMessage("[]"),
+		},
+		Cdc:       moduletestutil.MakeTestEncodingConfig().Codec,
+		Rand:      r,
+		GenState:  make(map[string]json.RawMessage),
+		Accounts:  simtypes.RandomAccounts(r, 5),
+		BondDenom: sdk.DefaultBondDenom,
+	}
+
+	simulation.RandomizedGenState(simState)
+
+	genesis, err := ante.UnmarshalFeeHandlerGenesisState(simState.GenState[ante.FeeHandlerModuleName])
+	require.NoError(t, err)
+	require.Equal(t, uint64(1234), genesis.Params.MinTxSize)
+	require.Empty(t, genesis.Relayers)
+	require.Empty(t, genesis.ScheduledParamsUpdates)
+}
+
+// TestRandomizedParams tests that the random params are always valid
+func TestRandomizedParams(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		require.NoError(t, simulation.RandomizedParams(r, sdk.DefaultBondDenom).Validate())
+	}
+}
@@ -0,0 +1,47 @@
+package simulation
+
+import (
+	"github.com/cosmos/cosmos-sdk/client"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/module"
+	simtypes "github.com/cosmos/cosmos-sdk/types/simulation"
+
+	"ibc-fee/antehandler"
+)
+
+// Assert that the AppModuleSimulation interface is really being implemented
+var _ module.AppModuleSimulation = AppModule{}
+
+// AppModule wires the feeHandler simulation on the simulation manager of a app
+// The feeHandler has no AppModule of its own, so this adapter only implements the simulation interface
+type AppModule struct {
+	txConfig client.TxConfig
+	ak       AccountKeeper
+	bk       BankKeeper
+	keeper   antehandler.FeeHandlerKeeper
+}
+
+// NewAppModule returns a new feeHandler simulation module
+func NewAppModule(txConfig client.TxConfig, ak AccountKeeper, bk BankKeeper, keeper antehandler.FeeHandlerKeeper) AppModule {
+	return AppModule{
+		txConfig: txConfig,
+		ak:       ak,
+		bk:       bk,
+		keeper:   keeper,
+	}
+}
+
+// GenerateGenesisState generates a random genesis state for the feeHandler
+func (AppModule) GenerateGenesisState(simState *module.SimulationState) {
+	RandomizedGenState(simState)
+}
+
+// RegisterStoreDecoder registers the decoder of the feeHandler store values
+func (AppModule) RegisterStoreDecoder(sdr sdk.StoreDecoderRegistry) {
+	sdr[antehandler.FeeHandlerStoreKey] = NewDecodeStore()
+}
+
+// WeightedOperations returns the feeHandler operations with their weights
+func (am AppModule) WeightedOperations(simState module.SimulationState) []simtypes.WeightedOperation {
+	return WeightedOperations(simState.AppParams, simState.Cdc, am.txConfig, am.ak, am.bk, am.keeper)
+}
@@ -0,0 +1,19 @@
+package simulation_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	ante "ibc-fee/antehandler"
+	"ibc-fee/antehandler/simulation"
+)
+
+// TestAppModule tests that the simulation module is wired on the simulation manager
+func TestAppModule(t *testing.T) {
+	app := NewSimApp(t)
+
+	require.Contains(t, app.sm.StoreDecoders, ante.FeeHandlerStoreKey)
+	require.Len(t, app.WeightedOperations(), 4)
+	require.Equal(t, simulation.DefaultWeightRandomSizeTx, app.WeightedOperations()[0].Weight())
+}
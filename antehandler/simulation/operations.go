@@ -0,0 +1,280 @@
+package simulation
+
+import (
+	"errors"
+	"math/rand"
+
+	"github.com/cosmos/cosmos-sdk/baseapp"
+	"github.com/cosmos/cosmos-sdk/client"
+	"github.com/cosmos/cosmos-sdk/codec"
+	cryptotypes "github.com/cosmos/cosmos-sdk/crypto/types"
+	simtestutil "github.com/cosmos/cosmos-sdk/testutil/sims"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	simtypes "github.com/cosmos/cosmos-sdk/types/simulation"
+	"github.com/cosmos/cosmos-sdk/types/tx/signing"
+	authsigning "github.com/cosmos/cosmos-sdk/x/auth/signing"
+	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	"github.com/cosmos/cosmos-sdk/x/simulation"
+
+	"ibc-fee/antehandler"
+)
+
+// Simulation operation weights constants
+const (
+	OpWeightRandomSizeTx         = "op_weight_fee_handler_random_size_tx"         //nolint:gosec
+	OpWeightMultiMsgTx           = "op_weight_fee_handler_multi_msg_tx"           //nolint:gosec
+	OpWeightMemoHeavyTx          = "op_weight_fee_handler_memo_heavy_tx"          //nolint:gosec
+	OpWeightScheduleParamsUpdate = "op_weight_fee_handler_schedule_params_update" //nolint:gosec
+
+	DefaultWeightRandomSizeTx         = 50
+	DefaultWeightMultiMsgTx           = 30
+	DefaultWeightMemoHeavyTx          = 20
+	DefaultWeightScheduleParamsUpdate = 5
+
+	// MaxMsgsPerTx is the max amount of msgs on the multi msg TXs
+	MaxMsgsPerTx = 10
+
+	// Names of the simulated operations
+	TypeRandomSizeTx         = "random_size_tx"
+	TypeMultiMsgTx           = "multi_msg_tx"
+	TypeMemoHeavyTx          = "memo_heavy_tx"
+	TypeScheduleParamsUpdate = "schedule_params_update"
+)
+
+// AccountKeeper defines the account keeper used by the simulation operations
+type AccountKeeper interface {
+	GetAccount(ctx sdk.Context, addr sdk.AccAddress) authtypes.AccountI
+	GetParams(ctx sdk.Context) authtypes.Params
+}
+
+// BankKeeper defines the bank keeper used by the simulation operations
+type BankKeeper interface {
+	SpendableCoins(ctx sdk.Context, addr sdk.AccAddress) sdk.Coins
+}
+
+// WeightedOperations returns all the operations of the feeHandler simulation with their respective weights
+// The TXs are plain bank sends, the goal is to run the weighted fee antehandler over random TX sizes
+func WeightedOperations(
+	appParams simtypes.AppParams,
+	cdc codec.JSONCodec,
+	txConfig client.TxConfig,
+	ak AccountKeeper,
+	bk BankKeeper,
+	k antehandler.FeeHandlerKeeper,
+) simulation.WeightedOperations {
+	var weightRandomSizeTx, weightMultiMsgTx, weightMemoHeavyTx, weightScheduleParamsUpdate int
+	appParams.GetOrGenerate(cdc, OpWeightRandomSizeTx, &weightRandomSizeTx, nil,
+		func(_ *rand.Rand) { weightRandomSizeTx = DefaultWeightRandomSizeTx },
+	)
+	appParams.GetOrGenerate(cdc, OpWeightMultiMsgTx, &weightMultiMsgTx, nil,
+		func(_ *rand.Rand) { weightMultiMsgTx = DefaultWeightMultiMsgTx },
+	)
+	appParams.GetOrGenerate(cdc, OpWeightMemoHeavyTx, &weightMemoHeavyTx, nil,
+		func(_ *rand.Rand) { weightMemoHeavyTx = DefaultWeightMemoHeavyTx },
+	)
+	appParams.GetOrGenerate(cdc, OpWeightScheduleParamsUpdate, &weightScheduleParamsUpdate, nil,
+		func(_ *rand.Rand) { weightScheduleParamsUpdate = DefaultWeightScheduleParamsUpdate },
+	)
+
+	return simulation.WeightedOperations{
+		simulation.NewWeightedOperation(weightRandomSizeTx, SimulateRandomSizeTx(txConfig, ak, bk)),
+		simulation.NewWeightedOperation(weightMultiMsgTx, SimulateMultiMsgTx(txConfig, ak, bk)),
+		simulation.NewWeightedOperation(weightMemoHeavyTx, SimulateMemoHeavyTx(txConfig, ak, bk)),
+		simulation.NewWeightedOperation(weightScheduleParamsUpdate, SimulateScheduleParamsUpdate(k)),
+	}
+}
+
+// SimulateRandomSizeTx sends a single msg TX with a random memo, so the TX size varies around the threshold
+func SimulateRandomSizeTx(txConfig client.TxConfig, ak AccountKeeper, bk BankKeeper) simtypes.Operation {
+	return func(
+		r *rand.Rand, app *baseapp.BaseApp, ctx sdk.Context, accs []simtypes.Account, chainID string,
+	) (simtypes.OperationMsg, []simtypes.FutureOperation, error) {
+		memoSize := r.Intn(int(ak.GetParams(ctx).MaxMemoCharacters) + 1)
+		return deliverSendTx(r, app, ctx, accs, chainID, txConfig, ak, bk, TypeRandomSizeTx, 1, memoSize)
+	}
+}
+
+// SimulateMultiMsgTx sends a TX with multiple msgs, so the TX is usually above the threshold
+func SimulateMultiMsgTx(txConfig client.TxConfig, ak AccountKeeper, bk BankKeeper) simtypes.Operation {
+	return func(
+		r *rand.Rand, app *baseapp.BaseApp, ctx sdk.Context, accs []simtypes.Account, chainID string,
+	) (simtypes.OperationMsg, []simtypes.FutureOperation, error) {
+		totalMsgs := simtypes.RandIntBetween(r, 2, MaxMsgsPerTx+1)
+		return deliverSendTx(r, app, ctx, accs, chainID, txConfig, ak, bk, TypeMultiMsgTx, totalMsgs, 0)
+	}
+}
+
+// SimulateMemoHeavyTx sends a single msg TX with a memo close to the max memo size
+func SimulateMemoHeavyTx(txConfig client.TxConfig, ak AccountKeeper, bk BankKeeper) simtypes.Operation {
+	return func(
+		r *rand.Rand, app *baseapp.BaseApp, ctx sdk.Context, accs []simtypes.Account, chainID string,
+	) (simtypes.OperationMsg, []simtypes.FutureOperation, error) {
+		maxMemo := int(ak.GetParams(ctx).MaxMemoCharacters)
+		memoSize := simtypes.RandIntBetween(r, maxMemo/2, maxMemo+1)
+		return deliverSendTx(r, app, ctx, accs, chainID, txConfig, ak, bk, TypeMemoHeavyTx, 1, memoSize)
+	}
+}
+
+// SimulateScheduleParamsUpdate schedules random params at a near height, as governance would
+// The feeHandler has no protobuf msgs to route through a TX, so the update is written on the block context and not fuzzed through the antehandler
+// This is how a passed proposal is executed, the gov EndBlocker calls the keeper outside of any user TX
+func SimulateScheduleParamsUpdate(k antehandler.FeeHandlerKeeper) simtypes.Operation {
+	return func(
+		r *rand.Rand, _ *baseapp.BaseApp, ctx sdk.Context, _ []simtypes.Account, _ string,
+	) (simtypes.OperationMsg, []simtypes.FutureOperation, error) {
+		// The byte prices keep the denom of the current params
+		denom := sdk.DefaultBondDenom
+		if price := k.GetParams(ctx).FeeBytePrice; !price.IsZero() {
+			denom = price[0].Denom
+		}
+
+		update := antehandler.ScheduledParamsUpdate{
+			ActivationHeight: ctx.BlockHeight() + int64(simtypes.RandIntBetween(r, 1, 10)),
+			Params:           RandomizedParams(r, denom),
+		}
+		if err := k.ScheduleParamsUpdate(ctx, k.GetAuthority(), update); err != nil {
+			// Only a single update can be scheduled per height
+			if errors.Is(err, antehandler.ErrParamsUpdateExists) {
+				return simtypes.NoOpMsg(antehandler.FeeHandlerModuleName, TypeScheduleParamsUpdate, err.Error()), nil, nil
+			}
+			return simtypes.NoOpMsg(antehandler.FeeHandlerModuleName, TypeScheduleParamsUpdate, "invalid params update"), nil, err
+		}
+
+		return simtypes.NewOperationMsgBasic(antehandler.FeeHandlerModuleName, TypeScheduleParamsUpdate, "", true, nil), nil, nil
+	}
+}
+
+// deliverSendTx delivers a TX with bank sends from a random account to another
+// The TXs rejected by the byte fee rules are expected, so they are reported as no operation
+func deliverSendTx(
+	r *rand.Rand,
+	app *baseapp.BaseApp,
+	ctx sdk.Context,
+	accs []simtypes.Account,
+	chainID string,
+	txConfig client.TxConfig,
+	ak AccountKeeper,
+	bk BankKeeper,
+	opType string,
+	totalMsgs int,
+	memoSize int,
+) (simtypes.OperationMsg, []simtypes.FutureOperation, error) {
+	from, _ := simtypes.RandomAcc(r, accs)
+	to, _ := simtypes.RandomAcc(r, accs)
+
+	account := ak.GetAccount(ctx, from.Address)
+	if account == nil {
+		return simtypes.NoOpMsg(antehandler.FeeHandlerModuleName, opType, "account not found"), nil, nil
+	}
+
+	// Each msg sends a single coin, the rest of the balance pays the regular and the byte fees
+	spendable := bk.SpendableCoins(ctx, from.Address)
+	if spendable.Empty() {
+		return simtypes.NoOpMsg(antehandler.FeeHandlerModuleName, opType, "no spendable coins"), nil, nil
+	}
+	coin := sdk.NewCoin(spendable[r.Intn(len(spendable))].Denom, sdk.OneInt())
+	sent := sdk.NewCoins(sdk.NewCoin(coin.Denom, sdk.NewInt(int64(totalMsgs))))
+	remaining, hasNeg := spendable.SafeSub(sent...)
+	if hasNeg {
+		return simtypes.NoOpMsg(antehandler.FeeHandlerModuleName, opType, "not enough coins to send"), nil, nil
+	}
+
+	msgs := make([]sdk.Msg, totalMsgs)
+	for i := range msgs {
+		msgs[i] = banktypes.NewMsgSend(from.Address, to.Address, sdk.NewCoins(coin))
+	}
+
+	// Only a tenth of the remaining coins can be used as regular fee, so the byte fees can usually be paid
+	fees, err := simtypes.RandomFees(r, ctx, tenth(remaining))
+	if err != nil {
+		return simtypes.NoOpMsg(antehandler.FeeHandlerModuleName, opType, "unable to generate fees"), nil, err
+	}
+
+	memo := simtypes.RandStringOfLength(r, memoSize)
+	tx, err := genSignedTx(txConfig, msgs, fees, memo, chainID, account, from.PrivKey)
+	if err != nil {
+		return simtypes.NoOpMsg(antehandler.FeeHandlerModuleName, opType, "unable to generate tx"), nil, err
+	}
+
+	if _, _, err := app.SimDeliver(txConfig.TxEncoder(), tx); err != nil {
+		if isExpectedRejection(err) {
+			return simtypes.NoOpMsg(antehandler.FeeHandlerModuleName, opType, err.Error()), nil, nil
+		}
+		return simtypes.NoOpMsg(antehandler.FeeHandlerModuleName, opType, "unable to deliver tx"), nil, err
+	}
+
+	return simtypes.NewOperationMsgBasic(antehandler.FeeHandlerModuleName, opType, "", true, nil), nil, nil
+}
+
+// isExpectedRejection returns true if the TX was rejected by the byte fee rules or the fee payer funds
+func isExpectedRejection(err error) bool {
+	return errors.Is(err, antehandler.ErrInsufficientByteFee) ||
+		errors.Is(err, sdkerrors.ErrInsufficientFunds) ||
+		errors.Is(err, sdkerrors.ErrInsufficientFee)
+}
+
+// tenth returns a tenth of each coin, dropping the coins that round to zero
+func tenth(coins sdk.Coins) sdk.Coins {
+	result := sdk.NewCoins()
+	for _, coin := range coins {
+		result = result.Add(sdk.NewCoin(coin.Denom, coin.Amount.QuoRaw(10)))
+	}
+	return result
+}
+
+// genSignedTx generates a TX signed by a single account with the given memo
+// It mirrors simtestutil.GenSignedMockTx, which always sets a random short memo
+func genSignedTx(
+	txConfig client.TxConfig,
+	msgs []sdk.Msg,
+	fees sdk.Coins,
+	memo string,
+	chainID string,
+	account authtypes.AccountI,
+	privKey cryptotypes.PrivKey,
+) (sdk.Tx, error) {
+	signMode := txConfig.SignModeHandler().DefaultMode()
+
+	txBuilder := txConfig.NewTxBuilder()
+	if err := txBuilder.SetMsgs(msgs...); err != nil {
+		return nil, err
+	}
+	txBuilder.SetMemo(memo)
+	txBuilder.SetFeeAmount(fees)
+	txBuilder.SetGasLimit(simtestutil.DefaultGenTxGas)
+
+	// First round, set the signer info with a empty signature
+	sig := signing.SignatureV2{
+		PubKey:   privKey.PubKey(),
+		Data:     &signing.SingleSignatureData{SignMode: signMode},
+		Sequence: account.GetSequence(),
+	}
+	if err := txBuilder.SetSignatures(sig); err != nil {
+		return nil, err
+	}
+
+	// Second round, sign the TX bytes
+	signerData := authsigning.SignerData{
+		Address:       account.GetAddress().String(),
+		ChainID:       chainID,
+		AccountNumber: account.GetAccountNumber(),
+		Sequence:      account.GetSequence(),
+		PubKey:        privKey.PubKey(),
+	}
+	signBytes, err := txConfig.SignModeHandler().GetSignBytes(signMode, signerData, txBuilder.GetTx())
+	if err != nil {
+		return nil, err
+	}
+	signature, err := privKey.Sign(signBytes)
+	if err != nil {
+		return nil, err
+	}
+	sig.Data = &signing.SingleSignatureData{SignMode: signMode, Signature: signature}
+	if err := txBuilder.SetSignatures(sig); err != nil {
+		return nil, err
+	}
+
+	return txBuilder.GetTx(), nil
+}
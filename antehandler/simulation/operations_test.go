@@ -0,0 +1,115 @@
+package simulation_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	simtypes "github.com/cosmos/cosmos-sdk/types/simulation"
+
+	"ibc-fee/antehandler/simulation"
+)
+
+// TestWeightedOperations tests that all the operations are registered with the default weights
+func TestWeightedOperations(t *testing.T) {
+	app := NewSimApp(t)
+	ops := simulation.WeightedOperations(
+		make(simtypes.AppParams), app.encCfg.Codec, app.encCfg.TxConfig,
+		app.accountKeeper, app.bankKeeper, app.feeHandlerKeeper,
+	)
+
+	expectedWeights := []int{
+		simulation.DefaultWeightRandomSizeTx,
+		simulation.DefaultWeightMultiMsgTx,
+		simulation.DefaultWeightMemoHeavyTx,
+		simulation.DefaultWeightScheduleParamsUpdate,
+	}
+	require.Len(t, ops, len(expectedWeights))
+	for i, op := range ops {
+		require.Equal(t, expectedWeights[i], op.Weight())
+	}
+}
+
+// TestSimulateTxs tests that every TX operation is delivered and charged on a fresh chain
+func TestSimulateTxs(t *testing.T) {
+	testCases := []struct {
+		name string
+		op   func(app *SimApp) simtypes.Operation
+	}{
+		{
+			name: "random size TX",
+			op: func(app *SimApp) simtypes.Operation {
+				return simulation.SimulateRandomSizeTx(app.encCfg.TxConfig, app.accountKeeper, app.bankKeeper)
+			},
+		},
+		{
+			name: "multi msg TX",
+			op: func(app *SimApp) simtypes.Operation {
+				return simulation.SimulateMultiMsgTx(app.encCfg.TxConfig, app.accountKeeper, app.bankKeeper)
+			},
+		},
+		{
+			name: "memo heavy TX",
+			op: func(app *SimApp) simtypes.Operation {
+				return simulation.SimulateMemoHeavyTx(app.encCfg.TxConfig, app.accountKeeper, app.bankKeeper)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := rand.New(rand.NewSource(1))
+			accs := simtypes.RandomAccounts(r, 3)
+
+			app := NewSimApp(t)
+			app.InitChain(t, r, accs)
+			app.BeginBlockAt(1)
+			app.EndBlockAt(1)
+
+			// Enable the byte fees with a threshold bellow the size of a bank send, so every TX is charged
+			ctx := app.BeginBlockAt(2)
+			params := app.feeHandlerKeeper.GetParams(ctx)
+			params.Enabled = true
+			params.ActivationHeight = 0
+			params.MinTxSize = 100
+			params.FeeBytePrice = sdk.NewDecCoins(sdk.NewDecCoin(sdk.DefaultBondDenom, sdk.OneInt()))
+			require.NoError(t, app.feeHandlerKeeper.SetParams(ctx, params))
+
+			opMsg, futureOps, err := tc.op(app)(r, app.BaseApp, ctx, accs, SimChainID)
+			require.NoError(t, err)
+			require.True(t, opMsg.OK, opMsg.Comment)
+			require.Empty(t, futureOps)
+
+			// The TX is always above the threshold, so some bytes fee must have been charged
+			require.False(t, app.feeHandlerKeeper.GetBlockBytesFees(ctx).IsZero())
+		})
+	}
+}
+
+// TestSimulateScheduleParamsUpdate tests that the scheduled params are valid and applied at the activation height
+func TestSimulateScheduleParamsUpdate(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	accs := simtypes.RandomAccounts(r, 3)
+
+	app := NewSimApp(t)
+	app.InitChain(t, r, accs)
+	app.BeginBlockAt(1)
+	app.EndBlockAt(1)
+
+	// The random genesis can already have scheduled updates
+	ctx := app.BeginBlockAt(2)
+	genesisUpdates := app.feeHandlerKeeper.GetScheduledParamsUpdates(ctx)
+
+	op := simulation.SimulateScheduleParamsUpdate(app.feeHandlerKeeper)
+	opMsg, _, err := op(r, app.BaseApp, ctx, accs, SimChainID)
+	require.NoError(t, err)
+	require.True(t, opMsg.OK)
+
+	updates := app.feeHandlerKeeper.GetScheduledParamsUpdates(ctx)
+	require.Len(t, updates, len(genesisUpdates)+1)
+	for _, update := range updates {
+		require.NoError(t, update.Params.Validate())
+	}
+}
@@ -0,0 +1,42 @@
+package simulation
+
+import (
+	"math/rand"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+
+	"ibc-fee/antehandler"
+)
+
+// RandomizedParams returns random valid params of the simulated feeHandler module
+// The byte prices are quoted in the given denom, usually the simulation bond denom
+func RandomizedParams(r *rand.Rand, denom string) antehandler.FeeHandlerParams {
+	params := antehandler.DefaultFeeHandlerParams()
+
+	// The byte fees are enabled most of the time, so the charging path is exercised
+	params.Enabled = r.Intn(100) < 90
+	params.ActivationHeight = int64(r.Intn(5))
+
+	params.FeeBytePrice = sdk.NewDecCoins(sdk.NewDecCoinFromDec(denom, sdk.NewDecWithPrec(int64(r.Intn(100)+1), 2)))
+	params.MinTxSize = uint64(r.Intn(400) + 100)
+
+	// Half of the time the memo has its own price
+	if r.Intn(2) == 0 {
+		params.MemoBytePrice = sdk.NewDecCoins(sdk.NewDecCoinFromDec(denom, sdk.NewDecWithPrec(int64(r.Intn(200)+1), 2)))
+		params.FreeMemoBytes = uint64(r.Intn(64))
+	}
+
+	// Half of the time the surge pricing is enabled
+	if r.Intn(2) == 0 {
+		params.SurgeWindowBlocks = uint64(r.Intn(20) + 1)
+		params.SurgeByteBudget = uint64(r.Intn(10_000) + 1_000)
+		params.SurgeMultiplierStep = sdk.NewDecWithPrec(int64(r.Intn(100)+1), 2)
+		params.MaxSurgeMultiplier = sdk.OneDec().Add(sdk.NewDec(int64(r.Intn(5))))
+	}
+
+	params.PacketDataPricing = r.Intn(2) == 0
+	params.ReceiptRetentionBlocks = uint64(r.Intn(100))
+	params.RelayerByteFeeDiscount = sdk.NewDecWithPrec(int64(r.Intn(101)), 2)
+
+	return params
+}
@@ -0,0 +1,96 @@
+package simulation_test
+
+import (
+	"encoding/json"
+	"io"
+	"testing"
+
+	abci "github.com/cometbft/cometbft/abci/types"
+	tmproto "github.com/cometbft/cometbft/proto/tendermint/types"
+	"github.com/stretchr/testify/require"
+
+	simtestutil "github.com/cosmos/cosmos-sdk/testutil/sims"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	simtypes "github.com/cosmos/cosmos-sdk/types/simulation"
+	"github.com/cosmos/cosmos-sdk/x/simulation"
+
+	ante "ibc-fee/antehandler"
+)
+
+// newSimulationConfig returns the config of a short simulation that commits every block
+func newSimulationConfig(seed int64) simtypes.Config {
+	return simtypes.Config{
+		Seed:               seed,
+		InitialBlockHeight: 1,
+		NumBlocks:          10,
+		BlockSize:          20,
+		ChainID:            SimChainID,
+		Commit:             true,
+	}
+}
+
+// runSimulation runs the Cosmos-SDK simulation over the app with the feeHandler operations
+func runSimulation(t *testing.T, app *SimApp, config simtypes.Config) {
+	stopEarly, _, err := simulation.SimulateFromSeed(
+		t,
+		io.Discard,
+		app.BaseApp,
+		app.AppStateFn(t),
+		simtypes.RandomAccounts,
+		app.WeightedOperations(),
+		app.ModuleAccountAddrs(),
+		config,
+		app.encCfg.Codec,
+	)
+	require.NoError(t, err)
+	require.False(t, stopEarly)
+}
+
+// TestAppStateDeterminism runs the same simulation a few times and checks that the final app hash is the same
+func TestAppStateDeterminism(t *testing.T) {
+	const (
+		totalSeeds = 2
+		totalRuns  = 2
+	)
+
+	for seed := int64(1); seed <= totalSeeds; seed++ {
+		appHashes := make([][]byte, totalRuns)
+		for i := range appHashes {
+			app := NewSimApp(t)
+			runSimulation(t, app, newSimulationConfig(seed))
+			appHashes[i] = app.LastCommitID().Hash
+		}
+
+		for i := 1; i < totalRuns; i++ {
+			require.Equal(t, appHashes[0], appHashes[i], "non determinism on seed %d", seed)
+		}
+	}
+}
+
+// TestAppImportExport exports the feeHandler genesis after a simulation and imports it on a new app
+// The stores must match, except for the surge windows and the receipts that are not exported
+func TestAppImportExport(t *testing.T) {
+	app := NewSimApp(t)
+	runSimulation(t, app, newSimulationConfig(7))
+
+	ctx := app.NewContext(true, tmproto.Header{Height: app.LastBlockHeight()})
+	exported := app.feeHandlerKeeper.ExportGenesis(ctx)
+	genesisBz, err := json.Marshal(exported)
+	require.NoError(t, err)
+	appState, err := json.Marshal(map[string]json.RawMessage{ante.FeeHandlerModuleName: genesisBz})
+	require.NoError(t, err)
+
+	newApp := NewSimApp(t)
+	newApp.BaseApp.InitChain(abci.RequestInitChain{ChainId: SimChainID, AppStateBytes: appState})
+	newApp.Commit()
+	newCtx := newApp.NewContext(true, tmproto.Header{Height: newApp.LastBlockHeight()})
+	newGenesisBz, err := json.Marshal(newApp.feeHandlerKeeper.ExportGenesis(newCtx))
+	require.NoError(t, err)
+	require.JSONEq(t, string(genesisBz), string(newGenesisBz))
+
+	skippedPrefixes := [][]byte{ante.SurgeBytesPrefix, ante.ReceiptsPrefix, ante.ReceiptsByHeightPrefix}
+	storeA := ctx.KVStore(app.feeHandlerKey)
+	storeB := newCtx.KVStore(newApp.feeHandlerKey)
+	kvAs, kvBs := sdk.DiffKVStores(storeA, storeB, skippedPrefixes)
+	require.Empty(t, kvAs, simtestutil.GetSimulationLog(ante.FeeHandlerStoreKey, app.sm.StoreDecoders, kvAs, kvBs))
+}
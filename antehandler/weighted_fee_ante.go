@@ -7,19 +7,25 @@
 package antehandler
 
 import (
+	"errors"
+	"strconv"
+
 	errorsmod "cosmossdk.io/errors"
+	"github.com/cometbft/cometbft/crypto/tmhash"
+	"github.com/cosmos/cosmos-sdk/codec"
 	sdk "github.com/cosmos/cosmos-sdk/types"
 	errortypes "github.com/cosmos/cosmos-sdk/types/errors"
 	authtx "github.com/cosmos/cosmos-sdk/x/auth/tx"
 	"github.com/cosmos/cosmos-sdk/x/auth/types"
 )
 
-// Error messages
 const (
-	ErrFeeTxDecode = "error parsing tx into FeeTx"
-
 	// Events to be emitted since we don't have a module behind
-	AttributeKeyBytesFee = "bytes_fee"
+	AttributeKeyBytesFee        = "bytes_fee"
+	AttributeKeySurgeMultiplier = "surge_multiplier"
+	AttributeKeyMemoFee         = "memo_fee"
+	AttributeKeyTxSize          = "tx_size"
+	AttributeKeyRelayerDiscount = "relayer_discount"
 )
 
 // Assert that the AnteDecorator function is really being implemented
@@ -27,24 +33,65 @@ var _ sdk.AnteDecorator = (*WeightedFeeDecorator)(nil)
 
 // WeightedFeeDecorator is the decorator responsible of charging extra fees based on a TX size
 type WeightedFeeDecorator struct {
+	// cdc is used to decode the msgs nested inside ICA txs, it can be nil
+	cdc        codec.BinaryCodec
 	bankKeeper BankKeeper
 	feeHandler FeeHandler
+	// packetDataUnmarshaler is used to find the original sender of IBC packets, it can be nil
+	packetDataUnmarshaler PacketDataUnmarshaler
+	// priceOracle is used to convert the byte prices into the accepted fee denoms, it can be nil
+	priceOracle PriceOracle
+}
+
+// WeightedFeeOption sets a optional dependency of the weighted fee decorator
+type WeightedFeeOption func(*WeightedFeeDecorator)
+
+// WithCodec sets the codec, without it the msgs inside ICA txs are not unwrapped
+func WithCodec(cdc codec.BinaryCodec) WeightedFeeOption {
+	return func(wfd *WeightedFeeDecorator) {
+		wfd.cdc = cdc
+	}
+}
+
+// WithPacketDataUnmarshaler sets the packet data unmarshaler, without it the packets original sender is never charged
+func WithPacketDataUnmarshaler(pdu PacketDataUnmarshaler) WeightedFeeOption {
+	return func(wfd *WeightedFeeDecorator) {
+		wfd.packetDataUnmarshaler = pdu
+	}
+}
+
+// WithPriceOracle sets the price oracle, without it the byte fees can only be paid with the native prices
+func WithPriceOracle(po PriceOracle) WeightedFeeOption {
+	return func(wfd *WeightedFeeDecorator) {
+		wfd.priceOracle = po
+	}
 }
 
 // NewWeightedFeeDecorator returns a new weighted fee decorator
-func NewWeightedFeeDecorator(bk BankKeeper, fh FeeHandler) WeightedFeeDecorator {
-	// Returns the object
-	return WeightedFeeDecorator{
+// The optional dependencies are set with the options
+func NewWeightedFeeDecorator(bk BankKeeper, fh FeeHandler, opts ...WeightedFeeOption) WeightedFeeDecorator {
+	wfd := WeightedFeeDecorator{
 		bankKeeper: bk,
 		feeHandler: fh,
 	}
+	for _, opt := range opts {
+		opt(&wfd)
+	}
+
+	// Returns the object
+	return wfd
 }
 
 // AnteHandle executes the effective antehandler function
 // It charges fees on top of normal fees based on the feeHandler module
 // Only extra bytes are charged from the user
 // Fees are calculated as:
-// Fee price * (tx bytes - Min Tx Size)
+// Fee price * surge multiplier * (tx bytes - Min Tx Size)
+// If the memo has its own price, the memo bytes are removed from the tx bytes and charged as:
+// Memo price * surge multiplier * (memo bytes - Free memo bytes)
+// If the packet data pricing is enabled, IBC packet msgs only count their packet data bytes
+// Nothing is charged while the byte fees are disabled or before the activation height
+// TXs bigger than the max TX size are rejected
 func (wfd WeightedFeeDecorator) AnteHandle(ctx sdk.Context, tx sdk.Tx, simulate bool, next sdk.AnteHandler) (sdk.Context, error) {
 	// Pass the call to the check and deduct fee
 	err := wfd.checkDeductFee(ctx, tx)
@@ -68,40 +115,144 @@ func (wfd WeightedFeeDecorator) checkDeductFee(ctx sdk.Context, tx sdk.Tx) error
 	// Get the feeHandler params
 	feeHandlerParams := wfd.feeHandler.GetParams(ctx)
 
-	// Check if our TX will pay extra fees
-	if len(txBytes) <= int(feeHandlerParams.MinTxSize) {
+	// While the byte fees are not active, the TX passes through and only its size is emitted
+	// This allows shipping the binary first and enabling the byte fees later through governance
+	if !feeHandlerParams.IsActive(ctx.BlockHeight()) {
+		ctx.EventManager().EmitEvent(
+			sdk.NewEvent(
+				sdk.EventTypeTx,
+				sdk.NewAttribute(AttributeKeyTxSize, strconv.Itoa(len(txBytes))),
+			),
+		)
 		return nil
 	}
 
+	// Unwrap the msgs nested inside wrappers, so the rules apply to the inner msgs
+	msgs, err := wfd.unwrapMsgs(tx.GetMsgs(), feeHandlerParams.MaxMsgNestingDepth)
+	if err != nil {
+		return err
+	}
+
+	// Exclude the bytes of IBC packet msgs that are not packet data, such as proofs
+	bodySize := len(txBytes)
+	var packetSender sdk.AccAddress
+	if feeHandlerParams.PacketDataPricing {
+		pricing := wfd.getPacketPricing(msgs)
+		bodySize -= pricing.excludedBytes
+		packetSender = pricing.sender
+	}
+
+	// Split the memo from the rest of the TX if the memo has its own price
+	memoSize := 0
+	if feeHandlerParams.IsMemoPricingEnabled() {
+		if memoTx, ok := tx.(sdk.TxWithMemo); ok {
+			memoSize = len(memoTx.GetMemo())
+			bodySize -= memoSize
+		}
+	}
+	extraMemoBytes := memoSize - int(feeHandlerParams.FreeMemoBytes)
+
 	// Parse the TX as a FeeTx
 	feeTx, ok := tx.(sdk.FeeTx)
 	if !ok {
-		return errorsmod.Wrap(
-			errortypes.ErrTxDecode, ErrFeeTxDecode,
+		return errorsmod.Wrapf(
+			ErrFeeTxDecode, "invalid tx type %T", tx,
 		)
 	}
 
 	// Get the fee payer from the TX
+	// If the packets have a original sender on this chain, the sender pays instead
+	// Otherwise, a registered relayer paying for IBC core msgs gets a discount
 	feePayer := feeTx.FeePayer()
+	relayerDiscount := sdk.ZeroDec()
+	if packetSender != nil {
+		feePayer = packetSender
+	} else {
+		relayerDiscount = wfd.getRelayerDiscount(ctx, feePayer, msgs, feeHandlerParams)
+	}
+
+	// Get the surge multiplier of the fee payer, based on the bytes sent before this TX
+	// Then register the TX bytes on the fee payer window for the next TXs
+	// TXs bellow the min size are tracked too, so a flood of small TXs also raises the multiplier
+	surgeMultiplier := wfd.feeHandler.GetSurgeMultiplier(ctx, feePayer)
+	wfd.feeHandler.TrackTxBytes(ctx, feePayer, uint64(bodySize+memoSize))
+
+	// Check if our TX will pay extra fees
+	if bodySize <= int(feeHandlerParams.MinTxSize) && extraMemoBytes <= 0 {
+		return nil
+	}
+
+	// Convert the prices if the user is paying with a accepted non native denom
+	bytePrice := feeHandlerParams.FeeBytePrice
+	memoBytePrice := feeHandlerParams.MemoBytePrice
+	feeDenom, err := wfd.getFeeDenom(feeTx, feeHandlerParams)
+	if err != nil {
+		return err
+	}
+	if feeDenom != "" {
+		bytePrice, err = wfd.convertPrice(ctx, bytePrice, feeDenom, feeHandlerParams)
+		if err != nil {
+			return err
+		}
+		memoBytePrice, err = wfd.convertPrice(ctx, memoBytePrice, feeDenom, feeHandlerParams)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Apply the surge multiplier of the fee payer
+	bytePrice = bytePrice.MulDec(surgeMultiplier)
+	memoBytePrice = memoBytePrice.MulDec(surgeMultiplier)
+
+	// Apply the relayer discount, a full discount exempts the relayer from the byte fees
+	if relayerDiscount.IsPositive() {
+		bytePrice = bytePrice.MulDec(sdk.OneDec().Sub(relayerDiscount))
+		memoBytePrice = memoBytePrice.MulDec(sdk.OneDec().Sub(relayerDiscount))
+	}
 
 	// Calculate the total fee, but only for the additional bytes
-	extraBytes := len(txBytes) - int(feeHandlerParams.MinTxSize)
-	totalFee := calculateFeeForBytes(int64(extraBytes), feeHandlerParams.FeeBytePrice)
+	// The memo is charged separately, only above the free memo bytes
+	extraBytes := max(bodySize-int(feeHandlerParams.MinTxSize), 0)
+	memoFee := calculateFeeForBytes(int64(max(extraMemoBytes, 0)), memoBytePrice)
+	totalFee := calculateFeeForBytes(int64(extraBytes), bytePrice).Add(memoFee...)
 
 	// Charge the extra fee from the user
 	if !totalFee.IsZero() {
-		err := wfd.bankKeeper.SendCoinsFromAccountToModule(ctx, feePayer, types.FeeCollectorName, totalFee)
+		feePayer, err = wfd.chargeBytesFee(ctx, feePayer, feeTx.FeePayer(), totalFee)
 		if err != nil {
 			return err
 		}
 	}
 
+	// Register the charged fee on the block fees audit
+	wfd.feeHandler.TrackBytesFee(ctx, totalFee)
+
+	// Store the receipt of the charged fee, keyed by the TX hash
+	// The baseapp sets the raw TX bytes on the context, the re-encoded bytes are only used without them
+	hashedBytes := ctx.TxBytes()
+	if len(hashedBytes) == 0 {
+		hashedBytes = txBytes
+	}
+	wfd.feeHandler.SetBytesFeeReceipt(ctx, tmhash.Sum(hashedBytes), BytesFeeReceipt{
+		Height:         ctx.BlockHeight(),
+		FeePayer:       feePayer.String(),
+		TxSize:         uint64(bodySize),
+		ExtraBytes:     uint64(extraBytes),
+		BytePrice:      bytePrice,
+		ExtraMemoBytes: uint64(max(extraMemoBytes, 0)),
+		MemoBytePrice:  memoBytePrice,
+		Amount:         totalFee,
+	})
+
 	// Emit events
 	events := sdk.Events{
 		sdk.NewEvent(
 			sdk.EventTypeTx,
 			sdk.NewAttribute(AttributeKeyBytesFee, totalFee.String()),
+			sdk.NewAttribute(AttributeKeyMemoFee, memoFee.String()),
 			sdk.NewAttribute(sdk.AttributeKeyFeePayer, feePayer.String()),
+			sdk.NewAttribute(AttributeKeySurgeMultiplier, surgeMultiplier.String()),
+			sdk.NewAttribute(AttributeKeyRelayerDiscount, relayerDiscount.String()),
 		),
 	}
 	ctx.EventManager().EmitEvents(events)
@@ -110,6 +261,38 @@ func (wfd WeightedFeeDecorator) checkDeductFee(ctx sdk.Context, tx sdk.Tx) error
 	return nil
 }
 
+// chargeBytesFee sends the byte fee from the fee payer to the fee collector and returns who paid it
+// If the packets sender can't pay, the TX fee payer is charged instead
+// Otherwise a sender could block the acknowledgements and timeouts of its packets by emptying its account
+func (wfd WeightedFeeDecorator) chargeBytesFee(ctx sdk.Context, feePayer, txFeePayer sdk.AccAddress, fee sdk.Coins) (sdk.AccAddress, error) {
+	if !feePayer.Equals(txFeePayer) {
+		// The sender charge runs on a cache, so a failed send doesn't leave a partial charge behind
+		cacheCtx, write := ctx.CacheContext()
+		err := wfd.bankKeeper.SendCoinsFromAccountToModule(cacheCtx, feePayer, types.FeeCollectorName, fee)
+		if err == nil {
+			write()
+			return feePayer, nil
+		}
+		if !errors.Is(err, errortypes.ErrInsufficientFunds) {
+			return nil, err
+		}
+		feePayer = txFeePayer
+	}
+
+	err := wfd.bankKeeper.SendCoinsFromAccountToModule(ctx, feePayer, types.FeeCollectorName, fee)
+	if errors.Is(err, errortypes.ErrInsufficientFunds) {
+		return nil, wrapWithCause(
+			ErrInsufficientByteFee, err,
+			"fee payer %s can't pay the byte fee %s", feePayer, fee,
+		)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return feePayer, nil
+}
+
 // calculateFeeForBytes calculate the fees for a txbytes
 // It use the formula: Fee price * size
 // This also truncate the decimals
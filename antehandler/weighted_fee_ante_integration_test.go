@@ -0,0 +1,207 @@
+package antehandler_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	errorsmod "cosmossdk.io/errors"
+	"cosmossdk.io/math"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	authante "github.com/cosmos/cosmos-sdk/x/auth/ante"
+	authtx "github.com/cosmos/cosmos-sdk/x/auth/tx"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+
+	ante "ibc-fee/antehandler"
+)
+
+// TestWeightedFeeAnteIntegration tests the weighted fee antehandler with real keepers and signed TXs
+func TestWeightedFeeAnteIntegration(t *testing.T) {
+	initialBalance := sdk.NewCoins(sdk.NewCoin("testcoin", math.NewInt(10_000)))
+
+	// All the test cases
+	testCases := []struct {
+		name      string
+		totalMsgs int
+		minTxSize uint64
+		chargeFee bool
+	}{
+		{
+			name:      "No fee, signed tx below a large threshold",
+			totalMsgs: 1,
+			minTxSize: 1_000,
+			chargeFee: false,
+		},
+		{
+			name:      "Fee, single bank send message",
+			totalMsgs: 1,
+			minTxSize: DefaultMinTxSize,
+			chargeFee: true,
+		},
+		{
+			name:      "Fee, multiple bank send messages",
+			totalMsgs: 5,
+			minTxSize: DefaultMinTxSize,
+			chargeFee: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("Case %s", tc.name), func(t *testing.T) {
+			s := SetupIntegrationTestSuite(t, false)
+			s.feeHandler.params.MinTxSize = tc.minTxSize
+			antehandler := newIntegrationAnteHandler(s)
+
+			// Create the payer and the TX
+			payer := s.CreateFundedAccount(t, initialBalance)
+			receiver := s.CreateFundedAccount(t, nil)
+			tx := s.CreateSignedTx(t, newBankSendMsgs(payer, receiver, tc.totalMsgs), payer)
+
+			// Run the antehandler
+			events, err := s.RunAnteHandler(antehandler, tx)
+			require.NoError(t, err)
+
+			// Calculate the expected fee from the TX size
+			expectedFee := expectedBytesFee(t, s, tx)
+			require.Equal(t, tc.chargeFee, !expectedFee.IsZero())
+
+			// Assert the balances movement
+			require.Equal(t, initialBalance.Sub(expectedFee...), s.bankKeeper.GetAllBalances(s.ctx, payer.Address))
+			require.Equal(t, expectedFee, s.FeeCollectorBalance())
+
+			// Assert the emitted event, TXs bellow the threshold don't emit it
+			event, found := findBytesFeeEvent(events)
+			require.Equal(t, tc.chargeFee, found)
+			if !found {
+				return
+			}
+			require.Equal(t, expectedFee.String(), eventAttribute(event, ante.AttributeKeyBytesFee))
+			require.Equal(t, payer.Address.String(), eventAttribute(event, sdk.AttributeKeyFeePayer))
+		})
+	}
+}
+
+// TestWeightedFeeAnteIntegrationInsufficientFunds tests a payer without enough funds to pay the byte fee
+func TestWeightedFeeAnteIntegrationInsufficientFunds(t *testing.T) {
+	s := SetupIntegrationTestSuite(t, false)
+	antehandler := newIntegrationAnteHandler(s)
+
+	// The payer only has a single coin, bellow any byte fee
+	initialBalance := sdk.NewCoins(sdk.NewCoin("testcoin", math.OneInt()))
+	payer := s.CreateFundedAccount(t, initialBalance)
+	receiver := s.CreateFundedAccount(t, nil)
+	tx := s.CreateSignedTx(t, newBankSendMsgs(payer, receiver, 2), payer)
+
+	// Run the antehandler
+	_, err := s.RunAnteHandler(antehandler, tx)
+	require.ErrorIs(t, err, ante.ErrInsufficientByteFee)
+	require.ErrorIs(t, err, sdkerrors.ErrInsufficientFunds)
+
+	// The bank error is kept on the chain, but the ABCI code is the byte fee one
+	codespace, code, _ := errorsmod.ABCIInfo(err, false)
+	require.Equal(t, ante.FeeHandlerModuleName, codespace)
+	require.Equal(t, ante.ErrInsufficientByteFee.ABCICode(), code)
+
+	// No balance should have been moved
+	require.Equal(t, initialBalance, s.bankKeeper.GetAllBalances(s.ctx, payer.Address))
+	require.True(t, s.FeeCollectorBalance().IsZero())
+}
+
+// TestWeightedFeeAnteIntegrationRollback tests that the byte fee is rolled back when a later decorator fails
+func TestWeightedFeeAnteIntegrationRollback(t *testing.T) {
+	s := SetupIntegrationTestSuite(t, false)
+
+	// A decorator chain that always fails after the byte fee is charged
+	errFailingDecorator := fmt.Errorf("failing decorator")
+	antehandler := sdk.ChainAnteDecorators(
+		ante.NewWeightedFeeDecorator(s.bankKeeper, s.feeHandler),
+		failingDecorator{err: errFailingDecorator},
+	)
+
+	initialBalance := sdk.NewCoins(sdk.NewCoin("testcoin", math.NewInt(10_000)))
+	payer := s.CreateFundedAccount(t, initialBalance)
+	receiver := s.CreateFundedAccount(t, nil)
+	tx := s.CreateSignedTx(t, newBankSendMsgs(payer, receiver, 2), payer)
+
+	// Run the antehandler
+	_, err := s.RunAnteHandler(antehandler, tx)
+	require.ErrorIs(t, err, errFailingDecorator)
+
+	// The charged fee must have been discarded
+	require.Equal(t, initialBalance, s.bankKeeper.GetAllBalances(s.ctx, payer.Address))
+	require.True(t, s.FeeCollectorBalance().IsZero())
+}
+
+// newIntegrationAnteHandler returns a antehandler with signature verification and the weighted fee decorator
+func newIntegrationAnteHandler(s *IntegrationTestSuite) sdk.AnteHandler {
+	return sdk.ChainAnteDecorators(
+		authante.NewSetPubKeyDecorator(s.accountKeeper),
+		authante.NewSigVerificationDecorator(s.accountKeeper, s.encCfg.TxConfig.SignModeHandler()),
+		ante.NewWeightedFeeDecorator(s.bankKeeper, s.feeHandler),
+	)
+}
+
+// newBankSendMsgs creates a list of bank send messages from the payer to the receiver
+func newBankSendMsgs(payer, receiver IntegrationTestAccount, total int) []sdk.Msg {
+	msgs := make([]sdk.Msg, total)
+	for i := range msgs {
+		msgs[i] = banktypes.NewMsgSend(
+			payer.Address,
+			receiver.Address,
+			sdk.NewCoins(sdk.NewCoin("testcoin", math.OneInt())),
+		)
+	}
+	return msgs
+}
+
+// expectedBytesFee calculates the expected byte fee based on the encoded TX size
+func expectedBytesFee(t *testing.T, s *IntegrationTestSuite, tx sdk.Tx) sdk.Coins {
+	txBytes, err := authtx.DefaultTxEncoder()(tx)
+	require.NoError(t, err)
+
+	params := s.feeHandler.GetParams(s.ctx)
+	extraBytes := int64(len(txBytes)) - int64(params.MinTxSize)
+	if extraBytes <= 0 {
+		return sdk.NewCoins()
+	}
+
+	fee, _ := params.FeeBytePrice.MulDec(sdk.NewDec(extraBytes)).TruncateDecimal()
+	return fee
+}
+
+// findBytesFeeEvent returns the event emitted by the weighted fee antehandler
+func findBytesFeeEvent(events sdk.Events) (sdk.Event, bool) {
+	for _, event := range events {
+		if event.Type != sdk.EventTypeTx {
+			continue
+		}
+		for _, attr := range event.Attributes {
+			if attr.Key == ante.AttributeKeyBytesFee {
+				return event, true
+			}
+		}
+	}
+	return sdk.Event{}, false
+}
+
+// eventAttribute returns the value of a attribute in a event
+func eventAttribute(event sdk.Event, key string) string {
+	for _, attr := range event.Attributes {
+		if attr.Key == key {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// failingDecorator is a decorator that always returns the given error
+type failingDecorator struct {
+	err error
+}
+
+// AnteHandle returns the decorator error
+func (fd failingDecorator) AnteHandle(ctx sdk.Context, _ sdk.Tx, _ bool, _ sdk.AnteHandler) (sdk.Context, error) {
+	return ctx, fd.err
+}
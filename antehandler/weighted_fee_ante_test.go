@@ -2,6 +2,7 @@ package antehandler_test
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/golang/mock/gomock"
@@ -12,6 +13,7 @@ import (
 	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
 	"github.com/cosmos/cosmos-sdk/types/module/testutil"
 	"github.com/cosmos/cosmos-sdk/x/auth/signing"
+	authtx "github.com/cosmos/cosmos-sdk/x/auth/tx"
 	banktyppes "github.com/cosmos/cosmos-sdk/x/bank/types"
 	ibcclienttypes "github.com/cosmos/ibc-go/v7/modules/core/02-client/types"
 
@@ -111,8 +113,9 @@ func TestWeightedFeeAnte(t *testing.T) {
 			// Run the antehandler
 			_, err := antehandler(s.ctx, tx, false)
 
-			// If a expected fee has been passed we will get insufficient funds error
+			// If a expected fee has been passed we will get insufficient byte fee error
 			if tc.expectedFee != nil {
+				require.ErrorIs(t, err, ante.ErrInsufficientByteFee)
 				require.ErrorIs(t, err, sdkerrors.ErrInsufficientFunds)
 			} else {
 				require.NoError(t, err)
@@ -122,8 +125,174 @@ func TestWeightedFeeAnte(t *testing.T) {
 	}
 }
 
+// TestWeightedFeeAnteMemo tests the separate pricing of memo bytes
+func TestWeightedFeeAnteMemo(t *testing.T) {
+	// Prepare the testing data
+	accAddr1 := sdk.AccAddress([]byte("acc1"))
+	accAddr2 := sdk.AccAddress([]byte("acc2"))
+	msgs := []sdk.Msg{
+		banktyppes.NewMsgSend( // This TX is exactly at the threshold limit without a memo
+			accAddr1,
+			accAddr2,
+			sdk.NewCoins(sdk.NewCoin("utestcoin", sdk.OneInt())),
+		),
+	}
+	memoBytePrice := sdk.NewDecCoins(sdk.NewDecCoinFromDec("testcoin", sdk.NewDec(2)))
+
+	// All the test cases
+	testCases := []struct {
+		name            string
+		memo            string
+		memoBytePrice   sdk.DecCoins
+		expectedFee     sdk.Coins
+		expectedMemoFee sdk.Coins
+	}{
+		{
+			name:            "Memo priced as the rest of the TX when there is no memo price",
+			memo:            strings.Repeat("m", 50),
+			memoBytePrice:   nil,
+			expectedFee:     sdk.NewCoins(sdk.NewCoin("testcoin", math.NewInt(53))), // 50 memo bytes and 3 bytes of encoding
+			expectedMemoFee: sdk.NewCoins(),
+		},
+		{
+			name:            "Memo inside the free allowance",
+			memo:            strings.Repeat("m", 10),
+			memoBytePrice:   memoBytePrice,
+			expectedFee:     sdk.NewCoins(sdk.NewCoin("testcoin", math.NewInt(2))), // Only the 2 bytes of encoding are charged
+			expectedMemoFee: sdk.NewCoins(),
+		},
+		{
+			name:            "Memo above the free allowance",
+			memo:            strings.Repeat("m", 50),
+			memoBytePrice:   memoBytePrice,
+			expectedFee:     sdk.NewCoins(sdk.NewCoin("testcoin", math.NewInt(83))), // 3 bytes of encoding + 40 memo bytes at price 2
+			expectedMemoFee: sdk.NewCoins(sdk.NewCoin("testcoin", math.NewInt(80))),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("Case %s", tc.name), func(t *testing.T) {
+			// At each run we restart our setup with a new fee decorator
+			s := SetupTestSuite(t, false)
+			s.feeHandler.params.MemoBytePrice = tc.memoBytePrice
+			s.feeHandler.params.FreeMemoBytes = 10
+			dfd := ante.NewWeightedFeeDecorator(s.bankKeeper, s.feeHandler)
+			antehandler := sdk.ChainAnteDecorators(dfd)
+
+			// Build a new TX with the memo
+			tx := createTXWithMemo(t, msgs, tc.memo)
+
+			// Expect the call with the correct balance and let it succeed to check the events
+			s.bankKeeper.EXPECT().SendCoinsFromAccountToModule(gomock.Any(), gomock.Any(), gomock.Any(), tc.expectedFee).Return(nil)
+
+			// Run the antehandler
+			newCtx, err := antehandler(s.ctx, tx, false)
+			require.NoError(t, err)
+
+			// The memo fee must be its own attribute
+			event, found := findBytesFeeEvent(newCtx.EventManager().Events())
+			require.True(t, found)
+			require.Equal(t, tc.expectedFee.String(), eventAttribute(event, ante.AttributeKeyBytesFee))
+			require.Equal(t, tc.expectedMemoFee.String(), eventAttribute(event, ante.AttributeKeyMemoFee))
+		})
+	}
+}
+
+// TestWeightedFeeAnteFeatureGate tests the pass-through of the antehandler while the byte fees are not active
+func TestWeightedFeeAnteFeatureGate(t *testing.T) {
+	// Prepare the testing data
+	accAddr1 := sdk.AccAddress([]byte("acc1"))
+	accAddr2 := sdk.AccAddress([]byte("acc2"))
+	msgs := []sdk.Msg{
+		banktyppes.NewMsgSend(accAddr1, accAddr2, sdk.NewCoins(sdk.NewCoin("utestcoin", sdk.OneInt()))),
+		banktyppes.NewMsgSend(accAddr1, accAddr2, sdk.NewCoins(sdk.NewCoin("utestcoin", sdk.OneInt()))),
+	}
+
+	// All the test cases
+	testCases := []struct {
+		name             string
+		enabled          bool
+		activationHeight int64
+		chargeFee        bool
+	}{
+		{
+			name:             "Disabled byte fees pass through",
+			enabled:          false,
+			activationHeight: 0,
+			chargeFee:        false,
+		},
+		{
+			name:             "Enabled byte fees pass through before the activation height",
+			enabled:          true,
+			activationHeight: 11,
+			chargeFee:        false,
+		},
+		{
+			name:             "Enabled byte fees are charged at the activation height",
+			enabled:          true,
+			activationHeight: 10,
+			chargeFee:        true,
+		},
+		{
+			name:             "Disabled byte fees pass through after the activation height",
+			enabled:          false,
+			activationHeight: 5,
+			chargeFee:        false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("Case %s", tc.name), func(t *testing.T) {
+			// At each run we restart our setup with a new fee decorator
+			s := SetupTestSuite(t, false)
+			s.ctx = s.ctx.WithBlockHeight(10)
+			s.feeHandler.params.Enabled = tc.enabled
+			s.feeHandler.params.ActivationHeight = tc.activationHeight
+			dfd := ante.NewWeightedFeeDecorator(s.bankKeeper, s.feeHandler)
+			antehandler := sdk.ChainAnteDecorators(dfd)
+
+			tx := createTX(t, msgs)
+
+			// The bank keeper is only called if the byte fees are active
+			if tc.chargeFee {
+				s.bankKeeper.EXPECT().SendCoinsFromAccountToModule(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+			}
+
+			// Run the antehandler
+			newCtx, err := antehandler(s.ctx, tx, false)
+			require.NoError(t, err)
+
+			// The pass-through only emits the TX size
+			_, found := findBytesFeeEvent(newCtx.EventManager().Events())
+			require.Equal(t, tc.chargeFee, found)
+			sizeEvent, found := findTxSizeEvent(newCtx.EventManager().Events())
+			require.Equal(t, !tc.chargeFee, found)
+			if found {
+				txBytes, err := authtx.DefaultTxEncoder()(tx)
+				require.NoError(t, err)
+				require.Equal(t, fmt.Sprint(len(txBytes)), eventAttribute(sizeEvent, ante.AttributeKeyTxSize))
+			}
+		})
+	}
+}
+
+// findTxSizeEvent returns the size only event emitted by the weighted fee antehandler while not active
+func findTxSizeEvent(events sdk.Events) (sdk.Event, bool) {
+	for _, event := range events {
+		if event.Type == sdk.EventTypeTx && eventAttribute(event, ante.AttributeKeyTxSize) != "" {
+			return event, true
+		}
+	}
+	return sdk.Event{}, false
+}
+
 // createTX creates a new testing tx from current encoding
 func createTX(t *testing.T, msgs []sdk.Msg) signing.Tx {
+	return createTXWithMemo(t, msgs, "")
+}
+
+// createTXWithMemo creates a new testing tx with a memo from current encoding
+func createTXWithMemo(t *testing.T, msgs []sdk.Msg, memo string) signing.Tx {
 	// Create the TX
 	encodingConfig := testutil.MakeTestEncodingConfig()
 	txBuilder := encodingConfig.TxConfig.NewTxBuilder()
@@ -131,6 +300,7 @@ func createTX(t *testing.T, msgs []sdk.Msg) signing.Tx {
 	// Set the msgs
 	err := txBuilder.SetMsgs(msgs...)
 	require.NoError(t, err)
+	txBuilder.SetMemo(memo)
 
 	return txBuilder.GetTx()
 }